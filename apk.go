@@ -4,6 +4,8 @@ import (
 	"archive/zip"
 	"bytes"
 	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/xml"
 	"errors"
 	"fmt"
@@ -12,24 +14,81 @@ import (
 	_ "image/png"  // handle png format
 	"io"
 	"os"
-	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 )
 
-// apk is an application package file for android.
+// iconResConfig selects the highest density artwork of the APK.
+var iconResConfig = &ResTableConfig{
+	Density: 720,
+}
+
+// labelResConfig selects the Chinese label of the APK, if translated.
+var labelResConfig = &ResTableConfig{
+	Language: [2]uint8{'z', 'h'},
+	Country:  [2]uint8{'C', 'N'},
+}
+
+// apk is an application package file for android. Its parts are parsed on
+// demand by the load methods, once, so that it is safe for concurrent use.
 type apk struct {
-	f           *os.File
-	zipReader   *zip.Reader
-	apkManifest apkManifest
-	table       *TableFile
+	f          *os.File
+	r          *io.SectionReader // the contents of the APK
+	archive    *zipArchive
+	size       int64
+	limits     Limits
+	read       atomic.Int64 // uncompressed bytes read of the zip entries
+	bestEffort bool         // whether stages which fail are reported as warnings
+
+	withDex           bool // whether frameworks are also detected by classes
+	withEngineVersion bool // whether native libraries are searched for versions
+
+	manifestOnce sync.Once
+	manifestErr  error
+	apkManifest  apkManifest
+	manifestXML  *XMLFile
+
+	resourcesOnce sync.Once
+	resourcesErr  error
+	table         *TableFile // empty if the resources can't be read
+
+	abiOnce     sync.Once
 	supportOs32 bool
 	supportOs64 bool
-	md5         string
-	size        int64
+	abis        []string
+
+	digestsOnce sync.Once
+	digestsErr  error
+	digests     Digests
+
+	signatureOnce sync.Once
+	signatureErr  error
+	cert          *CertInfo
+
+	iconOnce sync.Once
+	iconErr  error
+	iconImg  image.Image
+}
+
+// openFile will open the file specified by filename and return apk, with
+// its manifest, resources and ABIs parsed. The zip archive is read in
+// tolerant mode if option.Tolerant is set.
+func openFile(filename string, option Option) (*apk, error) {
+	k, err := openLazyFile(filename, option)
+	if err != nil {
+		return nil, err
+	}
+	if err := k.load(); err != nil {
+		k.close()
+		return nil, err
+	}
+	return k, nil
 }
 
-// openFile will open the file specified by filename and return apk
-func openFile(filename string) (apk *apk, err error) {
+// openLazyFile will open the file specified by filename and return apk,
+// whose parts are parsed on demand.
+func openLazyFile(filename string, option Option) (*apk, error) {
 	f, err := os.Open(filename)
 	if err != nil {
 		return nil, err
@@ -39,35 +98,131 @@ func openFile(filename string) (apk *apk, err error) {
 		f.Close()
 		return nil, err
 	}
-	apk, err = openZipReader(f, fi.Size())
+	k, err := newAPK(f, fi.Size(), option)
 	if err != nil {
 		f.Close()
 		return nil, err
 	}
-	apk.f = f
-	apk.size = fi.Size()
-	return
+	k.f = f
+	return k, nil
 }
 
-// openZipReader has same arguments like zip.NewReader
-func openZipReader(r *os.File, size int64) (*apk, error) {
-	zipReader, err := zip.NewReader(r, size)
+// openZipReader has same arguments like zip.NewReader, and returns apk
+// parsed like openFile does.
+func openZipReader(r io.ReaderAt, size int64, option Option) (*apk, error) {
+	k, err := newAPK(r, size, option)
 	if err != nil {
 		return nil, err
 	}
-	apk := &apk{
-		zipReader: zipReader,
+	if err := k.load(); err != nil {
+		return nil, err
 	}
-	if err = apk.parseManifest(); err != nil {
-		return nil, errors.New("parse-apkManifest:" + err.Error())
+	return k, nil
+}
+
+// newAPK has same arguments like zip.NewReader, and reads the zip archive
+// like libziparchive if option.Tolerant is set. The parts of the APK are
+// parsed on demand within option.Limits.
+func newAPK(r io.ReaderAt, size int64, option Option) (*apk, error) {
+	var (
+		archive *zipArchive
+		err     error
+	)
+	if option.Tolerant {
+		archive, err = readTolerantZip(r, size)
+	} else {
+		var zipReader *zip.Reader
+		if zipReader, err = zip.NewReader(r, size); err == nil {
+			archive = newZipArchive(zipReader)
+		}
 	}
-	if err = apk.parseResources(); err != nil {
-		return nil, err
+	if err != nil {
+		return nil, &kindError{ErrNotAPK, err}
 	}
-	apk.parseOsSupport(zipReader)
-	apk.getApkMd5(r)
+	return &apk{
+		r:          io.NewSectionReader(r, 0, size),
+		size:       size,
+		archive:    archive,
+		limits:     option.Limits.withDefaults(),
+		bestEffort: option.BestEffort,
+
+		withDex:           option.WithDex,
+		withEngineVersion: option.WithEngineVersion,
+	}, nil
+}
 
-	return apk, nil
+// load parses the manifest, the resources and the ABIs of the APK, which
+// most of its methods use. In best-effort mode the APK is used without
+// resources if they can't be read.
+func (k *apk) load() error {
+	if err := k.loadManifest(); err != nil {
+		return err
+	}
+	if err := k.loadResources(); err != nil {
+		if !k.bestEffort {
+			return err
+		}
+		k.failed(WarningResources, err)
+	}
+	k.loadABIs()
+	return nil
+}
+
+// loadManifest parses AndroidManifest.xml, once.
+func (k *apk) loadManifest() error {
+	k.manifestOnce.Do(func() {
+		k.manifestErr = k.parseManifest()
+	})
+	return k.manifestErr
+}
+
+// loadResources parses resources.arsc, once. The table is left empty if it
+// can't be read.
+func (k *apk) loadResources() error {
+	k.resourcesOnce.Do(func() {
+		if k.resourcesErr = k.parseResources(); k.resourcesErr != nil {
+			k.table = &TableFile{tablePackages: make(map[uint32]*TablePackage)}
+		}
+	})
+	return k.resourcesErr
+}
+
+// loadABIs lists the ABIs of the native libraries, once.
+func (k *apk) loadABIs() {
+	k.abiOnce.Do(k.parseOsSupport)
+}
+
+// loadDigests computes the digests of the APK file, once.
+func (k *apk) loadDigests() (Digests, error) {
+	k.digestsOnce.Do(func() {
+		k.digests, k.digestsErr = k.getApkDigests(io.NewSectionReader(k.r, 0, k.size))
+	})
+	return k.digests, k.digestsErr
+}
+
+// loadSignature verifies the signature of the APK, once, against the SDK
+// versions of the manifest.
+func (k *apk) loadSignature() (*CertInfo, error) {
+	k.signatureOnce.Do(func() {
+		if k.signatureErr = k.loadManifest(); k.signatureErr == nil {
+			k.cert, k.signatureErr = getSignature(k)
+		}
+	})
+	return k.cert, k.signatureErr
+}
+
+// loadIcon decodes the icon of the APK, once.
+func (k *apk) loadIcon() (image.Image, error) {
+	k.iconOnce.Do(func() {
+		if k.iconErr = k.loadManifest(); k.iconErr != nil {
+			return
+		}
+		if k.iconErr = k.loadResources(); k.iconErr != nil {
+			return
+		}
+		k.iconImg, k.iconErr = k.icon(iconResConfig)
+	})
+	return k.iconImg, k.iconErr
 }
 
 // close is avaliable only if apk is created with openFile
@@ -80,16 +235,30 @@ func (k *apk) close() error {
 
 // icon returns the icon image of the APK.
 func (k *apk) icon(resConfig *ResTableConfig) (image.Image, error) {
-	iconPath := k.getResource(k.apkManifest.App.Icon, resConfig)
-	if IsResID(iconPath) {
-		return nil, errors.New("unable to convert icon-id to icon path")
-	}
-	imgData, err := k.readZipFile(iconPath)
-	if err != nil {
-		return nil, err
+	return k.image(k.apkManifest.App.Icon, resConfig)
+}
+
+// roundIcon returns the round icon image of the APK.
+func (k *apk) roundIcon(resConfig *ResTableConfig) (image.Image, error) {
+	return k.image(k.apkManifest.App.RoundIcon, resConfig)
+}
+
+// banner returns the Android TV home screen banner of the APK. The banner
+// of the application takes precedence over the banner of its activities.
+func (k *apk) banner(resConfig *ResTableConfig) (image.Image, error) {
+	ref := k.apkManifest.App.Banner
+	for _, act := range k.apkManifest.App.Activities {
+		if ref != "" {
+			break
+		}
+		ref = act.Banner
 	}
-	m, _, err := image.Decode(bytes.NewReader(imgData))
-	return m, err
+	return k.image(ref, resConfig)
+}
+
+// logo returns the logo image of the APK.
+func (k *apk) logo(resConfig *ResTableConfig) (image.Image, error) {
+	return k.image(k.apkManifest.App.Logo, resConfig)
 }
 
 // label returns the label of the APK.
@@ -115,16 +284,16 @@ func (k *apk) packageName() string {
 func (k *apk) mainActivity() (activity string, err error) {
 	for _, act := range k.apkManifest.App.Activities {
 		for _, intent := range act.IntentFilters {
-			if intent.Action.Name == "android.intent.action.MAIN" &&
-				intent.Category.Name == "android.intent.category.LAUNCHER" {
+			if intent.hasAction("android.intent.action.MAIN") &&
+				intent.hasCategory("android.intent.category.LAUNCHER") {
 				return act.Name, nil
 			}
 		}
 	}
 	for _, act := range k.apkManifest.App.ActivityAliases {
 		for _, intent := range act.IntentFilters {
-			if intent.Action.Name == "android.intent.action.MAIN" &&
-				intent.Category.Name == "android.intent.category.LAUNCHER" {
+			if intent.hasAction("android.intent.action.MAIN") &&
+				intent.hasCategory("android.intent.category.LAUNCHER") {
 				return act.TargetActivity, nil
 			}
 		}
@@ -134,28 +303,71 @@ func (k *apk) mainActivity() (activity string, err error) {
 }
 
 func (k *apk) parseManifest() error {
-	xmlData, err := k.readZipFile("AndroidManifest.xml")
-	if err != nil {
-		return errors.New("read-apkManifest.xml" + err.Error())
+	const name = "AndroidManifest.xml"
+	xmlData, err := k.readZipFile(name)
+	if errors.Is(err, ErrEntryNotFound) {
+		return ErrNoManifest
+	} else if err != nil {
+		return err
 	}
-	xmlFile, err := NewXMLFile(bytes.NewReader(xmlData))
+	xmlFile, err := newXMLFile(bytes.NewReader(xmlData), k.limits)
 	if err != nil {
-		return errors.New("parse-xml:" + err.Error())
+		return &EntryError{Op: "parse", Name: name, Err: err}
 	}
+	k.manifestXML = xmlFile
 	reader := xmlFile.Reader()
 	data, err := io.ReadAll(reader)
 	if err != nil {
 		return err
 	}
-	return xml.Unmarshal(data, &k.apkManifest)
+	if err := xml.Unmarshal(data, &k.apkManifest); err != nil {
+		return &EntryError{Op: "parse", Name: name, Err: err}
+	}
+	return nil
+}
+
+// readXML reads the compiled XML file name, e.g. res/xml/file_paths.xml.
+// Its references are left unresolved if the resources can't be read.
+func (k *apk) readXML(name string) (*XMLFile, error) {
+	data, err := k.readZipFile(name)
+	if err != nil {
+		return nil, err
+	}
+	xmlFile, err := newXMLFile(bytes.NewReader(data), k.limits)
+	if err != nil {
+		return nil, &EntryError{Op: "parse", Name: name, Err: err}
+	}
+	if k.loadResources() == nil {
+		xmlFile.table = k.table
+	}
+	return xmlFile, nil
+}
+
+// readXMLResource reads the compiled XML file of the resource id.
+func (k *apk) readXMLResource(id ResID, resConfig *ResTableConfig) (*XMLFile, error) {
+	v, err := k.table.getValue(id, resConfig)
+	if err != nil {
+		return nil, err
+	}
+	if v.DataType != TypeString {
+		return nil, fmt.Errorf("resource %s is not a file", id)
+	}
+	name, err := k.table.getString(ResStringPoolRef(v.Data))
+	if err != nil {
+		return nil, err
+	}
+	return k.readXML(name)
 }
 
 func (k *apk) parseResources() (err error) {
-	resData, err := k.readZipFile("resources.arsc")
+	const name = "resources.arsc"
+	resData, err := k.readZipFile(name)
 	if err != nil {
 		return
 	}
-	k.table, err = NewTableFile(bytes.NewReader(resData))
+	if k.table, err = newTableFile(bytes.NewReader(resData), k.limits); err != nil {
+		return &EntryError{Op: "parse", Name: name, Err: err}
+	}
 	return
 }
 
@@ -173,40 +385,71 @@ func (k *apk) getResource(id string, resConfig *ResTableConfig) string {
 
 func (k *apk) readZipFile(name string) (data []byte, err error) {
 	buf := bytes.NewBuffer(nil)
-	for _, file := range k.zipReader.File {
-		if file.Name != name {
-			continue
+	if file := k.archive.lookup(name); file != nil {
+		// the sizes in the zip archive may lie, so the data is limited too.
+		maxSize := k.limits.MaxEntrySize
+		limitErr := &LimitError{Limit: "MaxEntrySize", Max: maxSize, Name: name}
+		read := k.read.Load()
+		if total := k.limits.MaxTotalSize; total >= 0 && (maxSize < 0 || total-read < maxSize) {
+			maxSize = total - read
+			if maxSize < 0 {
+				maxSize = 0
+			}
+			limitErr = &LimitError{Limit: "MaxTotalSize", Max: total, Name: name}
+		}
+		if exceeds(int64(file.UncompressedSize64), maxSize) {
+			return nil, limitErr
 		}
 		rc, er := file.Open()
 		if er != nil {
-			fmt.Println("file.Open " + er.Error())
-			err = er
-			return
+			return nil, &EntryError{Op: "open", Name: name, Err: er}
+		}
+		var r io.Reader = rc
+		if maxSize >= 0 {
+			r = io.LimitReader(rc, maxSize+1)
 		}
-		_, err = io.Copy(buf, rc)
+		var n int64
+		n, err = io.Copy(buf, r)
+		k.read.Add(n)
 		if err != nil {
 			_ = rc.Close()
-			return data, err
+			return nil, &EntryError{Op: "read", Name: name, Err: err}
 		}
-		data = buf.Bytes()
 		_ = rc.Close()
-		break
+		if exceeds(n, maxSize) {
+			return nil, limitErr
+		}
+		data = buf.Bytes()
 	}
 	if len(data) == 0 {
-		return nil, fmt.Errorf("file %s not found", strconv.Quote(name))
+		return nil, &EntryError{Op: "read", Name: name, Err: ErrEntryNotFound}
 	} else {
 		return data, nil
 	}
 }
 
-func (k *apk) parseOsSupport(r *zip.Reader) {
+// knownABIs are the ABIs which Android has supported.
+var knownABIs = []string{"armeabi", "armeabi-v7a", "arm64-v8a", "x86", "x86_64", "mips", "mips64", "riscv64"}
+
+func (k *apk) parseOsSupport() {
 	var (
 		hasSoFile                bool
 		isSupport32, isSupport64 bool
 	)
-	for _, f := range r.File {
+	for _, f := range k.archive.files {
 		if strings.HasSuffix(f.Name, ".so") {
 			hasSoFile = true
+			// native libraries are stored in lib/<abi>/
+			parts := strings.Split(f.Name, "/")
+			switch {
+			case len(parts) != 3 || parts[0] != "lib":
+				k.failed(WarningABI, &EntryError{Op: "parse", Name: f.Name, Err: errors.New("native library outside of lib/<abi>/")})
+			case !containsString(knownABIs, parts[1]):
+				k.failed(WarningABI, &EntryError{Op: "parse", Name: f.Name, Err: fmt.Errorf("unknown ABI %q", parts[1])})
+			}
+			if len(parts) == 3 && parts[0] == "lib" && !containsString(k.abis, parts[1]) {
+				k.abis = append(k.abis, parts[1])
+			}
 		}
 		// if strings.HasPrefix(f.Name, "lib/arm64-v8a") {
 		// 	fmt.Println(f.Name)
@@ -233,32 +476,126 @@ func (k *apk) parseOsSupport(r *zip.Reader) {
 	}
 }
 
-// 获取apk md5
-func (k *apk) getApkMd5(file *os.File) {
-	hash := md5.New()
-	if _, err := io.Copy(hash, file); err != nil {
-		return
+// 获取apk md5、sha1和sha256
+func (k *apk) getApkDigests(file io.Reader) (Digests, error) {
+	md5Hash, sha1Hash, sha256Hash := md5.New(), sha1.New(), sha256.New()
+	if _, err := io.Copy(io.MultiWriter(md5Hash, sha1Hash, sha256Hash), file); err != nil {
+		return Digests{}, err
 	}
 
-	k.md5 = fmt.Sprintf("%032x", hash.Sum(nil))
+	return Digests{
+		MD5:    fmt.Sprintf("%032x", md5Hash.Sum(nil)),
+		SHA1:   fmt.Sprintf("%040x", sha1Hash.Sum(nil)),
+		SHA256: fmt.Sprintf("%064x", sha256Hash.Sum(nil)),
+	}, nil
+}
+
+// failed reports that the stage code of parsing failed with err as a
+// warning, in best-effort mode.
+func (k *apk) failed(code string, err error) {
+	if !k.bestEffort || err == nil {
+		return
+	}
+	w := Warning{Code: code, Message: err.Error()}
+	var entryErr *EntryError
+	if errors.As(err, &entryErr) {
+		w.Entry = entryErr.Name
+		if err == error(entryErr) {
+			w.Message = entryErr.Err.Error()
+		}
+	}
+	k.archive.warn(w)
 }
 
 // 解析apk名称
 func (k *apk) parseApkLabel() string {
-	label, _ := k.label(&ResTableConfig{
-		Language: [2]uint8{'z', 'h'},
-		Country:  [2]uint8{'C', 'N'},
-	})
+	label, err := k.label(labelResConfig)
 	// label, _ := pkg.label(&ResTableConfig{})
+	k.failed(WarningLabel, err)
 
 	return label
 }
 
 // 解析apk图标
 func (k *apk) parseApkIcon() image.Image {
-	icon, _ := k.icon(&ResTableConfig{
-		Density: 720,
-	})
+	icon, err := k.loadIcon()
+	k.failedImage("icon", err)
+
+	return icon
+}
+
+// 解析apk圆形图标
+func (k *apk) parseApkRoundIcon() image.Image {
+	icon, err := k.roundIcon(iconResConfig)
+	k.failedImage("round icon", err)
 
 	return icon
 }
+
+// 解析apk横幅(Android TV)
+func (k *apk) parseApkBanner() image.Image {
+	banner, err := k.banner(iconResConfig)
+	k.failedImage("banner", err)
+
+	return banner
+}
+
+// 解析apk logo
+func (k *apk) parseApkLogo() image.Image {
+	logo, err := k.logo(iconResConfig)
+	k.failedImage("logo", err)
+
+	return logo
+}
+
+// failedImage reports that the image what, e.g. icon, failed to decode with
+// err, unless the APK doesn't declare it.
+func (k *apk) failedImage(what string, err error) {
+	if err != nil && !errors.Is(err, errNoDrawable) {
+		k.failed(WarningIcon, fmt.Errorf("%s: %w", what, err))
+	}
+}
+
+// 解析dex文件信息
+func (k *apk) parseApkDex() *DexReport {
+	report, err := k.dexReport()
+	k.failed(WarningDex, err)
+
+	return report
+}
+
+// 识别开发框架，withDex为true时也根据dex文件中的类识别，withEngineVersion为true时从原生库中提取引擎版本
+func (k *apk) parseApkFrameworks(withDex, withEngineVersion bool) []Framework {
+	var classNames []string
+	if withDex {
+		var err error
+		classNames, err = k.classNames()
+		k.failed(WarningFrameworks, err)
+	}
+
+	return k.frameworks(classNames, withEngineVersion)
+}
+
+// 检测混淆和加固
+func (k *apk) parseApkProtection() *ProtectionReport {
+	report, err := k.protection()
+	k.failed(WarningProtection, err)
+
+	return report
+}
+
+// 识别集成的第三方SDK
+func (k *apk) parseApkSDKs(db *SDKDatabase) []SDK {
+	sdks, err := k.sdks(db)
+	k.failed(WarningSDK, err)
+
+	return sdks
+}
+
+// 解析网络安全配置
+func (k *apk) parseApkNetworkSecurity() *NetworkSecurityReport {
+	report, err := k.networkSecurity()
+	k.failed(WarningNetworkSecurity, err)
+
+	return report
+}
@@ -18,10 +18,46 @@ type apkActivityCategory struct {
 	Name string `xml:"name,attr"`
 }
 
+// apkIntentData is a data specification of an intent filter.
+// https://developer.android.com/guide/topics/manifest/data-element
+type apkIntentData struct {
+	Scheme              string `xml:"scheme,attr"`
+	Host                string `xml:"host,attr"`
+	Port                string `xml:"port,attr"`
+	Path                string `xml:"path,attr"`
+	PathPrefix          string `xml:"pathPrefix,attr"`
+	PathPattern         string `xml:"pathPattern,attr"`
+	PathSuffix          string `xml:"pathSuffix,attr"`
+	PathAdvancedPattern string `xml:"pathAdvancedPattern,attr"`
+	MimeType            string `xml:"mimeType,attr"`
+}
+
 // apkActivityIntentFilter is an intent filter of an activity.
 type apkActivityIntentFilter struct {
-	Action   apkActivityAction   `xml:"action"`
-	Category apkActivityCategory `xml:"category"`
+	AutoVerify bool                  `xml:"autoVerify,attr"`
+	Actions    []apkActivityAction   `xml:"action"`
+	Categories []apkActivityCategory `xml:"category"`
+	Data       []apkIntentData       `xml:"data"`
+}
+
+// hasAction returns whether the intent filter matches the action name.
+func (f *apkActivityIntentFilter) hasAction(name string) bool {
+	for _, action := range f.Actions {
+		if action.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// hasCategory returns whether the intent filter matches the category name.
+func (f *apkActivityIntentFilter) hasCategory(name string) bool {
+	for _, category := range f.Categories {
+		if category.Name == name {
+			return true
+		}
+	}
+	return false
 }
 
 // apkAppActivity is an activity in an application.
@@ -29,6 +65,10 @@ type apkAppActivity struct {
 	Theme         string                    `xml:"theme,attr"`
 	Name          string                    `xml:"name,attr"`
 	Label         string                    `xml:"label,attr"`
+	Banner        string                    `xml:"banner,attr"`
+	Orientation   string                    `xml:"screenOrientation,attr"`
+	Exported      *bool                     `xml:"exported,attr"`
+	Permission    string                    `xml:"permission,attr"`
 	IntentFilters []apkActivityIntentFilter `xml:"intent-filter"`
 }
 
@@ -37,14 +77,56 @@ type apkAppActivityAlias struct {
 	Name           string                    `xml:"name,attr"`
 	Label          string                    `xml:"label,attr"`
 	TargetActivity string                    `xml:"targetActivity,attr"`
+	Exported       *bool                     `xml:"exported,attr"`
+	Permission     string                    `xml:"permission,attr"`
 	IntentFilters  []apkActivityIntentFilter `xml:"intent-filter"`
 }
 
+// apkAppService is a service or a broadcast receiver in an application.
+// https://developer.android.com/guide/topics/manifest/service-element
+// https://developer.android.com/guide/topics/manifest/receiver-element
+type apkAppService struct {
+	Name          string                    `xml:"name,attr"`
+	Exported      *bool                     `xml:"exported,attr"`
+	Permission    string                    `xml:"permission,attr"`
+	IntentFilters []apkActivityIntentFilter `xml:"intent-filter"`
+}
+
+// apkAppProvider https://developer.android.com/guide/topics/manifest/provider-element
+type apkAppProvider struct {
+	Name                string              `xml:"name,attr"`
+	Authorities         string              `xml:"authorities,attr"`
+	Exported            *bool               `xml:"exported,attr"`
+	Permission          string              `xml:"permission,attr"`
+	ReadPermission      string              `xml:"readPermission,attr"`
+	WritePermission     string              `xml:"writePermission,attr"`
+	GrantURIPermissions bool                `xml:"grantUriPermissions,attr"`
+	PathPermissions     []apkPathPermission `xml:"path-permission"`
+}
+
+// apkMetaData https://developer.android.com/guide/topics/manifest/meta-data-element
+type apkMetaData struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// apkPathPermission is a permission guarding a subset of the data of a
+// content provider.
+type apkPathPermission struct {
+	Path            string `xml:"path,attr"`
+	PathPrefix      string `xml:"pathPrefix,attr"`
+	PathPattern     string `xml:"pathPattern,attr"`
+	Permission      string `xml:"permission,attr"`
+	ReadPermission  string `xml:"readPermission,attr"`
+	WritePermission string `xml:"writePermission,attr"`
+}
+
 // apkApplication is an application in an APK.
 type apkApplication struct {
 	AllowTaskReParenting  bool                  `xml:"allowTaskReparenting,attr"`
 	AllowBackup           bool                  `xml:"allowBackup,attr"`
 	BackupAgent           string                `xml:"backupAgent,attr"`
+	Banner                string                `xml:"banner,attr"`
 	Debuggable            bool                  `xml:"debuggable,attr"`
 	Description           string                `xml:"description,attr"`
 	Enabled               bool                  `xml:"enabled,attr"`
@@ -56,19 +138,27 @@ type apkApplication struct {
 	Logo                  string                `xml:"logo,attr"`
 	ManageSpaceActivity   string                `xml:"manageSpaceActivity,attr"`
 	Name                  string                `xml:"name,attr"`
+	NetworkSecurityConfig string                `xml:"networkSecurityConfig,attr"`
 	Permission            string                `xml:"permission,attr"`
 	Persistent            bool                  `xml:"persistent,attr"`
 	Process               string                `xml:"process,attr"`
 	RestoreAnyVersion     bool                  `xml:"restoreAnyVersion,attr"`
 	RequiredAccountType   string                `xml:"requiredAccountType,attr"`
 	RestrictedAccountType string                `xml:"restrictedAccountType,attr"`
+	RoundIcon             string                `xml:"roundIcon,attr"`
 	SupportsRtl           bool                  `xml:"supportsRtl,attr"`
 	TaskAffinity          string                `xml:"taskAffinity,attr"`
 	TestOnly              bool                  `xml:"testOnly,attr"`
 	Theme                 string                `xml:"theme,attr"`
 	UIOptions             string                `xml:"uiOptions,attr"`
+	UsesCleartextTraffic  *bool                 `xml:"usesCleartextTraffic,attr"`
 	Activities            []apkAppActivity      `xml:"activity"`
 	ActivityAliases       []apkAppActivityAlias `xml:"activity-alias"`
+	Services              []apkAppService       `xml:"service"`
+	Receivers             []apkAppService       `xml:"receiver"`
+	Providers             []apkAppProvider      `xml:"provider"`
+	UsesLibraries         []apkUsesLibrary      `xml:"uses-library"`
+	MetaData              []apkMetaData         `xml:"meta-data"`
 	// VMSafeMode            bool                  `xml:"vmSafeMode,attr"`
 	// LargeHeap             bool                  `xml:"largeHeap,attr"`
 }
@@ -82,15 +172,78 @@ type apkUsesSDK struct {
 
 // apkManifest is a apkManifest of an APK.
 type apkManifest struct {
-	Package     string             `xml:"package,attr"`
-	VersionCode int64              `xml:"versionCode,attr"`
-	VersionName string             `xml:"versionName,attr"`
-	App         apkApplication     `xml:"application"`
-	Instrument  apkInstrumentation `xml:"instrumentation"`
-	Permissions []permission       `xml:"uses-permission"`
-	SDK         apkUsesSDK         `xml:"uses-sdk"`
+	Package             string                 `xml:"package,attr"`
+	VersionCode         int64                  `xml:"versionCode,attr"`
+	VersionName         string                 `xml:"versionName,attr"`
+	App                 apkApplication         `xml:"application"`
+	Instrument          apkInstrumentation     `xml:"instrumentation"`
+	Permissions         []permission           `xml:"uses-permission"`
+	PermissionsSDK23    []permission           `xml:"uses-permission-sdk-23"` // only requested on API 23+
+	SDK                 apkUsesSDK             `xml:"uses-sdk"`
+	DeclaredPermissions []apkPermission        `xml:"permission"` // permissions the app defines
+	Features            []apkUsesFeature       `xml:"uses-feature"`
+	Configurations      []apkUsesConfiguration `xml:"uses-configuration"`
+	SupportsScreens     *apkSupportsScreens    `xml:"supports-screens"`
+	CompatibleScreens   []apkCompatibleScreen  `xml:"compatible-screens>screen"`
+	GLTextures          []apkSupportsGLTexture `xml:"supports-gl-texture"`
 }
 
-type permission struct {
+// apkUsesFeature https://developer.android.com/guide/topics/manifest/uses-feature-element
+type apkUsesFeature struct {
+	Name        string `xml:"name,attr"`
+	Required    *bool  `xml:"required,attr"`
+	GLESVersion string `xml:"glEsVersion,attr"`
+}
+
+// apkUsesConfiguration https://developer.android.com/guide/topics/manifest/uses-configuration-element
+type apkUsesConfiguration struct {
+	FiveWayNav   bool   `xml:"reqFiveWayNav,attr"`
+	HardKeyboard bool   `xml:"reqHardKeyboard,attr"`
+	KeyboardType string `xml:"reqKeyboardType,attr"`
+	Navigation   string `xml:"reqNavigation,attr"`
+	TouchScreen  string `xml:"reqTouchScreen,attr"`
+}
+
+// apkSupportsScreens https://developer.android.com/guide/topics/manifest/supports-screens-element
+type apkSupportsScreens struct {
+	Resizeable              *bool `xml:"resizeable,attr"`
+	SmallScreens            *bool `xml:"smallScreens,attr"`
+	NormalScreens           *bool `xml:"normalScreens,attr"`
+	LargeScreens            *bool `xml:"largeScreens,attr"`
+	XLargeScreens           *bool `xml:"xlargeScreens,attr"`
+	AnyDensity              *bool `xml:"anyDensity,attr"`
+	RequiresSmallestWidthDp int   `xml:"requiresSmallestWidthDp,attr"`
+	CompatibleWidthLimitDp  int   `xml:"compatibleWidthLimitDp,attr"`
+	LargestWidthLimitDp     int   `xml:"largestWidthLimitDp,attr"`
+}
+
+// apkCompatibleScreen is a screen of <compatible-screens>.
+type apkCompatibleScreen struct {
+	ScreenSize    string `xml:"screenSize,attr"`
+	ScreenDensity string `xml:"screenDensity,attr"`
+}
+
+// apkSupportsGLTexture https://developer.android.com/guide/topics/manifest/supports-gl-texture-element
+type apkSupportsGLTexture struct {
 	Name string `xml:"name,attr"`
 }
+
+// apkUsesLibrary https://developer.android.com/guide/topics/manifest/uses-library-element
+type apkUsesLibrary struct {
+	Name     string `xml:"name,attr"`
+	Required *bool  `xml:"required,attr"`
+}
+
+// apkPermission https://developer.android.com/guide/topics/manifest/permission-element
+type apkPermission struct {
+	Name            string `xml:"name,attr"`
+	PermissionGroup string `xml:"permissionGroup,attr"`
+	ProtectionLevel string `xml:"protectionLevel,attr"`
+}
+
+// permission https://developer.android.com/guide/topics/manifest/uses-permission-element
+type permission struct {
+	Name                string `xml:"name,attr"`
+	MaxSdkVersion       int    `xml:"maxSdkVersion,attr"`
+	UsesPermissionFlags string `xml:"usesPermissionFlags,attr"`
+}
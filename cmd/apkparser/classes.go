@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/zclwy/apkparser"
+)
+
+// runClasses lists the classes of the DEX files of an APK.
+func runClasses(args []string) error {
+	fs := flag.NewFlagSet("classes", flag.ExitOnError)
+	filter := fs.String("filter", "", "only list classes whose name starts with `prefix`, e.g. com.example")
+	members := fs.Bool("members", false, "also list fields and methods")
+	asJSON := fs.Bool("json", false, "output JSON, one class per line")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: apkparser classes [flags] <apk-file> [flags]")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	// flags may also follow the APK file.
+	apkFile := fs.Arg(0)
+	_ = fs.Parse(fs.Args()[1:])
+	if fs.NArg() != 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	it, err := apkparser.ReadDexClasses(apkFile)
+	if err != nil {
+		return fmt.Errorf("failed to parse APK: %v", err)
+	}
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	enc := json.NewEncoder(w)
+	for it.Next() {
+		c := it.Class()
+		if !strings.HasPrefix(c.Name, *filter) {
+			continue
+		}
+		if *asJSON {
+			if err := enc.Encode(c); err != nil {
+				return err
+			}
+			continue
+		}
+		fmt.Fprintln(w, formatClass(c))
+		if !*members {
+			continue
+		}
+		for _, f := range c.Fields {
+			fmt.Fprintf(w, "    .field %s%s:%s\n", formatFlags(f.AccessFlags), f.Name, f.Type)
+		}
+		for _, m := range c.Methods {
+			fmt.Fprintf(w, "    .method %s%s%s\n", formatFlags(m.AccessFlags), m.Name, m.Proto.Descriptor())
+		}
+	}
+	return it.Err()
+}
+
+// formatClass formats the declaration of a class, e.g. public final class
+// com.example.Main extends android.app.Activity implements java.lang.Runnable.
+func formatClass(c *apkparser.DexClass) string {
+	var b strings.Builder
+	flags := c.AccessFlags
+	kind := "class"
+	switch {
+	case flags&apkparser.AccAnnotation != 0:
+		kind = "@interface"
+		flags &^= apkparser.AccAnnotation | apkparser.AccInterface | apkparser.AccAbstract
+	case flags&apkparser.AccInterface != 0:
+		kind = "interface"
+		flags &^= apkparser.AccInterface | apkparser.AccAbstract
+	case flags&apkparser.AccEnum != 0:
+		kind = "enum"
+		flags &^= apkparser.AccEnum
+	}
+	fmt.Fprintf(&b, "%s%s %s", formatFlags(flags), kind, c.Name)
+	if c.Superclass != "" && c.Superclass != "java.lang.Object" {
+		fmt.Fprintf(&b, " extends %s", c.Superclass)
+	}
+	if len(c.Interfaces) > 0 {
+		keyword := "implements"
+		if c.AccessFlags&apkparser.AccInterface != 0 {
+			keyword = "extends"
+		}
+		fmt.Fprintf(&b, " %s %s", keyword, strings.Join(c.Interfaces, ", "))
+	}
+	var source []string
+	if c.SourceFile != "" {
+		source = append(source, c.SourceFile)
+	}
+	source = append(source, c.Dex)
+	fmt.Fprintf(&b, " (%s)", strings.Join(source, ", "))
+	return b.String()
+}
+
+// formatFlags formats access flags followed by a space, if any.
+func formatFlags(flags apkparser.AccessFlags) string {
+	if s := flags.String(); s != "" {
+		return s + " "
+	}
+	return ""
+}
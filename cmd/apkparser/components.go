@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/zclwy/apkparser"
+)
+
+// runComponents prints the attack surface of an APK: its components, how
+// they are exported and the permissions guarding them.
+func runComponents(args []string) error {
+	fs := flag.NewFlagSet("components", flag.ExitOnError)
+	all := fs.Bool("all", false, "also list components that aren't exported")
+	asJSON := fs.Bool("json", false, "output JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: apkparser components [flags] <apk-file>")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	apk, err := apkparser.Open(fs.Arg(0), apkparser.Option{})
+	if err != nil {
+		return fmt.Errorf("failed to open APK: %v", err)
+	}
+	defer apk.Close()
+	attackSurface, err := apk.AttackSurface()
+	if err != nil {
+		return fmt.Errorf("failed to parse APK: %v", err)
+	}
+	surface := *attackSurface
+	if !*all {
+		surface.Components = surface.Exported()
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(surface)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "KIND\tNAME\tEXPORTED\tPERMISSION")
+	for _, c := range surface.Components {
+		exported := fmt.Sprintf("%v (%s)", c.Exported, c.ExportedSource)
+		var permissions []string
+		if c.Kind == apkparser.ComponentProvider {
+			permissions = append(permissions, "read="+formatGuard(c.ReadPermission), "write="+formatGuard(c.WritePermission))
+		} else {
+			permissions = append(permissions, formatGuard(c.Permission))
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", c.Kind, c.Name, exported, strings.Join(permissions, " "))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if len(surface.Findings) > 0 {
+		fmt.Println()
+		fmt.Println("Findings:")
+	}
+	for _, f := range surface.Findings {
+		fmt.Printf("  [%s] %s: %s\n", f.Rule, f.Target, f.Message)
+	}
+	return nil
+}
+
+// formatGuard formats a guarding permission with its protection level.
+func formatGuard(g *apkparser.PermissionGuard) string {
+	switch {
+	case g == nil:
+		return "-"
+	case g.ProtectionLevel == "":
+		return g.Name
+	}
+	return g.Name + " (" + g.ProtectionLevel + ")"
+}
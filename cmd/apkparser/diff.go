@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/zclwy/apkparser"
+)
+
+// runDiff compares two builds of an app.
+func runDiff(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	sections := fs.String("sections", "", "only compare these comma separated `sections`, e.g. manifest,permission")
+	asJSON := fs.Bool("json", false, "output JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: apkparser diff [flags] <old-apk-file> <new-apk-file>")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	diff, err := apkparser.Diff(fs.Arg(0), fs.Arg(1))
+	if err != nil {
+		return fmt.Errorf("failed to compare APKs: %v", err)
+	}
+	if *sections != "" {
+		var changes []apkparser.Change
+		for _, section := range strings.Split(*sections, ",") {
+			changes = append(changes, diff.Section(strings.TrimSpace(section))...)
+		}
+		diff.Changes = changes
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(diff)
+	}
+	return diff.WriteText(os.Stdout)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/zclwy/apkparser"
+)
+
+// runLinks lists the deep links an APK handles, grouped by activity.
+func runLinks(args []string) error {
+	fs := flag.NewFlagSet("links", flag.ExitOnError)
+	appLinks := fs.Bool("applinks", false, "only list verified App Links")
+	asJSON := fs.Bool("json", false, "output JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: apkparser links [flags] <apk-file>")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	apk, err := apkparser.Open(fs.Arg(0), apkparser.Option{})
+	if err != nil {
+		return fmt.Errorf("failed to open APK: %v", err)
+	}
+	defer apk.Close()
+	deepLinks, err := apk.DeepLinks()
+	if err != nil {
+		return fmt.Errorf("failed to parse APK: %v", err)
+	}
+	links := []apkparser.DeepLink{}
+	for _, link := range deepLinks {
+		if *appLinks && !link.AppLink {
+			continue
+		}
+		links = append(links, link)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(links)
+	}
+	var activity string
+	for _, link := range links {
+		if link.Activity != activity {
+			activity = link.Activity
+			if link.TargetActivity != "" {
+				fmt.Printf("%s -> %s\n", activity, link.TargetActivity)
+			} else {
+				fmt.Println(activity)
+			}
+		}
+		fmt.Printf("  %s%s\n", link.URI(), linkFlags(&link))
+	}
+	return nil
+}
+
+// linkFlags describes the App Link verification and the categories of link.
+func linkFlags(link *apkparser.DeepLink) string {
+	var flags []string
+	if link.AppLink {
+		flags = append(flags, "app-link")
+	} else if link.AutoVerify {
+		flags = append(flags, "auto-verify")
+	}
+	if link.Browsable {
+		flags = append(flags, "browsable")
+	}
+	if link.Default {
+		flags = append(flags, "default")
+	}
+	if len(flags) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(flags, ", ") + ")"
+}
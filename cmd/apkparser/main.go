@@ -8,20 +8,58 @@ import (
 	"github.com/zclwy/apkparser"
 )
 
+const usage = `Usage: apkparser <apk-file>
+       apkparser resources [-type type] [-name glob] [-config qualifiers] [-json] <apk-file>
+       apkparser manifest [-refs id|name|value] <apk-file>
+       apkparser xml [-refs id|name|value] [-json] <apk-file> <path|@type/name|@0xID>
+       apkparser links [-applinks] [-json] <apk-file>
+       apkparser components [-all] [-json] <apk-file>
+       apkparser classes <apk-file> [-filter prefix] [-members] [-json]
+       apkparser size [-depth n] [-max-size bytes] [-json] <apk-file>
+       apkparser diff [-sections list] [-json] <old-apk-file> <new-apk-file>`
+
 func main() {
 	if len(os.Args) < 2 {
-		fmt.Println("Usage: apkparser <apk-file>")
+		fmt.Println(usage)
 		os.Exit(1)
 	}
 
-	apkFile := os.Args[1]
+	var err error
+	switch os.Args[1] {
+	case "resources":
+		err = runResources(os.Args[2:])
+	case "manifest":
+		err = runManifest(os.Args[2:])
+	case "xml":
+		err = runXML(os.Args[2:])
+	case "links":
+		err = runLinks(os.Args[2:])
+	case "components":
+		err = runComponents(os.Args[2:])
+	case "classes":
+		err = runClasses(os.Args[2:])
+	case "size":
+		err = runSize(os.Args[2:])
+	case "diff":
+		err = runDiff(os.Args[2:])
+	default:
+		err = runInfo(os.Args[1])
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func runInfo(apkFile string) error {
 	app, err := apkparser.New(apkFile, apkparser.Option{
 		WithIcon:             true,
 		WithSignature:        true,
 		IgnoreSignatureError: true,
+		Tolerant:             true,
+		BestEffort:           true,
 	})
 	if err != nil {
-		log.Fatalf("Failed to parse APK: %v", err)
+		return fmt.Errorf("failed to parse APK: %v", err)
 	}
 
 	fmt.Printf("Name: %v\n", app.Name)
@@ -29,5 +67,11 @@ func main() {
 	fmt.Printf("Version: %v\n", app.Version)
 	fmt.Printf("Build: %v\n", app.Build)
 	fmt.Printf("Md5: %v\n", app.Md5)
-	fmt.Printf("Signature md5: %v\n", app.CertInfo.Md5)
+	if app.CertInfo != nil {
+		fmt.Printf("Signature md5: %v\n", app.CertInfo.Md5)
+	}
+	for _, w := range app.Warnings {
+		fmt.Printf("Warning: %v\n", w)
+	}
+	return nil
 }
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/zclwy/apkparser"
+)
+
+// referenceModes are the values of the -refs flag.
+var referenceModes = map[string]apkparser.ReferenceMode{
+	"id":    apkparser.ReferenceID,
+	"name":  apkparser.ReferenceName,
+	"value": apkparser.ReferenceValue,
+}
+
+// runManifest prints the decoded AndroidManifest.xml of an APK.
+func runManifest(args []string) error {
+	fs := flag.NewFlagSet("manifest", flag.ExitOnError)
+	refs := fs.String("refs", "name", "write references to resources as `id`, name or value")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: apkparser manifest [flags] <apk-file>")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	mode, ok := referenceModes[*refs]
+	if !ok {
+		return fmt.Errorf("invalid -refs %q", *refs)
+	}
+
+	err := apkparser.DecodeManifest(fs.Arg(0), os.Stdout, apkparser.XMLEncodeOption{
+		Indent:     "    ",
+		References: mode,
+		Config:     &apkparser.ResTableConfig{},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to parse APK: %v", err)
+	}
+	return nil
+}
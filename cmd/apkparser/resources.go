@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+
+	"github.com/zclwy/apkparser"
+)
+
+// defaultQualifiers selects the default configuration in the -config filter.
+const defaultQualifiers = "default"
+
+// runResources lists the resources of an APK, like `aapt2 dump resources`.
+func runResources(args []string) error {
+	fs := flag.NewFlagSet("resources", flag.ExitOnError)
+	typ := fs.String("type", "", "only list resources of this `type`, e.g. string or drawable")
+	name := fs.String("name", "", "only list resources whose name matches this `glob`")
+	config := fs.String("config", "", "only list values for these `qualifiers`, e.g. fr-rCA or \""+defaultQualifiers+"\"")
+	asJSON := fs.Bool("json", false, "output JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: apkparser resources [flags] <apk-file>")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	if _, err := path.Match(*name, ""); err != nil {
+		return fmt.Errorf("invalid -name: %v", err)
+	}
+
+	table, err := apkparser.ReadTable(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed to parse APK: %v", err)
+	}
+
+	var resources []apkparser.Resource
+	for _, res := range table.Resources() {
+		if *typ != "" && res.Type != *typ {
+			continue
+		}
+		if ok, _ := path.Match(*name, res.Name); *name != "" && !ok {
+			continue
+		}
+		if *config != "" {
+			var values []apkparser.ResourceValue
+			for _, v := range res.Values {
+				if v.Qualifiers == *config || v.Qualifiers == "" && *config == defaultQualifiers {
+					values = append(values, v)
+				}
+			}
+			if len(values) == 0 {
+				continue
+			}
+			res.Values = values
+		}
+		resources = append(resources, res)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(resources)
+	}
+	printResources(resources)
+	return nil
+}
+
+// printResources prints resources grouped by package and type.
+func printResources(resources []apkparser.Resource) {
+	var pkg, typ string
+	for _, res := range resources {
+		if res.Package != pkg {
+			pkg, typ = res.Package, ""
+			fmt.Printf("Package name=%s id=%02x\n", pkg, res.ID.Package())
+		}
+		if res.Type != typ {
+			typ = res.Type
+			fmt.Printf("  type %s id=%02x\n", typ, res.ID.Type())
+		}
+		fmt.Printf("    resource 0x%08x %s/%s\n", uint32(res.ID), res.Type, res.Name)
+		for _, v := range res.Values {
+			if v.Items == nil {
+				fmt.Printf("      (%s) %s\n", v.Qualifiers, formatValue(res.Type, v.Value))
+				continue
+			}
+			fmt.Printf("      (%s) {", v.Qualifiers)
+			if v.Parent != "" {
+				fmt.Printf(" parent=%s", v.Parent)
+			}
+			fmt.Println()
+			for _, item := range v.Items {
+				fmt.Printf("        %s=%s\n", item.Name, item.Value)
+			}
+			fmt.Println("      }")
+		}
+	}
+}
+
+// formatValue quotes string values so that empty and padded strings are visible.
+func formatValue(typ, value string) string {
+	if typ == "string" {
+		return strconv.Quote(value)
+	}
+	return value
+}
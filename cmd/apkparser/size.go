@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/zclwy/apkparser"
+)
+
+// runSize prints the size breakdown of an APK: by category, and as a tree of
+// its directories and files.
+func runSize(args []string) error {
+	fs := flag.NewFlagSet("size", flag.ExitOnError)
+	depth := fs.Int("depth", 2, "levels of directories of the tree to print, 0 for all")
+	maxSize := fs.Int64("max-size", 0, "fail if the APK is larger than `bytes`")
+	asJSON := fs.Bool("json", false, "output JSON, with every entry")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: apkparser size [flags] <apk-file>")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	report, err := apkparser.ReadSizeReport(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed to parse APK: %v", err)
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+	} else if err := printSizes(os.Stdout, report, *depth); err != nil {
+		return err
+	}
+	if *maxSize > 0 && report.Size > *maxSize {
+		return fmt.Errorf("APK size %d bytes exceeds the maximum of %d bytes", report.Size, *maxSize)
+	}
+	return nil
+}
+
+// sizeNode is a directory or file of the size tree.
+type sizeNode struct {
+	apkparser.SizeGroup
+	children map[string]*sizeNode
+}
+
+func printSizes(w io.Writer, report *apkparser.SizeReport, depth int) error {
+	fmt.Fprintf(w, "APK size: %s (%d bytes)\n\n", formatSize(uint64(report.Size)), report.Size)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "CATEGORY\tFILES\tCOMPRESSED\tUNCOMPRESSED\t%")
+	groups := make([]apkparser.SizeGroup, 0, len(report.Categories)+1)
+	groups = append(groups, report.Categories...)
+	for _, g := range append(groups, report.Total) {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%.1f\n", g.Name, g.Files, formatSize(g.CompressedSize),
+			formatSize(g.UncompressedSize), percent(g.CompressedSize, report.Total.CompressedSize))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintln(w)
+
+	root := &sizeNode{children: make(map[string]*sizeNode)}
+	for _, e := range report.Entries {
+		node := root
+		parts := strings.Split(e.Name, "/")
+		for i, part := range parts {
+			if i < len(parts)-1 {
+				part += "/"
+			}
+			child, ok := node.children[part]
+			if !ok {
+				child = &sizeNode{SizeGroup: apkparser.SizeGroup{Name: part}, children: make(map[string]*sizeNode)}
+				node.children[part] = child
+			}
+			child.Files++
+			child.CompressedSize += e.CompressedSize
+			child.UncompressedSize += e.UncompressedSize
+			node = child
+		}
+	}
+	tw = tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "PATH\tFILES\tCOMPRESSED\tUNCOMPRESSED\t%")
+	printNode(tw, root, 0, depth, report.Total.CompressedSize)
+	return tw.Flush()
+}
+
+// printNode prints the children of node, the largest first, down to depth
+// levels of directories.
+func printNode(w io.Writer, node *sizeNode, level, depth int, total uint64) {
+	children := make([]*sizeNode, 0, len(node.children))
+	for _, child := range node.children {
+		children = append(children, child)
+	}
+	sort.Slice(children, func(i, j int) bool {
+		if children[i].CompressedSize != children[j].CompressedSize {
+			return children[i].CompressedSize > children[j].CompressedSize
+		}
+		return children[i].Name < children[j].Name
+	})
+	for _, child := range children {
+		fmt.Fprintf(w, "%s%s\t%d\t%s\t%s\t%.1f\n", strings.Repeat("  ", level), child.Name, child.Files,
+			formatSize(child.CompressedSize), formatSize(child.UncompressedSize), percent(child.CompressedSize, total))
+		if depth == 0 || level+1 < depth {
+			printNode(w, child, level+1, depth, total)
+		}
+	}
+}
+
+// formatSize formats a size in bytes with binary units, e.g. 1.5 MiB.
+func formatSize(size uint64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := uint64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGT"[exp])
+}
+
+func percent(n, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(n) / float64(total)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/zclwy/apkparser"
+)
+
+// runXML prints a compiled XML file of an APK, selected by zip path or by
+// resource name or ID.
+func runXML(args []string) error {
+	fs := flag.NewFlagSet("xml", flag.ExitOnError)
+	refs := fs.String("refs", "name", "write references to resources as `id`, name or value")
+	asJSON := fs.Bool("json", false, "output the element tree as JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: apkparser xml [flags] <apk-file> <path|@type/name|@0xID>")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	mode, ok := referenceModes[*refs]
+	if !ok {
+		return fmt.Errorf("invalid -refs %q", *refs)
+	}
+	apkFile, path := fs.Arg(0), fs.Arg(1)
+	config := &apkparser.ResTableConfig{}
+
+	var xmlFile *apkparser.XMLFile
+	var err error
+	if strings.HasPrefix(path, "@") {
+		var id apkparser.ResID
+		if id, err = resolveResID(apkFile, path); err != nil {
+			return err
+		}
+		xmlFile, err = apkparser.ReadXMLResource(apkFile, id, config)
+	} else {
+		xmlFile, err = apkparser.ReadXML(apkFile, path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse APK: %v", err)
+	}
+
+	option := apkparser.XMLEncodeOption{
+		Indent:     "    ",
+		References: mode,
+		Config:     config,
+	}
+	if *asJSON {
+		root := xmlFile.Root()
+		substituteReferences(xmlFile, root, option)
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(root)
+	}
+	return xmlFile.Encode(os.Stdout, option)
+}
+
+// resolveResID parses a resource ID, @0x7F010000, or looks up a resource
+// name, @xml/file_paths.
+func resolveResID(apkFile, ref string) (apkparser.ResID, error) {
+	if apkparser.IsResID(ref) {
+		return apkparser.ParseResID(ref)
+	}
+	table, err := apkparser.ReadTable(apkFile)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse APK: %v", err)
+	}
+	return table.LookupID(ref)
+}
+
+// substituteReferences replaces the references in the attributes of elem
+// and its descendants according to option.
+func substituteReferences(xmlFile *apkparser.XMLFile, elem *apkparser.XMLElement, option apkparser.XMLEncodeOption) {
+	if elem == nil {
+		return
+	}
+	for i, attr := range elem.Attrs {
+		elem.Attrs[i].Value = xmlFile.AttrValue(attr, option)
+	}
+	for _, c := range elem.Elements("") {
+		substituteReferences(xmlFile, c, option)
+	}
+}
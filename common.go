@@ -3,8 +3,10 @@ package apkparser
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
 	"io"
 	"unicode/utf16"
+	"unsafe"
 )
 
 // ChunkType is type of resource chunk.
@@ -114,16 +116,64 @@ type ResValue struct {
 	Data     uint32
 }
 
-// GetString returns a string referenced by ref.
+// GetString returns a string referenced by ref, or an empty string if ref
+// is out of range.
 func (pool *ResStringPool) GetString(ref ResStringPoolRef) string {
-	return pool.Strings[int(ref)]
+	s, _ := pool.getString(ref)
+	return s
 }
 
-func readStringPool(sr *io.SectionReader) (*ResStringPool, error) {
+// getString returns a string referenced by ref, or an error if ref is out
+// of range.
+func (pool *ResStringPool) getString(ref ResStringPoolRef) (string, error) {
+	if pool == nil {
+		return "", fmt.Errorf("apkparser: string %d referenced without a string pool", ref)
+	}
+	if uint64(ref) >= uint64(len(pool.Strings)) {
+		return "", fmt.Errorf("apkparser: string %d out of range of %d strings", ref, len(pool.Strings))
+	}
+	return pool.Strings[ref], nil
+}
+
+// readChunkHeader reads the header of the chunk at offset of r, which has
+// size bytes, and returns it with the section of the chunk. The chunk must
+// fit in r, or else a ChunkError is returned.
+func readChunkHeader(r io.ReaderAt, offset, size int64) (*ResChunkHeader, *io.SectionReader, error) {
+	header := new(ResChunkHeader)
+	if err := binary.Read(io.NewSectionReader(r, offset, size-offset), binary.LittleEndian, header); err != nil {
+		return nil, nil, &ChunkError{Type: header.Type, Offset: offset, Err: err}
+	}
+	if int64(header.Size) < int64(unsafe.Sizeof(*header)) || int64(header.Size) > size-offset ||
+		uint32(header.HeaderSize) > header.Size {
+		err := fmt.Errorf("apkparser: invalid size %d with header of %d bytes", header.Size, header.HeaderSize)
+		return nil, nil, &ChunkError{Type: header.Type, Offset: offset, Err: err}
+	}
+	return header, io.NewSectionReader(r, offset, int64(header.Size)), nil
+}
+
+// readStringPool reads the string pool chunk sr, of at most maxStrings
+// strings and styles.
+func readStringPool(sr *io.SectionReader, maxStrings int) (*ResStringPool, error) {
 	sp := new(ResStringPool)
 	if err := binary.Read(sr, binary.LittleEndian, &sp.Header); err != nil {
 		return nil, err
 	}
+	count := int64(sp.Header.StringCount) + int64(sp.Header.StyleCount)
+	if exceeds(count, int64(maxStrings)) {
+		return nil, &LimitError{Limit: "MaxStringCount", Max: int64(maxStrings)}
+	}
+	size := int64(sp.Header.Header.Size)
+	if sr.Size() < size {
+		size = sr.Size()
+	}
+	// the offsets of the strings and styles follow the header.
+	if int64(unsafe.Sizeof(sp.Header))+4*count > size {
+		return nil, fmt.Errorf("apkparser: %d strings and styles exceed the string pool of %d bytes", count, size)
+	}
+	sr = io.NewSectionReader(sr, 0, size)
+	if _, err := sr.Seek(int64(unsafe.Sizeof(sp.Header)), seekStart); err != nil {
+		return nil, err
+	}
 
 	stringStarts := make([]uint32, sp.Header.StringCount)
 	if err := binary.Read(sr, binary.LittleEndian, stringStarts); err != nil {
@@ -139,7 +189,7 @@ func readStringPool(sr *io.SectionReader) (*ResStringPool, error) {
 	for i, start := range stringStarts {
 		var str string
 		var err error
-		if _, err := sr.Seek(int64(sp.Header.StringStart+start), seekStart); err != nil {
+		if _, err := sr.Seek(int64(sp.Header.StringStart)+int64(start), seekStart); err != nil {
 			return nil, err
 		}
 		if (sp.Header.Flags & UTF8Flag) == 0 {
@@ -155,7 +205,7 @@ func readStringPool(sr *io.SectionReader) (*ResStringPool, error) {
 
 	sp.Styles = make([]ResStringPoolSpan, sp.Header.StyleCount)
 	for i, start := range styleStarts {
-		if _, err := sr.Seek(int64(sp.Header.StylesStart+start), seekStart); err != nil {
+		if _, err := sr.Seek(int64(sp.Header.StylesStart)+int64(start), seekStart); err != nil {
 			return nil, err
 		}
 		if err := binary.Read(sr, binary.LittleEndian, &sp.Styles[i]); err != nil {
@@ -174,6 +224,9 @@ func readUTF16(sr *io.SectionReader) (string, error) {
 	}
 
 	// read string value
+	if err := checkRemaining(sr, 2*int64(size)); err != nil {
+		return "", err
+	}
 	buf := make([]uint16, size)
 	if err := binary.Read(sr, binary.LittleEndian, buf); err != nil {
 		return "", err
@@ -211,6 +264,9 @@ func readUTF8(sr *io.SectionReader) (string, error) {
 		return "", err
 	}
 
+	if err := checkRemaining(sr, int64(size)); err != nil {
+		return "", err
+	}
 	buf := make([]uint8, size)
 	if err := binary.Read(sr, binary.LittleEndian, buf); err != nil {
 		return "", err
@@ -235,6 +291,19 @@ func readUTF8length(sr *io.SectionReader) (int, error) {
 	return size, nil
 }
 
+// checkRemaining returns an error if sr has less than n bytes left to read,
+// before they are allocated.
+func checkRemaining(sr *io.SectionReader, n int64) error {
+	pos, err := sr.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if n > sr.Size()-pos {
+		return fmt.Errorf("apkparser: %d bytes at %d exceed the chunk of %d bytes: %w", n, pos, sr.Size(), io.ErrUnexpectedEOF)
+	}
+	return nil
+}
+
 func newZeroFilledReader(r io.Reader, actual int64, expected int64) (io.Reader, error) {
 	if actual >= expected {
 		// no need to fill
@@ -0,0 +1,27 @@
+package apkparser
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func FuzzStringPool(f *testing.F) {
+	var pool stringPool
+	for _, s := range []string{"", "android", "http://schemas.android.com/apk/res/android", "Seulement en français"} {
+		pool.ref(s)
+	}
+	f.Add(pool.encode())
+	f.Add(new(stringPool).encode())
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		sp, err := readStringPool(io.NewSectionReader(bytes.NewReader(data), 0, int64(len(data))), DefaultLimits.MaxStringCount)
+		if err != nil {
+			return
+		}
+		for i := range sp.Strings {
+			_ = sp.GetString(ResStringPoolRef(i))
+		}
+		_ = sp.GetString(NilResStringPoolRef)
+	})
+}
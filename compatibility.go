@@ -0,0 +1,147 @@
+package apkparser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Rules of the compatibility findings.
+const (
+	RuleSDKVersion       = "sdk-version"
+	RuleABI              = "abi"
+	RuleFeature          = "feature"
+	RuleGLESVersion      = "gl-es-version"
+	RuleGLTexture        = "gl-texture"
+	RuleScreenSize       = "screen-size"
+	RuleCompatibleScreen = "compatible-screens"
+	RuleSharedLibrary    = "shared-library"
+)
+
+// screenSizeOrder are the screen sizes, from the smallest.
+var screenSizeOrder = []string{"small", "normal", "large", "xlarge"}
+
+// Device describes a device to check the compatibility of an APK with.
+// Zero values of the optional fields skip the corresponding checks.
+type Device struct {
+	SDKVersion int      // API level
+	ABIs       []string // supported ABIs, e.g. arm64-v8a, armeabi-v7a
+
+	ScreenSize    string // optional: small, normal, large or xlarge
+	ScreenDensity int    // optional: dpi
+
+	Features        []string    // system features, e.g. android.hardware.camera
+	GLESVersion     GLESVersion // optional: OpenGL ES version
+	GLTextures      []string    // optional: supported texture compression formats
+	SharedLibraries []string    // shared libraries, e.g. org.apache.http.legacy
+}
+
+// Compatibility is the result of checking an APK against a device.
+type Compatibility struct {
+	Compatible bool      `json:"compatible"`
+	Reasons    []Finding `json:"reasons,omitempty"` // why the APK is incompatible
+}
+
+// CheckCompatibility returns whether the APK would install and run on
+// device, filtering like Google Play does. The feature requirements are
+// only checked if the AppInfo has them.
+func (info *AppInfo) CheckCompatibility(device Device) Compatibility {
+	var c Compatibility
+	fail := func(rule, target, format string, args ...interface{}) {
+		c.Reasons = append(c.Reasons, Finding{Rule: rule, Target: target, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if info.MinSdkVersion > device.SDKVersion {
+		fail(RuleSDKVersion, "", "requires API level %d or higher, the device has %d", info.MinSdkVersion, device.SDKVersion)
+	}
+	if info.MaxSdkVersion > 0 && info.MaxSdkVersion < device.SDKVersion {
+		fail(RuleSDKVersion, "", "supports API level %d or lower, the device has %d", info.MaxSdkVersion, device.SDKVersion)
+	}
+	if len(info.ABIs) > 0 && !containsAny(info.ABIs, device.ABIs) {
+		fail(RuleABI, "", "native code for %s, the device supports %s",
+			strings.Join(info.ABIs, ", "), strings.Join(device.ABIs, ", "))
+	}
+
+	if req := info.Features; req != nil {
+		req.check(&device, fail)
+	}
+
+	c.Compatible = len(c.Reasons) == 0
+	return c
+}
+
+// check checks the feature requirements against device, calling fail for
+// each one the device doesn't meet.
+func (req *FeatureRequirements) check(device *Device, fail func(rule, target, format string, args ...interface{})) {
+	for _, f := range req.Features {
+		if !f.Required || device.hasFeature(f.Name) {
+			continue
+		}
+		if f.Implied {
+			fail(RuleFeature, f.Name, "requires feature %s (implied: %s)", f.Name, f.Reason)
+		} else {
+			fail(RuleFeature, f.Name, "requires feature %s", f.Name)
+		}
+	}
+	if device.GLESVersion != 0 && device.GLESVersion < req.GLESVersion {
+		fail(RuleGLESVersion, "", "requires OpenGL ES %s, the device has %s", req.GLESVersion, device.GLESVersion)
+	}
+	if device.GLTextures != nil && len(req.GLTextures) > 0 && !containsAny(req.GLTextures, device.GLTextures) {
+		fail(RuleGLTexture, "", "requires one of the texture formats %s", strings.Join(req.GLTextures, ", "))
+	}
+	for _, l := range req.Libraries {
+		if l.Required && !containsString(device.SharedLibraries, l.Name) {
+			fail(RuleSharedLibrary, l.Name, "requires shared library %s", l.Name)
+		}
+	}
+
+	size := -1
+	for i, s := range screenSizeOrder {
+		if s == device.ScreenSize {
+			size = i
+		}
+	}
+	if size < 0 {
+		return
+	}
+	// apps run in compatibility mode on screens larger than they support,
+	// but not on smaller ones.
+	supported := []bool{req.SupportsScreens.Small, req.SupportsScreens.Normal, req.SupportsScreens.Large, req.SupportsScreens.XLarge}
+	smallest := 0
+	for smallest < len(supported) && !supported[smallest] {
+		smallest++
+	}
+	if smallest > size {
+		fail(RuleScreenSize, device.ScreenSize, "doesn't support %s screens", device.ScreenSize)
+	}
+	if len(req.CompatibleScreens) > 0 && device.ScreenDensity != 0 {
+		compatible := false
+		for _, s := range req.CompatibleScreens {
+			compatible = compatible || s.Size == device.ScreenSize && s.Density == device.ScreenDensity
+		}
+		if !compatible {
+			fail(RuleCompatibleScreen, "", "isn't compatible with %s screens at %d dpi", device.ScreenSize, device.ScreenDensity)
+		}
+	}
+}
+
+// hasFeature returns whether the device has the feature name. A touchscreen
+// provides the faketouch features.
+func (device *Device) hasFeature(name string) bool {
+	if containsString(device.Features, name) {
+		return true
+	}
+	if strings.HasPrefix(name, "android.hardware.faketouch") {
+		return containsString(device.Features, "android.hardware.touchscreen")
+	}
+	return false
+}
+
+// containsAny returns whether a and b have an element in common.
+func containsAny(a, b []string) bool {
+	for _, s := range b {
+		if containsString(a, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package apkparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCheckCompatibility(t *testing.T) {
+	manifest := testManifest(
+		&xmlElement{Name: "uses-sdk", Attrs: []xmlAttr{
+			{NS: androidNamespace, Name: "minSdkVersion", Type: TypeIntDec, Data: 24},
+			{NS: androidNamespace, Name: "targetSdkVersion", Type: TypeIntDec, Data: 33},
+		}},
+		&xmlElement{Name: "uses-permission", Attrs: []xmlAttr{{NS: androidNamespace, Name: "name", Value: "android.permission.CAMERA"}}},
+		&xmlElement{Name: "uses-feature", Attrs: []xmlAttr{{NS: androidNamespace, Name: "glEsVersion", Type: TypeIntHex, Data: 0x00030000}}},
+		&xmlElement{Name: "supports-screens", Attrs: []xmlAttr{{NS: androidNamespace, Name: "smallScreens", Type: TypeIntBoolean}}},
+		&xmlElement{Name: "application", Children: []*xmlElement{
+			{Name: "uses-library", Attrs: []xmlAttr{{NS: androidNamespace, Name: "name", Value: "com.google.android.maps"}}},
+		}},
+	)
+	name := writeAPK(t,
+		zipFile{"AndroidManifest.xml", encodeXML(manifest)},
+		zipFile{"resources.arsc", testTable()},
+		zipFile{"lib/arm64-v8a/libnative.so", []byte{0x7f, 'E', 'L', 'F'}},
+		zipFile{"lib/x86_64/libnative.so", []byte{0x7f, 'E', 'L', 'F'}},
+	)
+	info, err := New(name, Option{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"arm64-v8a", "x86_64"}; !reflect.DeepEqual(info.ABIs, want) {
+		t.Fatalf("ABIs = %q, want %q", info.ABIs, want)
+	}
+
+	phone := Device{
+		SDKVersion:      33,
+		ABIs:            []string{"arm64-v8a", "armeabi-v7a", "armeabi"},
+		ScreenSize:      "normal",
+		ScreenDensity:   420,
+		Features:        []string{"android.hardware.camera", "android.hardware.camera.autofocus", "android.hardware.touchscreen"},
+		GLESVersion:     0x00030002,
+		SharedLibraries: []string{"com.google.android.maps"},
+	}
+	if c := info.CheckCompatibility(phone); !c.Compatible {
+		t.Errorf("incompatible with a phone: %+v", c.Reasons)
+	}
+
+	old := Device{
+		SDKVersion:  23,
+		ABIs:        []string{"armeabi-v7a"},
+		ScreenSize:  "small",
+		Features:    []string{"android.hardware.faketouch"},
+		GLESVersion: 0x00020000,
+	}
+	c := info.CheckCompatibility(old)
+	var rules []string
+	for _, r := range c.Reasons {
+		rules = append(rules, r.Rule+" "+r.Target)
+	}
+	want := []string{
+		RuleSDKVersion + " ",
+		RuleABI + " ",
+		RuleFeature + " android.hardware.camera",
+		RuleFeature + " android.hardware.camera.autofocus",
+		RuleGLESVersion + " ",
+		RuleSharedLibrary + " com.google.android.maps",
+		RuleScreenSize + " small",
+	}
+	if c.Compatible || !reflect.DeepEqual(rules, want) {
+		t.Errorf("compatible = %v, reasons:\ngot  %q\nwant %q", c.Compatible, rules, want)
+	}
+}
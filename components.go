@@ -0,0 +1,213 @@
+package apkparser
+
+import (
+	"strings"
+)
+
+// sdkS is the platform version from which components with intent filters
+// must declare android:exported.
+const sdkS = 31
+
+// sdkJellyBeanMR1 is the platform version from which content providers are
+// no longer exported by default.
+const sdkJellyBeanMR1 = 17
+
+// Kinds of app components.
+const (
+	ComponentActivity      = "activity"
+	ComponentActivityAlias = "activity-alias"
+	ComponentService       = "service"
+	ComponentReceiver      = "receiver"
+	ComponentProvider      = "provider"
+)
+
+// How the exported value of a component is determined.
+const (
+	ExportedExplicit     = "explicit"      // android:exported is set
+	ExportedIntentFilter = "intent-filter" // implied by the intent filters of the component
+	ExportedDefault      = "default"       // the default of the component kind
+)
+
+// Rules of the attack surface findings.
+const (
+	RuleExportedUnprotected = "exported-unprotected"
+	RuleExportedImplicitly  = "exported-implicitly"
+	RuleMissingExported     = "missing-exported"
+	RuleWeakPermission      = "weak-permission"
+)
+
+// PermissionGuard is a permission an app must hold to use a component.
+type PermissionGuard struct {
+	Name string `json:"name"`
+	// ProtectionLevel is the protection level of the permission, e.g.
+	// signature|privileged, or "" if it is declared outside the APK.
+	ProtectionLevel string `json:"protectionLevel,omitempty"`
+}
+
+// Component is an activity, activity alias, service, broadcast receiver or
+// content provider of an app.
+type Component struct {
+	Kind           string           `json:"kind"`
+	Name           string           `json:"name"`
+	Exported       bool             `json:"exported"`
+	ExportedSource string           `json:"exportedSource"`
+	Actions        []string         `json:"actions,omitempty"` // actions of the intent filters
+	Permission     *PermissionGuard `json:"permission,omitempty"`
+
+	// provider only
+	Authorities         []string         `json:"authorities,omitempty"`
+	ReadPermission      *PermissionGuard `json:"readPermission,omitempty"`
+	WritePermission     *PermissionGuard `json:"writePermission,omitempty"`
+	GrantURIPermissions bool             `json:"grantUriPermissions,omitempty"`
+}
+
+// AttackSurface lists the components of an app, and the issues of the
+// exported ones.
+type AttackSurface struct {
+	Components []Component `json:"components"`
+	Findings   []Finding   `json:"findings,omitempty"`
+}
+
+// Exported returns the exported components.
+func (s *AttackSurface) Exported() []Component {
+	var exported []Component
+	for _, c := range s.Components {
+		if c.Exported {
+			exported = append(exported, c)
+		}
+	}
+	return exported
+}
+
+// attackSurface computes the attack surface of the APK from its manifest.
+func (k *apk) attackSurface() *AttackSurface {
+	app := &k.apkManifest.App
+	targetSdk := k.apkManifest.SDK.Target
+	if targetSdk == 0 {
+		targetSdk = k.apkManifest.SDK.Min
+	}
+	s := &AttackSurface{Components: []Component{}}
+	guard := func(names ...string) *PermissionGuard {
+		for _, name := range names {
+			if name != "" {
+				return &PermissionGuard{Name: name, ProtectionLevel: k.protectionLevel(name)}
+			}
+		}
+		return nil
+	}
+
+	activityPermissions := make(map[string]string)
+	for _, act := range app.Activities {
+		c := Component{Kind: ComponentActivity, Name: act.Name, Permission: guard(act.Permission, app.Permission)}
+		c.setExported(act.Exported, act.IntentFilters, false)
+		if c.Permission != nil {
+			activityPermissions[act.Name] = c.Permission.Name
+		}
+		s.add(c, act.IntentFilters, targetSdk)
+	}
+	for _, alias := range app.ActivityAliases {
+		c := Component{Kind: ComponentActivityAlias, Name: alias.Name, Permission: guard(alias.Permission, activityPermissions[alias.TargetActivity])}
+		c.setExported(alias.Exported, alias.IntentFilters, false)
+		s.add(c, alias.IntentFilters, targetSdk)
+	}
+	for _, svc := range app.Services {
+		c := Component{Kind: ComponentService, Name: svc.Name, Permission: guard(svc.Permission, app.Permission)}
+		c.setExported(svc.Exported, svc.IntentFilters, false)
+		s.add(c, svc.IntentFilters, targetSdk)
+	}
+	for _, rcv := range app.Receivers {
+		c := Component{Kind: ComponentReceiver, Name: rcv.Name, Permission: guard(rcv.Permission, app.Permission)}
+		c.setExported(rcv.Exported, rcv.IntentFilters, false)
+		s.add(c, rcv.IntentFilters, targetSdk)
+	}
+	for _, p := range app.Providers {
+		c := Component{
+			Kind:                ComponentProvider,
+			Name:                p.Name,
+			Permission:          guard(p.Permission, app.Permission),
+			ReadPermission:      guard(p.ReadPermission, p.Permission, app.Permission),
+			WritePermission:     guard(p.WritePermission, p.Permission, app.Permission),
+			GrantURIPermissions: p.GrantURIPermissions,
+		}
+		if p.Authorities != "" {
+			c.Authorities = strings.Split(p.Authorities, ";")
+		}
+		c.setExported(p.Exported, nil, targetSdk < sdkJellyBeanMR1)
+		s.add(c, nil, targetSdk)
+	}
+	return s
+}
+
+// setExported sets the effective exported value of the component: the
+// declared one, else whether it has intent filters, else def.
+func (c *Component) setExported(exported *bool, filters []apkActivityIntentFilter, def bool) {
+	switch {
+	case exported != nil:
+		c.Exported, c.ExportedSource = *exported, ExportedExplicit
+	case len(filters) > 0:
+		c.Exported, c.ExportedSource = true, ExportedIntentFilter
+	default:
+		c.Exported, c.ExportedSource = def, ExportedDefault
+	}
+}
+
+// add adds the component c with the given intent filters, and its findings.
+func (s *AttackSurface) add(c Component, filters []apkActivityIntentFilter, targetSdk int) {
+	launcher := false
+	for i := range filters {
+		for _, action := range filters[i].Actions {
+			if !containsString(c.Actions, action.Name) {
+				c.Actions = append(c.Actions, action.Name)
+			}
+		}
+		launcher = launcher || filters[i].hasCategory("android.intent.category.LAUNCHER")
+	}
+	s.Components = append(s.Components, c)
+
+	if c.ExportedSource == ExportedIntentFilter {
+		if targetSdk >= sdkS {
+			s.Findings = append(s.Findings, Finding{
+				Rule:    RuleMissingExported,
+				Target:  c.Name,
+				Message: "android:exported must be declared for components with intent filters when targeting API 31 or higher; the APK can't be installed",
+			})
+		} else {
+			s.Findings = append(s.Findings, Finding{
+				Rule:    RuleExportedImplicitly,
+				Target:  c.Name,
+				Message: "exported implicitly by its intent filters",
+			})
+		}
+	}
+	if !c.Exported {
+		return
+	}
+	// providers are guarded for reading and writing separately.
+	guards := []*PermissionGuard{c.Permission}
+	access := []string{""}
+	if c.Kind == ComponentProvider {
+		guards = []*PermissionGuard{c.ReadPermission, c.WritePermission}
+		access = []string{"read ", "write "}
+	}
+	for i, g := range guards {
+		switch {
+		case g == nil:
+			// launcher activities must be startable by any app.
+			if !launcher {
+				s.Findings = append(s.Findings, Finding{
+					Rule:    RuleExportedUnprotected,
+					Target:  c.Name,
+					Message: "exported " + c.Kind + " is not protected by a " + access[i] + "permission",
+				})
+			}
+		case i > 0 && guards[0] != nil && guards[0].Name == g.Name:
+			// the same permission guards reading, already checked.
+		case protectionBase(g.ProtectionLevel) == ProtectionNormal:
+			s.Findings = append(s.Findings, Finding{
+				Rule:    RuleWeakPermission,
+				Target:  c.Name,
+				Message: "permission " + g.Name + " has protection level normal; any app can hold it",
+			})
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package apkparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAttackSurface(t *testing.T) {
+	android := func(name, value string) xmlAttr {
+		return xmlAttr{NS: androidNamespace, Name: name, Value: value}
+	}
+	exported := func(v bool) xmlAttr {
+		a := xmlAttr{NS: androidNamespace, Name: "exported", Type: TypeIntBoolean}
+		if v {
+			a.Data = 0xFFFFFFFF
+		}
+		return a
+	}
+	filter := func(action string) *xmlElement {
+		return &xmlElement{Name: "intent-filter", Children: []*xmlElement{
+			{Name: "action", Attrs: []xmlAttr{android("name", action)}},
+		}}
+	}
+	component := func(kind string, attrs []xmlAttr, children ...*xmlElement) *xmlElement {
+		return &xmlElement{Name: kind, Attrs: attrs, Children: children}
+	}
+	manifest := func(targetSdk uint32) *xmlElement {
+		return testManifest(
+			&xmlElement{Name: "uses-sdk", Attrs: []xmlAttr{
+				{NS: androidNamespace, Name: "targetSdkVersion", Type: TypeIntDec, Data: targetSdk},
+			}},
+			&xmlElement{Name: "permission", Attrs: []xmlAttr{
+				android("name", "com.example.app.SYNC"),
+				{NS: androidNamespace, Name: "protectionLevel", Type: TypeIntHex, Data: 0x12},
+			}},
+			&xmlElement{Name: "permission", Attrs: []xmlAttr{
+				android("name", "com.example.app.READ"),
+			}},
+			&xmlElement{Name: "application", Children: []*xmlElement{
+				component("activity", []xmlAttr{android("name", ".MainActivity")}, &xmlElement{
+					Name: "intent-filter", Children: []*xmlElement{
+						{Name: "action", Attrs: []xmlAttr{android("name", "android.intent.action.MAIN")}},
+						{Name: "category", Attrs: []xmlAttr{android("name", "android.intent.category.LAUNCHER")}},
+					},
+				}),
+				component("activity", []xmlAttr{android("name", ".InternalActivity")}),
+				component("service", []xmlAttr{android("name", ".SyncService"), android("permission", "com.example.app.SYNC")},
+					filter("android.content.SyncAdapter")),
+				component("receiver", []xmlAttr{android("name", ".BootReceiver"), exported(true)},
+					filter("android.intent.action.BOOT_COMPLETED")),
+				component("provider", []xmlAttr{
+					android("name", ".DataProvider"),
+					android("authorities", "com.example.app.data;com.example.app.legacy"),
+					android("readPermission", "com.example.app.READ"),
+					android("writePermission", "com.example.app.WRITE"),
+				}),
+			}},
+		)
+	}
+
+	for _, tt := range []struct {
+		targetSdk uint32
+		exported  map[string]bool
+		findings  []Finding
+	}{
+		{
+			targetSdk: 30,
+			exported: map[string]bool{
+				".MainActivity": true, ".InternalActivity": false, ".SyncService": true,
+				".BootReceiver": true, ".DataProvider": false,
+			},
+			findings: []Finding{
+				{RuleExportedImplicitly, ".MainActivity", "exported implicitly by its intent filters"},
+				{RuleExportedImplicitly, ".SyncService", "exported implicitly by its intent filters"},
+				{RuleExportedUnprotected, ".BootReceiver", "exported receiver is not protected by a permission"},
+			},
+		},
+		{
+			targetSdk: 16,
+			exported: map[string]bool{
+				".MainActivity": true, ".InternalActivity": false, ".SyncService": true,
+				".BootReceiver": true, ".DataProvider": true,
+			},
+			findings: []Finding{
+				{RuleExportedImplicitly, ".MainActivity", "exported implicitly by its intent filters"},
+				{RuleExportedImplicitly, ".SyncService", "exported implicitly by its intent filters"},
+				{RuleExportedUnprotected, ".BootReceiver", "exported receiver is not protected by a permission"},
+				{RuleWeakPermission, ".DataProvider", "permission com.example.app.READ has protection level normal; any app can hold it"},
+			},
+		},
+	} {
+		name := writeAPK(t,
+			zipFile{"AndroidManifest.xml", encodeXML(manifest(tt.targetSdk))},
+			zipFile{"resources.arsc", testTable()},
+		)
+		info, err := New(name, Option{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		surface := info.AttackSurface
+		got := make(map[string]bool)
+		for _, c := range surface.Components {
+			got[c.Name] = c.Exported
+		}
+		if !reflect.DeepEqual(got, tt.exported) {
+			t.Errorf("targetSdk %d: exported = %v, want %v", tt.targetSdk, got, tt.exported)
+		}
+		if !reflect.DeepEqual(surface.Findings, tt.findings) {
+			t.Errorf("targetSdk %d: findings:\ngot  %+v\nwant %+v", tt.targetSdk, surface.Findings, tt.findings)
+		}
+
+		service := surface.Components[2]
+		if want := (&PermissionGuard{"com.example.app.SYNC", "signature|privileged"}); !reflect.DeepEqual(service.Permission, want) {
+			t.Errorf("service permission = %+v, want %+v", service.Permission, want)
+		}
+		provider := surface.Components[4]
+		if provider.WritePermission == nil || provider.WritePermission.ProtectionLevel != "" ||
+			!reflect.DeepEqual(provider.Authorities, []string{"com.example.app.data", "com.example.app.legacy"}) {
+			t.Errorf("provider = %+v", provider)
+		}
+	}
+
+	// components with intent filters must declare android:exported from API 31.
+	name := writeAPK(t,
+		zipFile{"AndroidManifest.xml", encodeXML(manifest(31))},
+		zipFile{"resources.arsc", testTable()},
+	)
+	info, err := New(name, Option{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f := info.AttackSurface.Findings[0]; f.Rule != RuleMissingExported || f.Target != ".MainActivity" {
+		t.Errorf("targetSdk 31: first finding = %+v", f)
+	}
+}
+
+func TestAttackSurfaceProvider(t *testing.T) {
+	android := func(name, value string) xmlAttr {
+		return xmlAttr{NS: androidNamespace, Name: name, Value: value}
+	}
+	provider := func(name string, attrs ...xmlAttr) *xmlElement {
+		attrs = append([]xmlAttr{
+			android("name", name),
+			{NS: androidNamespace, Name: "exported", Type: TypeIntBoolean, Data: 0xFFFFFFFF},
+		}, attrs...)
+		return &xmlElement{Name: "provider", Attrs: attrs}
+	}
+	manifest := testManifest(
+		&xmlElement{Name: "permission", Attrs: []xmlAttr{
+			android("name", "com.example.app.READ"),
+		}},
+		&xmlElement{Name: "application", Children: []*xmlElement{
+			// each of reading and writing is checked, whatever the other.
+			provider(".ReadOnly", android("readPermission", "com.example.app.READ")),
+			provider(".Unprotected"),
+			// the same permission guarding both is reported once.
+			provider(".Shared", android("permission", "com.example.app.READ")),
+		}},
+	)
+	name := writeAPK(t,
+		zipFile{"AndroidManifest.xml", encodeXML(manifest)},
+		zipFile{"resources.arsc", testTable()},
+	)
+	info, err := New(name, Option{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Finding{
+		{RuleWeakPermission, ".ReadOnly", "permission com.example.app.READ has protection level normal; any app can hold it"},
+		{RuleExportedUnprotected, ".ReadOnly", "exported provider is not protected by a write permission"},
+		{RuleExportedUnprotected, ".Unprotected", "exported provider is not protected by a read permission"},
+		{RuleExportedUnprotected, ".Unprotected", "exported provider is not protected by a write permission"},
+		{RuleWeakPermission, ".Shared", "permission com.example.app.READ has protection level normal; any app can hold it"},
+	}
+	if !reflect.DeepEqual(info.AttackSurface.Findings, want) {
+		t.Errorf("findings:\ngot  %+v\nwant %+v", info.AttackSurface.Findings, want)
+	}
+}
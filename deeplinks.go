@@ -0,0 +1,161 @@
+package apkparser
+
+import (
+	"strings"
+)
+
+// Intent actions and categories of deep links.
+const (
+	actionView        = "android.intent.action.VIEW"
+	categoryBrowsable = "android.intent.category.BROWSABLE"
+	categoryDefault   = "android.intent.category.DEFAULT"
+)
+
+// DeepLink is a URI an activity handles, one combination of the scheme,
+// authority and path of the <data> elements of a VIEW intent filter.
+// https://developer.android.com/training/app-links/deep-linking
+type DeepLink struct {
+	Activity       string `json:"activity"`                 // activity or activity alias
+	TargetActivity string `json:"targetActivity,omitempty"` // activity an alias starts
+
+	Scheme              string `json:"scheme"`
+	Host                string `json:"host,omitempty"`
+	Port                string `json:"port,omitempty"`
+	Path                string `json:"path,omitempty"`
+	PathPrefix          string `json:"pathPrefix,omitempty"`
+	PathPattern         string `json:"pathPattern,omitempty"`
+	PathSuffix          string `json:"pathSuffix,omitempty"`
+	PathAdvancedPattern string `json:"pathAdvancedPattern,omitempty"`
+
+	AutoVerify bool `json:"autoVerify"` // android:autoVerify="true" is set on the intent filter
+	Browsable  bool `json:"browsable"`  // the intent filter has the BROWSABLE category
+	Default    bool `json:"default"`    // the intent filter has the DEFAULT category
+
+	// AppLink is set for Android App Links, the http(s) links with
+	// AutoVerify, Browsable and Default the system verifies the app for.
+	// https://developer.android.com/training/app-links/verify-android-applinks
+	AppLink bool `json:"appLink"`
+}
+
+// URI returns the link as a URI template: prefixes end with *, and patterns
+// are written as is.
+func (l *DeepLink) URI() string {
+	var b strings.Builder
+	b.WriteString(l.Scheme)
+	b.WriteString(":")
+	if l.Host == "" {
+		return b.String()
+	}
+	b.WriteString("//")
+	b.WriteString(l.Host)
+	if l.Port != "" {
+		b.WriteString(":" + l.Port)
+	}
+	switch {
+	case l.Path != "":
+		b.WriteString(l.Path)
+	case l.PathPrefix != "":
+		b.WriteString(l.PathPrefix + "*")
+	case l.PathPattern != "":
+		b.WriteString(l.PathPattern)
+	case l.PathSuffix != "":
+		b.WriteString("*" + l.PathSuffix)
+	case l.PathAdvancedPattern != "":
+		b.WriteString(l.PathAdvancedPattern)
+	}
+	return b.String()
+}
+
+// deepLinks returns the deep links of the activities and activity aliases.
+func (k *apk) deepLinks() []DeepLink {
+	var links []DeepLink
+	for _, act := range k.apkManifest.App.Activities {
+		for i := range act.IntentFilters {
+			links = appendDeepLinks(links, &act.IntentFilters[i], DeepLink{Activity: act.Name})
+		}
+	}
+	for _, alias := range k.apkManifest.App.ActivityAliases {
+		for i := range alias.IntentFilters {
+			links = appendDeepLinks(links, &alias.IntentFilters[i], DeepLink{
+				Activity:       alias.Name,
+				TargetActivity: alias.TargetActivity,
+			})
+		}
+	}
+	return links
+}
+
+// appendDeepLinks appends the links an intent filter matches to links.
+// The <data> elements of a filter are merged: every scheme is combined with
+// every authority, and every authority with every path. As on the platform,
+// hosts are ignored without a scheme, and paths without a host.
+func appendDeepLinks(links []DeepLink, filter *apkActivityIntentFilter, link DeepLink) []DeepLink {
+	if !filter.hasAction(actionView) {
+		return links
+	}
+	var schemes []string
+	var authorities, paths []apkIntentData
+	for _, d := range filter.Data {
+		if d.Scheme != "" && !containsString(schemes, d.Scheme) {
+			schemes = append(schemes, d.Scheme)
+		}
+		if a := (apkIntentData{Host: d.Host, Port: d.Port}); d.Host != "" && !containsData(authorities, a) {
+			authorities = append(authorities, a)
+		}
+		for _, p := range []apkIntentData{
+			{Path: d.Path},
+			{PathPrefix: d.PathPrefix},
+			{PathPattern: d.PathPattern},
+			{PathSuffix: d.PathSuffix},
+			{PathAdvancedPattern: d.PathAdvancedPattern},
+		} {
+			if p != (apkIntentData{}) && !containsData(paths, p) {
+				paths = append(paths, p)
+			}
+		}
+	}
+	if len(authorities) == 0 {
+		authorities = []apkIntentData{{}}
+		paths = nil
+	}
+	if len(paths) == 0 {
+		paths = []apkIntentData{{}}
+	}
+
+	link.AutoVerify = filter.AutoVerify
+	link.Browsable = filter.hasCategory(categoryBrowsable)
+	link.Default = filter.hasCategory(categoryDefault)
+	for _, scheme := range schemes {
+		for _, a := range authorities {
+			for _, p := range paths {
+				l := link
+				l.Scheme = scheme
+				l.Host, l.Port = a.Host, a.Port
+				l.Path, l.PathPrefix, l.PathPattern = p.Path, p.PathPrefix, p.PathPattern
+				l.PathSuffix, l.PathAdvancedPattern = p.PathSuffix, p.PathAdvancedPattern
+				l.AppLink = l.AutoVerify && l.Browsable && l.Default && l.Host != "" &&
+					(l.Scheme == "http" || l.Scheme == "https")
+				links = append(links, l)
+			}
+		}
+	}
+	return links
+}
+
+func containsString(a []string, s string) bool {
+	for _, v := range a {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func containsData(a []apkIntentData, d apkIntentData) bool {
+	for _, v := range a {
+		if v == d {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package apkparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDeepLinks(t *testing.T) {
+	android := func(name, value string) xmlAttr {
+		return xmlAttr{NS: androidNamespace, Name: name, Value: value}
+	}
+	named := func(elem, name string) *xmlElement {
+		return &xmlElement{Name: elem, Attrs: []xmlAttr{android("name", name)}}
+	}
+	data := func(attrs ...xmlAttr) *xmlElement {
+		return &xmlElement{Name: "data", Attrs: attrs}
+	}
+	manifest := testManifest(&xmlElement{Name: "application", Children: []*xmlElement{
+		{Name: "activity", Attrs: []xmlAttr{android("name", ".MainActivity")}, Children: []*xmlElement{
+			{Name: "intent-filter", Children: []*xmlElement{
+				named("action", "android.intent.action.MAIN"),
+				named("category", "android.intent.category.LAUNCHER"),
+			}},
+			{Name: "intent-filter", Attrs: []xmlAttr{
+				{NS: androidNamespace, Name: "autoVerify", Type: TypeIntBoolean, Data: 0xFFFFFFFF},
+			}, Children: []*xmlElement{
+				named("action", "android.intent.action.VIEW"),
+				named("category", "android.intent.category.DEFAULT"),
+				named("category", "android.intent.category.BROWSABLE"),
+				data(android("scheme", "http")),
+				data(android("scheme", "https"), android("host", "example.com")),
+				data(android("pathPrefix", "/product/")),
+				data(android("host", "example.com")),
+			}},
+		}},
+		{Name: "activity-alias", Attrs: []xmlAttr{
+			android("name", ".ShareAlias"),
+			android("targetActivity", ".MainActivity"),
+		}, Children: []*xmlElement{
+			{Name: "intent-filter", Children: []*xmlElement{
+				named("action", "android.intent.action.VIEW"),
+				named("category", "android.intent.category.DEFAULT"),
+				// paths are ignored without a host.
+				data(android("scheme", "example"), android("path", "/ignored")),
+			}},
+		}},
+	}})
+	name := writeAPK(t,
+		zipFile{"AndroidManifest.xml", encodeXML(manifest)},
+		zipFile{"resources.arsc", testTable()},
+	)
+
+	info, err := New(name, Option{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var uris []string
+	for _, link := range info.DeepLinks {
+		uris = append(uris, link.URI())
+	}
+	want := []string{
+		"http://example.com/product/*",
+		"https://example.com/product/*",
+		"example:",
+	}
+	if !reflect.DeepEqual(uris, want) {
+		t.Errorf("deep links = %q, want %q", uris, want)
+	}
+	if len(info.DeepLinks) != len(want) {
+		return
+	}
+	if link := info.DeepLinks[1]; !link.AppLink || link.Activity != ".MainActivity" || link.PathPrefix != "/product/" {
+		t.Errorf("app link = %+v", link)
+	}
+	alias := info.DeepLinks[2]
+	if alias.AppLink || alias.Browsable || !alias.Default || alias.TargetActivity != ".MainActivity" {
+		t.Errorf("alias link = %+v", alias)
+	}
+}
@@ -0,0 +1,377 @@
+package apkparser
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/adler32"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// dexMagic is the magic of DEX files, followed by a 3 digit version and NUL.
+var dexMagic = []byte("dex\n")
+
+// dexEndianConstant is the EndianTag of little-endian DEX files.
+const dexEndianConstant = 0x12345678
+
+// DexMethodRefsLimit is the maximum number of methods a DEX file can
+// reference, the 64K limit beyond which apps need multidex.
+const DexMethodRefsLimit = 65536
+
+// dexVersionMinSdk are the API levels which introduced the DEX versions.
+var dexVersionMinSdk = map[int]int{
+	35: 1,
+	37: 24,
+	38: 26,
+	39: 28,
+	40: 29,
+	41: 35,
+}
+
+// DexHeader is the header of a DEX file.
+// https://source.android.com/docs/core/runtime/dex-format#header-item
+type DexHeader struct {
+	Magic         [8]uint8
+	Checksum      uint32
+	Signature     [20]uint8
+	FileSize      uint32
+	HeaderSize    uint32
+	EndianTag     uint32
+	LinkSize      uint32
+	LinkOff       uint32
+	MapOff        uint32
+	StringIdsSize uint32
+	StringIdsOff  uint32
+	TypeIdsSize   uint32
+	TypeIdsOff    uint32
+	ProtoIdsSize  uint32
+	ProtoIdsOff   uint32
+	FieldIdsSize  uint32
+	FieldIdsOff   uint32
+	MethodIdsSize uint32
+	MethodIdsOff  uint32
+	ClassDefsSize uint32
+	ClassDefsOff  uint32
+	DataSize      uint32
+	DataOff       uint32
+}
+
+// DexFile is a Dalvik executable, classes.dex.
+type DexFile struct {
+	Header DexHeader
+	data   []byte
+}
+
+// NewDexFile parses the header of the DEX file data. The rest of the file
+// is read lazily, by the accessors.
+func NewDexFile(data []byte) (*DexFile, error) {
+	return newDexFile(data, DefaultLimits)
+}
+
+// newDexFile returns a new DexFile, whose id tables have at most
+// limits.MaxDexItems items.
+func newDexFile(data []byte, limits Limits) (*DexFile, error) {
+	f := &DexFile{data: data}
+	if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, &f.Header); err != nil {
+		return nil, fmt.Errorf("apkparser: read dex header: %w", err)
+	}
+	if !bytes.HasPrefix(f.Header.Magic[:], dexMagic) {
+		return nil, errors.New("apkparser: not a dex file")
+	}
+	if f.Header.EndianTag != dexEndianConstant {
+		return nil, fmt.Errorf("apkparser: unsupported dex endian tag 0x%08x", f.Header.EndianTag)
+	}
+	if _, ok := dexVersionMinSdk[f.Version()]; !ok {
+		return nil, fmt.Errorf("apkparser: unsupported dex version %q", f.Header.Magic[4:7])
+	}
+	h := &f.Header
+	for _, t := range []struct {
+		name      string
+		size, off uint32
+		itemSize  uint64
+	}{
+		{"string_ids", h.StringIdsSize, h.StringIdsOff, 4},
+		{"type_ids", h.TypeIdsSize, h.TypeIdsOff, 4},
+		{"proto_ids", h.ProtoIdsSize, h.ProtoIdsOff, 12},
+		{"field_ids", h.FieldIdsSize, h.FieldIdsOff, 8},
+		{"method_ids", h.MethodIdsSize, h.MethodIdsOff, 8},
+		{"class_defs", h.ClassDefsSize, h.ClassDefsOff, 32},
+	} {
+		if exceeds(int64(t.size), int64(limits.MaxDexItems)) {
+			return nil, &LimitError{Limit: "MaxDexItems", Max: int64(limits.MaxDexItems)}
+		}
+		if uint64(t.off)+uint64(t.size)*t.itemSize > uint64(len(data)) {
+			return nil, fmt.Errorf("apkparser: dex %s of %d items at 0x%x out of bounds", t.name, t.size, t.off)
+		}
+	}
+	return f, nil
+}
+
+// Version returns the format version of the DEX file, e.g. 35 for dex\n035,
+// or 0 if the magic has none.
+func (f *DexFile) Version() int {
+	v, err := strconv.Atoi(string(f.Header.Magic[4:7]))
+	if err != nil || f.Header.Magic[7] != 0 {
+		return 0
+	}
+	return v
+}
+
+// VerifyChecksum returns whether the adler32 checksum of the header matches
+// the rest of the file.
+func (f *DexFile) VerifyChecksum() bool {
+	return len(f.data) >= 12 && adler32.Checksum(f.data[12:]) == f.Header.Checksum
+}
+
+// VerifySignature returns whether the SHA-1 signature of the header matches
+// the rest of the file.
+func (f *DexFile) VerifySignature() bool {
+	if len(f.data) < 32 {
+		return false
+	}
+	return sha1.Sum(f.data[32:]) == f.Header.Signature
+}
+
+// DexInfo is the metadata of a DEX file of an APK.
+type DexInfo struct {
+	Name           string `json:"name"`           // e.g. classes2.dex
+	Version        int    `json:"version"`        // format version, 35 to 41
+	MinSdkVersion  int    `json:"minSdkVersion"`  // API level which introduced the version
+	Size           int    `json:"size"`           // bytes, uncompressed
+	Checksum       string `json:"checksum"`       // adler32 of the header, in hex
+	ChecksumValid  bool   `json:"checksumValid"`  // whether the checksum matches the file
+	Signature      string `json:"signature"`      // SHA-1 of the header, in hex
+	SignatureValid bool   `json:"signatureValid"` // whether the signature matches the file
+
+	Strings   int `json:"strings"`
+	Types     int `json:"types"`
+	Protos    int `json:"protos"`
+	Fields    int `json:"fields"`  // field references
+	Methods   int `json:"methods"` // method references, at most DexMethodRefsLimit
+	ClassDefs int `json:"classDefs"`
+}
+
+// DexReport is the metadata of the DEX files of an APK, with totals over
+// them. References shared by several files are counted in each.
+type DexReport struct {
+	Files         []DexInfo `json:"files"`         // in load order
+	Multidex      bool      `json:"multidex"`      // whether there is more than one file
+	MinSdkVersion int       `json:"minSdkVersion"` // API level which supports all the versions
+
+	Strings   int `json:"strings"`
+	Types     int `json:"types"`
+	Protos    int `json:"protos"`
+	Fields    int `json:"fields"`
+	Methods   int `json:"methods"`
+	ClassDefs int `json:"classDefs"`
+}
+
+// Info returns the metadata of the DEX file, named name in the APK.
+func (f *DexFile) Info(name string) DexInfo {
+	h := &f.Header
+	return DexInfo{
+		Name:           name,
+		Version:        f.Version(),
+		MinSdkVersion:  dexVersionMinSdk[f.Version()],
+		Size:           len(f.data),
+		Checksum:       fmt.Sprintf("%08x", h.Checksum),
+		ChecksumValid:  f.VerifyChecksum(),
+		Signature:      hex.EncodeToString(h.Signature[:]),
+		SignatureValid: f.VerifySignature(),
+		Strings:        int(h.StringIdsSize),
+		Types:          int(h.TypeIdsSize),
+		Protos:         int(h.ProtoIdsSize),
+		Fields:         int(h.FieldIdsSize),
+		Methods:        int(h.MethodIdsSize),
+		ClassDefs:      int(h.ClassDefsSize),
+	}
+}
+
+// u32 returns the little-endian uint32 at off.
+func (f *DexFile) u32(off uint32) (uint32, error) {
+	if uint64(off)+4 > uint64(len(f.data)) {
+		return 0, fmt.Errorf("apkparser: dex offset 0x%x out of bounds", off)
+	}
+	return binary.LittleEndian.Uint32(f.data[off:]), nil
+}
+
+// GetString returns the string idx of the string_ids table.
+func (f *DexFile) GetString(idx uint32) (string, error) {
+	if idx >= f.Header.StringIdsSize {
+		return "", fmt.Errorf("apkparser: dex string index %d out of range", idx)
+	}
+	off, err := f.u32(f.Header.StringIdsOff + 4*idx)
+	if err != nil {
+		return "", err
+	}
+	if off >= uint32(len(f.data)) {
+		return "", fmt.Errorf("apkparser: dex string data offset 0x%x out of bounds", off)
+	}
+	// string_data_item: the UTF-16 length, then NUL-terminated MUTF-8.
+	data := f.data[off:]
+	n, size := readUleb128(data)
+	if size == 0 {
+		return "", fmt.Errorf("apkparser: invalid dex string length at 0x%x", off)
+	}
+	data = data[size:]
+	if end := bytes.IndexByte(data, 0); end >= 0 {
+		data = data[:end]
+	}
+	return decodeMUTF8(data, int(n))
+}
+
+// TypeDescriptor returns the descriptor of the type idx of the type_ids
+// table, e.g. Ljava/lang/Object; or [I.
+func (f *DexFile) TypeDescriptor(idx uint32) (string, error) {
+	if idx >= f.Header.TypeIdsSize {
+		return "", fmt.Errorf("apkparser: dex type index %d out of range", idx)
+	}
+	stringIdx, err := f.u32(f.Header.TypeIdsOff + 4*idx)
+	if err != nil {
+		return "", err
+	}
+	return f.GetString(stringIdx)
+}
+
+// TypeDescriptors returns the descriptors of all the types the DEX file
+// defines or references.
+func (f *DexFile) TypeDescriptors() ([]string, error) {
+	types := make([]string, 0, f.Header.TypeIdsSize)
+	for i := uint32(0); i < f.Header.TypeIdsSize; i++ {
+		t, err := f.TypeDescriptor(i)
+		if err != nil {
+			return nil, err
+		}
+		types = append(types, t)
+	}
+	return types, nil
+}
+
+// ClassName converts a class descriptor, e.g. Lcom/example/Foo$Bar;, to a
+// Java class name, e.g. com.example.Foo$Bar. Other descriptors, of
+// primitive and array types, are returned as is.
+func ClassName(descriptor string) string {
+	if len(descriptor) < 2 || descriptor[0] != 'L' || descriptor[len(descriptor)-1] != ';' {
+		return descriptor
+	}
+	return strings.ReplaceAll(descriptor[1:len(descriptor)-1], "/", ".")
+}
+
+// readUleb128 decodes an unsigned LEB128 value, returning it and its size,
+// 0 if it is invalid.
+func readUleb128(data []byte) (uint32, int) {
+	var v uint32
+	for i := 0; i < 5 && i < len(data); i++ {
+		v |= uint32(data[i]&0x7f) << (7 * i)
+		if data[i]&0x80 == 0 {
+			return v, i + 1
+		}
+	}
+	return 0, 0
+}
+
+// decodeMUTF8 decodes the modified UTF-8 of DEX files, which encodes NUL
+// in 2 bytes and supplementary characters as surrogate pairs, into a string
+// of n UTF-16 units.
+func decodeMUTF8(data []byte, n int) (string, error) {
+	if n > len(data) {
+		n = len(data)
+	}
+	units := make([]uint16, 0, n)
+	for i := 0; i < len(data); {
+		b := data[i]
+		switch {
+		case b < 0x80:
+			units = append(units, uint16(b))
+			i++
+		case b&0xe0 == 0xc0 && i+1 < len(data):
+			units = append(units, uint16(b&0x1f)<<6|uint16(data[i+1]&0x3f))
+			i += 2
+		case b&0xf0 == 0xe0 && i+2 < len(data):
+			units = append(units, uint16(b&0x0f)<<12|uint16(data[i+1]&0x3f)<<6|uint16(data[i+2]&0x3f))
+			i += 3
+		default:
+			return "", fmt.Errorf("apkparser: invalid MUTF-8 byte 0x%02x", b)
+		}
+	}
+	return string(utf16.Decode(units)), nil
+}
+
+// isDexName returns whether name is a DEX file of the APK, classes.dex or
+// classesN.dex.
+func isDexName(name string) bool {
+	if !strings.HasPrefix(name, "classes") || !strings.HasSuffix(name, ".dex") {
+		return false
+	}
+	n := name[len("classes") : len(name)-len(".dex")]
+	if n == "" {
+		return true
+	}
+	i, err := strconv.Atoi(n)
+	return err == nil && i >= 2 && strconv.Itoa(i) == n
+}
+
+// dexNames returns the names of the DEX files of the APK, in load order.
+func (k *apk) dexNames() []string {
+	var names []string
+	for _, f := range k.archive.files {
+		if isDexName(f.Name) {
+			names = append(names, f.Name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if len(names[i]) != len(names[j]) {
+			return len(names[i]) < len(names[j])
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// dexFiles reads and parses the DEX files of the APK, in load order.
+func (k *apk) dexFiles() ([]*DexFile, []string, error) {
+	names := k.dexNames()
+	files := make([]*DexFile, 0, len(names))
+	for _, name := range names {
+		data, err := k.readZipFile(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		f, err := newDexFile(data, k.limits)
+		if err != nil {
+			return nil, nil, &EntryError{Op: "parse", Name: name, Err: err}
+		}
+		files = append(files, f)
+	}
+	return files, names, nil
+}
+
+// dexReport returns the metadata of the DEX files of the APK, or nil if it
+// has none.
+func (k *apk) dexReport() (*DexReport, error) {
+	files, names, err := k.dexFiles()
+	if err != nil || len(files) == 0 {
+		return nil, err
+	}
+	report := &DexReport{Multidex: len(files) > 1}
+	for i, f := range files {
+		info := f.Info(names[i])
+		report.Files = append(report.Files, info)
+		if info.MinSdkVersion > report.MinSdkVersion {
+			report.MinSdkVersion = info.MinSdkVersion
+		}
+		report.Strings += info.Strings
+		report.Types += info.Types
+		report.Protos += info.Protos
+		report.Fields += info.Fields
+		report.Methods += info.Methods
+		report.ClassDefs += info.ClassDefs
+	}
+	return report, nil
+}
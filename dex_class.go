@@ -0,0 +1,409 @@
+package apkparser
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// dexNoIndex marks absent indexes of DEX files, e.g. the superclass of
+// java.lang.Object.
+const dexNoIndex = 0xffffffff
+
+// AccessFlags are the access flags of a DEX class, field or method.
+// https://source.android.com/docs/core/runtime/dex-format#access-flags
+type AccessFlags uint32
+
+// Access flags. Some values mean different things for fields and methods.
+const (
+	AccPublic               AccessFlags = 0x1
+	AccPrivate              AccessFlags = 0x2
+	AccProtected            AccessFlags = 0x4
+	AccStatic               AccessFlags = 0x8
+	AccFinal                AccessFlags = 0x10
+	AccSynchronized         AccessFlags = 0x20
+	AccVolatile             AccessFlags = 0x40 // fields
+	AccBridge               AccessFlags = 0x40 // methods
+	AccTransient            AccessFlags = 0x80 // fields
+	AccVarargs              AccessFlags = 0x80 // methods
+	AccNative               AccessFlags = 0x100
+	AccInterface            AccessFlags = 0x200
+	AccAbstract             AccessFlags = 0x400
+	AccStrict               AccessFlags = 0x800
+	AccSynthetic            AccessFlags = 0x1000
+	AccAnnotation           AccessFlags = 0x2000
+	AccEnum                 AccessFlags = 0x4000
+	AccConstructor          AccessFlags = 0x10000
+	AccDeclaredSynchronized AccessFlags = 0x20000
+)
+
+// accessFlagNames are the names of the flags which mean the same thing for
+// classes, fields and methods, in the order of Java modifiers.
+var accessFlagNames = []struct {
+	flag AccessFlags
+	name string
+}{
+	{AccPublic, "public"},
+	{AccPrivate, "private"},
+	{AccProtected, "protected"},
+	{AccStatic, "static"},
+	{AccFinal, "final"},
+	{AccSynchronized, "synchronized"},
+	{AccNative, "native"},
+	{AccAbstract, "abstract"},
+	{AccStrict, "strictfp"},
+	{AccSynthetic, "synthetic"},
+	{AccInterface, "interface"},
+	{AccAnnotation, "annotation"},
+	{AccEnum, "enum"},
+	{AccConstructor, "constructor"},
+}
+
+// String returns the names of the flags separated by spaces, e.g. public
+// static final. The flags whose meaning depends on what they apply to,
+// volatile or bridge and transient or varargs, are left out.
+func (flags AccessFlags) String() string {
+	var names []string
+	for _, f := range accessFlagNames {
+		if flags&f.flag != 0 {
+			names = append(names, f.name)
+		}
+	}
+	return strings.Join(names, " ")
+}
+
+// DexClass is a class defined by a DEX file.
+type DexClass struct {
+	Dex         string      `json:"dex,omitempty"`        // DEX file, e.g. classes2.dex
+	Name        string      `json:"name"`                 // Java class name, e.g. com.example.Foo$Bar
+	AccessFlags AccessFlags `json:"accessFlags"`          // AccPublic, AccFinal, AccInterface...
+	Superclass  string      `json:"superclass,omitempty"` // Java class name, none for java.lang.Object
+	Interfaces  []string    `json:"interfaces,omitempty"` // Java class names
+	SourceFile  string      `json:"sourceFile,omitempty"` // e.g. Foo.java, often stripped by obfuscators
+	Fields      []DexField  `json:"fields,omitempty"`     // static fields, then instance fields
+	Methods     []DexMethod `json:"methods,omitempty"`    // direct methods, then virtual methods
+}
+
+// DexField is a field of a DEX class.
+type DexField struct {
+	Name        string      `json:"name"`
+	Type        string      `json:"type"`        // type descriptor, e.g. Ljava/lang/String;
+	AccessFlags AccessFlags `json:"accessFlags"` // AccPublic, AccFinal, AccInterface...
+}
+
+// DexMethod is a method of a DEX class.
+type DexMethod struct {
+	Name        string      `json:"name"`
+	Proto       DexProto    `json:"proto"`
+	AccessFlags AccessFlags `json:"accessFlags"` // AccPublic, AccFinal, AccInterface...
+	Direct      bool        `json:"direct"`      // static, private or a constructor
+}
+
+// DexProto is the prototype of a DEX method.
+type DexProto struct {
+	Shorty     string   `json:"shorty"`               // short form, e.g. VIL
+	ReturnType string   `json:"returnType"`           // type descriptor
+	Parameters []string `json:"parameters,omitempty"` // type descriptors
+}
+
+// Descriptor returns the method descriptor of the prototype, e.g.
+// (ILjava/lang/String;)V.
+func (p DexProto) Descriptor() string {
+	return "(" + strings.Join(p.Parameters, "") + ")" + p.ReturnType
+}
+
+// u16 returns the little-endian uint16 at off.
+func (f *DexFile) u16(off uint32) (uint16, error) {
+	if uint64(off)+2 > uint64(len(f.data)) {
+		return 0, fmt.Errorf("apkparser: dex offset 0x%x out of bounds", off)
+	}
+	return binary.LittleEndian.Uint16(f.data[off:]), nil
+}
+
+// typeList returns the type descriptors of the type_list at off.
+func (f *DexFile) typeList(off uint32) ([]string, error) {
+	size, err := f.u32(off)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(off)+4+2*uint64(size) > uint64(len(f.data)) {
+		return nil, fmt.Errorf("apkparser: dex type list at 0x%x out of bounds", off)
+	}
+	types := make([]string, size)
+	for i := range types {
+		idx, _ := f.u16(off + 4 + 2*uint32(i))
+		if types[i], err = f.TypeDescriptor(uint32(idx)); err != nil {
+			return nil, err
+		}
+	}
+	return types, nil
+}
+
+// Proto returns the prototype idx of the proto_ids table.
+func (f *DexFile) Proto(idx uint32) (DexProto, error) {
+	var p DexProto
+	if idx >= f.Header.ProtoIdsSize {
+		return p, fmt.Errorf("apkparser: dex proto index %d out of range", idx)
+	}
+	off := f.Header.ProtoIdsOff + 12*idx
+	shorty, err := f.u32(off)
+	if err != nil {
+		return p, err
+	}
+	returnType, err := f.u32(off + 4)
+	if err != nil {
+		return p, err
+	}
+	parameters, err := f.u32(off + 8)
+	if err != nil {
+		return p, err
+	}
+	if p.Shorty, err = f.GetString(shorty); err != nil {
+		return p, err
+	}
+	if p.ReturnType, err = f.TypeDescriptor(returnType); err != nil {
+		return p, err
+	}
+	if parameters != 0 {
+		if p.Parameters, err = f.typeList(parameters); err != nil {
+			return p, err
+		}
+	}
+	return p, nil
+}
+
+// memberID returns the type or proto index and the name of the member idx of
+// the field_ids or method_ids table at off, of size entries.
+func (f *DexFile) memberID(off, size, idx uint32) (uint32, string, error) {
+	if idx >= size {
+		return 0, "", fmt.Errorf("apkparser: dex member index %d out of range", idx)
+	}
+	off += 8 * idx
+	typeIdx, err := f.u16(off + 2)
+	if err != nil {
+		return 0, "", err
+	}
+	nameIdx, err := f.u32(off + 4)
+	if err != nil {
+		return 0, "", err
+	}
+	name, err := f.GetString(nameIdx)
+	return uint32(typeIdx), name, err
+}
+
+// Field returns the name and type descriptor of the field idx of the
+// field_ids table.
+func (f *DexFile) Field(idx uint32) (name, typ string, err error) {
+	typeIdx, name, err := f.memberID(f.Header.FieldIdsOff, f.Header.FieldIdsSize, idx)
+	if err != nil {
+		return "", "", err
+	}
+	typ, err = f.TypeDescriptor(typeIdx)
+	return name, typ, err
+}
+
+// Method returns the name and prototype of the method idx of the method_ids
+// table.
+func (f *DexFile) Method(idx uint32) (string, DexProto, error) {
+	protoIdx, name, err := f.memberID(f.Header.MethodIdsOff, f.Header.MethodIdsSize, idx)
+	if err != nil {
+		return "", DexProto{}, err
+	}
+	proto, err := f.Proto(protoIdx)
+	return name, proto, err
+}
+
+// ClassDef returns the class idx of the class_defs table.
+func (f *DexFile) ClassDef(idx uint32) (*DexClass, error) {
+	if idx >= f.Header.ClassDefsSize {
+		return nil, fmt.Errorf("apkparser: dex class index %d out of range", idx)
+	}
+	// class_def_item: class_idx, access_flags, superclass_idx, interfaces_off,
+	// source_file_idx, annotations_off, class_data_off, static_values_off.
+	var def [8]uint32
+	for i := range def {
+		v, err := f.u32(f.Header.ClassDefsOff + 32*idx + 4*uint32(i))
+		if err != nil {
+			return nil, err
+		}
+		def[i] = v
+	}
+
+	c := &DexClass{AccessFlags: AccessFlags(def[1])}
+	descriptor, err := f.TypeDescriptor(def[0])
+	if err != nil {
+		return nil, err
+	}
+	c.Name = ClassName(descriptor)
+	if def[2] != dexNoIndex {
+		superclass, err := f.TypeDescriptor(def[2])
+		if err != nil {
+			return nil, err
+		}
+		c.Superclass = ClassName(superclass)
+	}
+	if def[3] != 0 {
+		interfaces, err := f.typeList(def[3])
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range interfaces {
+			c.Interfaces = append(c.Interfaces, ClassName(t))
+		}
+	}
+	if def[4] != dexNoIndex {
+		if c.SourceFile, err = f.GetString(def[4]); err != nil {
+			return nil, err
+		}
+	}
+	if def[6] != 0 {
+		if err := f.readClassData(c, def[6]); err != nil {
+			return nil, fmt.Errorf("class %s: %w", c.Name, err)
+		}
+	}
+	return c, nil
+}
+
+// readClassData reads the fields and methods of the class_data_item at off
+// into c.
+func (f *DexFile) readClassData(c *DexClass, off uint32) error {
+	if off >= uint32(len(f.data)) {
+		return fmt.Errorf("apkparser: dex class data offset 0x%x out of bounds", off)
+	}
+	r := &uleb128Reader{data: f.data[off:]}
+	var sizes [4]uint32 // static fields, instance fields, direct methods, virtual methods
+	for i := range sizes {
+		sizes[i] = r.read()
+		// every entry takes 2 bytes at least.
+		if uint64(sizes[i]) > uint64(len(r.data)) {
+			return fmt.Errorf("apkparser: invalid class data at 0x%x", off)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		var idx uint32
+		for n := uint32(0); n < sizes[i] && r.err == nil; n++ {
+			idx += r.read()
+			flags := AccessFlags(r.read())
+			name, typ, err := f.Field(idx)
+			if err != nil {
+				return err
+			}
+			c.Fields = append(c.Fields, DexField{Name: name, Type: typ, AccessFlags: flags})
+		}
+	}
+	for i := 2; i < 4; i++ {
+		var idx uint32
+		for n := uint32(0); n < sizes[i] && r.err == nil; n++ {
+			idx += r.read()
+			flags := AccessFlags(r.read())
+			r.read() // code_off
+			name, proto, err := f.Method(idx)
+			if err != nil {
+				return err
+			}
+			c.Methods = append(c.Methods, DexMethod{Name: name, Proto: proto, AccessFlags: flags, Direct: i == 2})
+		}
+	}
+	if r.err != nil {
+		return fmt.Errorf("apkparser: invalid class data at 0x%x: %w", off, r.err)
+	}
+	return nil
+}
+
+// uleb128Reader reads consecutive unsigned LEB128 values, keeping the first
+// error.
+type uleb128Reader struct {
+	data []byte
+	err  error
+}
+
+func (r *uleb128Reader) read() uint32 {
+	if r.err != nil {
+		return 0
+	}
+	v, size := readUleb128(r.data)
+	if size == 0 {
+		r.err = errors.New("truncated uleb128")
+		return 0
+	}
+	r.data = r.data[size:]
+	return v
+}
+
+// DexClassIterator iterates over the classes of one or more DEX files:
+//
+//	for it.Next() {
+//		c := it.Class()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type DexClassIterator struct {
+	files []*DexFile
+	names []string
+	file  int
+	idx   uint32
+	class *DexClass
+	err   error
+}
+
+// Classes returns an iterator over the classes of the DEX file.
+func (f *DexFile) Classes() *DexClassIterator {
+	return newDexClassIterator([]*DexFile{f}, []string{""})
+}
+
+// newDexClassIterator returns an iterator over the classes of files, named
+// names in the APK.
+func newDexClassIterator(files []*DexFile, names []string) *DexClassIterator {
+	return &DexClassIterator{files: files, names: names}
+}
+
+// Next advances to the next class, returning false at the end of the
+// classes or on an error.
+func (it *DexClassIterator) Next() bool {
+	it.class = nil
+	for it.err == nil && it.file < len(it.files) {
+		f := it.files[it.file]
+		if it.idx >= f.Header.ClassDefsSize {
+			it.file++
+			it.idx = 0
+			continue
+		}
+		c, err := f.ClassDef(it.idx)
+		it.idx++
+		if err != nil {
+			if name := it.names[it.file]; name != "" {
+				err = fmt.Errorf("%s: %w", name, err)
+			}
+			it.err = err
+			return false
+		}
+		c.Dex = it.names[it.file]
+		it.class = c
+		return true
+	}
+	return false
+}
+
+// Class returns the current class.
+func (it *DexClassIterator) Class() *DexClass {
+	return it.class
+}
+
+// Err returns the error which stopped the iteration, if any.
+func (it *DexClassIterator) Err() error {
+	return it.err
+}
+
+// dexClasses returns an iterator over the classes of the DEX files of the
+// APK, in load order.
+func (k *apk) dexClasses() (*DexClassIterator, error) {
+	files, names, err := k.dexFiles()
+	if err != nil {
+		return nil, err
+	}
+	return newDexClassIterator(files, names), nil
+}
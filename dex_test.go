@@ -0,0 +1,241 @@
+package apkparser
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestDexTypeDescriptors(t *testing.T) {
+	// type_ids are sorted like string_ids, by descriptor.
+	want := []string{"I", "Lcom/example/app/Main$1;", "Lcom/example/app/Main;", "Ljava/lang/Object;", "[Ljava/lang/String;"}
+	f, err := NewDexFile(encodeDex(want...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := f.TypeDescriptors()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TypeDescriptors() = %q, want %q", got, want)
+	}
+	if _, err := f.TypeDescriptor(uint32(len(want))); err == nil {
+		t.Error("TypeDescriptor out of range: no error")
+	}
+	if name := ClassName("Lcom/example/app/Main$1;"); name != "com.example.app.Main$1" {
+		t.Errorf("ClassName = %q", name)
+	}
+
+	if _, err := NewDexFile([]byte("PK\x03\x04")); err == nil {
+		t.Error("NewDexFile of a zip file: no error")
+	}
+
+	// the tables of a hostile header must fit in the file, lest they be
+	// allocated from their sizes.
+	hostile := encodeDex("La;")
+	binary.LittleEndian.PutUint32(hostile[0x40:], 0x40000000) // type_ids_size
+	if _, err := NewDexFile(hostile); err == nil {
+		t.Error("NewDexFile of a type_ids table out of bounds: no error")
+	}
+	hostile = encodeDex("La;")
+	binary.LittleEndian.PutUint32(hostile[0x64:], 0xfffffff0) // class_defs_off
+	binary.LittleEndian.PutUint32(hostile[0x60:], 1)          // class_defs_size
+	if _, err := NewDexFile(hostile); err == nil {
+		t.Error("NewDexFile of a class_defs table out of bounds: no error")
+	}
+
+	var limitErr *LimitError
+	if _, err := newDexFile(encodeDex(want...), Limits{MaxDexItems: 4}); !errors.As(err, &limitErr) || limitErr.Limit != "MaxDexItems" {
+		t.Errorf("newDexFile() of %d types error = %v, want LimitError", len(want), err)
+	}
+}
+
+func FuzzDexFile(f *testing.F) {
+	f.Add(encodeDex("I", "Ljava/lang/Object;"))
+	f.Add(encodeDexClasses(nil, testDexClass{
+		Descriptor: "Lcom/example/app/Main;",
+		Superclass: "Ljava/lang/Object;",
+		Fields:     []testDexMember{{"count", "I", AccPrivate}},
+		Methods:    []testDexMember{{"run", "(I)V", AccPublic}},
+	}))
+	hostile := encodeDex("La;")
+	binary.LittleEndian.PutUint32(hostile[0x40:], 0x40000000) // type_ids_size
+	f.Add(hostile)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		dex, err := newDexFile(data, DefaultLimits)
+		if err != nil {
+			return
+		}
+		_ = dex.Info("classes.dex")
+		_, _ = dex.TypeDescriptors()
+		it := newDexClassIterator([]*DexFile{dex}, []string{"classes.dex"})
+		for it.Next() {
+			_ = it.Class()
+		}
+	})
+}
+
+func TestDecodeMUTF8(t *testing.T) {
+	for _, tt := range []struct {
+		data []byte
+		want string
+	}{
+		{[]byte("abc"), "abc"},
+		{[]byte{0xc0, 0x80}, "\x00"},
+		{[]byte("caf\xc3\xa9"), "café"},
+		// U+1F600 as a surrogate pair of 3 byte sequences.
+		{[]byte{0xed, 0xa0, 0xbd, 0xed, 0xb8, 0x80}, "\U0001F600"},
+	} {
+		got, err := decodeMUTF8(tt.data, len(tt.data))
+		if err != nil || got != tt.want {
+			t.Errorf("decodeMUTF8(% x) = %q, %v; want %q", tt.data, got, err, tt.want)
+		}
+	}
+}
+
+func TestDexReport(t *testing.T) {
+	classes2 := encodeDex("Lcom/example/app/Feature;")
+	copy(classes2[4:7], "039")
+	signDex(classes2)
+	corrupt := encodeDex("Lcom/example/app/Main;", "Ljava/lang/Object;")
+	corrupt[len(corrupt)-1] ^= 0xff
+
+	name := writeAPK(t,
+		zipFile{"AndroidManifest.xml", encodeXML(testManifest())},
+		zipFile{"resources.arsc", testTable()},
+		zipFile{"classes.dex", corrupt},
+		zipFile{"classes2.dex", classes2},
+	)
+	info, err := New(name, Option{WithDex: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := info.Dex
+	if report == nil {
+		t.Fatal("no dex report")
+	}
+	if !report.Multidex || report.MinSdkVersion != 28 || report.Types != 3 || report.Strings != 3 {
+		t.Errorf("report = %+v", report)
+	}
+	if len(report.Files) != 2 {
+		t.Fatalf("files = %+v", report.Files)
+	}
+	f := report.Files[0]
+	if f.Name != "classes.dex" || f.Version != 35 || f.MinSdkVersion != 1 || f.Size != len(corrupt) || f.ChecksumValid || f.SignatureValid {
+		t.Errorf("classes.dex = %+v", f)
+	}
+	f = report.Files[1]
+	if f.Name != "classes2.dex" || f.Version != 39 || f.MinSdkVersion != 28 || !f.ChecksumValid || !f.SignatureValid {
+		t.Errorf("classes2.dex = %+v", f)
+	}
+
+	unknown := encodeDex("I")
+	copy(unknown[4:7], "036")
+	if _, err := NewDexFile(unknown); err == nil {
+		t.Error("NewDexFile of version 036: no error")
+	}
+}
+
+func TestDexClasses(t *testing.T) {
+	classes := encodeDexClasses(nil,
+		testDexClass{
+			Descriptor: "Lcom/example/app/Main;",
+			Flags:      AccPublic | AccFinal,
+			Superclass: "Landroid/app/Activity;",
+			Interfaces: []string{"Ljava/lang/Runnable;", "Landroid/view/View$OnClickListener;"},
+			SourceFile: "Main.java",
+			Fields: []testDexMember{
+				{"count", "I", AccPrivate},
+				{"TAG", "Ljava/lang/String;", AccPrivate | AccStatic | AccFinal},
+			},
+			Methods: []testDexMember{
+				{"run", "()V", AccPublic},
+				{"<init>", "()V", AccPublic | AccConstructor},
+				{"onClick", "(Landroid/view/View;)V", AccPublic},
+				{"a", "(I[Ljava/lang/String;J)Z", AccPrivate | AccStatic},
+			},
+		},
+		testDexClass{
+			Descriptor: "La/b;",
+			Flags:      AccPublic | AccInterface | AccAbstract,
+			Superclass: "Ljava/lang/Object;",
+		},
+	)
+	classes2 := encodeDexClasses(nil, testDexClass{Descriptor: "Lcom/example/app/Main$1;", Superclass: "Ljava/lang/Object;"})
+	name := writeAPK(t,
+		zipFile{"AndroidManifest.xml", encodeXML(testManifest())},
+		zipFile{"resources.arsc", testTable()},
+		zipFile{"classes.dex", classes},
+		zipFile{"classes2.dex", classes2},
+	)
+
+	it, err := ReadDexClasses(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []*DexClass
+	for it.Next() {
+		got = append(got, it.Class())
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("classes = %+v", got)
+	}
+
+	main := got[0]
+	if main.Dex != "classes.dex" || main.Name != "com.example.app.Main" || main.Superclass != "android.app.Activity" ||
+		main.SourceFile != "Main.java" || main.AccessFlags.String() != "public final" {
+		t.Errorf("class = %+v", main)
+	}
+	if want := []string{"java.lang.Runnable", "android.view.View$OnClickListener"}; !reflect.DeepEqual(main.Interfaces, want) {
+		t.Errorf("interfaces = %q, want %q", main.Interfaces, want)
+	}
+	wantFields := []DexField{
+		{Name: "TAG", Type: "Ljava/lang/String;", AccessFlags: AccPrivate | AccStatic | AccFinal},
+		{Name: "count", Type: "I", AccessFlags: AccPrivate},
+	}
+	if !reflect.DeepEqual(main.Fields, wantFields) {
+		t.Errorf("fields:\ngot  %+v\nwant %+v", main.Fields, wantFields)
+	}
+	var methods []string
+	for _, m := range main.Methods {
+		methods = append(methods, fmt.Sprintf("%s %s%s %s direct=%v", m.AccessFlags, m.Name, m.Proto.Descriptor(), m.Proto.Shorty, m.Direct))
+	}
+	wantMethods := []string{
+		"public constructor <init>()V V direct=true",
+		"private static a(I[Ljava/lang/String;J)Z ZILJ direct=true",
+		"public run()V V direct=false",
+		"public onClick(Landroid/view/View;)V VL direct=false",
+	}
+	if !reflect.DeepEqual(methods, wantMethods) {
+		t.Errorf("methods:\ngot  %q\nwant %q", methods, wantMethods)
+	}
+
+	if c := got[1]; c.Name != "a.b" || c.AccessFlags&AccInterface == 0 || c.SourceFile != "" || c.Fields != nil || c.Methods != nil {
+		t.Errorf("interface = %+v", c)
+	}
+	if c := got[2]; c.Dex != "classes2.dex" || c.Name != "com.example.app.Main$1" {
+		t.Errorf("classes2.dex class = %+v", c)
+	}
+
+	// an invalid class stops the iteration with an error.
+	f, err := NewDexFile(classes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	binary.LittleEndian.PutUint32(classes[f.Header.ClassDefsOff+32+24:], uint32(len(classes))) // class_data_off
+	it = f.Classes()
+	n := 0
+	for it.Next() {
+		n++
+	}
+	if n != 1 || it.Err() == nil {
+		t.Errorf("truncated dex: %d classes, error %v", n, it.Err())
+	}
+}
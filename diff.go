@@ -0,0 +1,291 @@
+package apkparser
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Sections of the changes between two APKs, in the order they are listed.
+const (
+	SectionManifest    = "manifest"
+	SectionPermission  = "permission"
+	SectionComponent   = "component"
+	SectionFeature     = "feature"
+	SectionResource    = "resource"
+	SectionNative      = "native"
+	SectionDex         = "dex"
+	SectionCertificate = "certificate"
+	SectionSize        = "size"
+	SectionEntry       = "entry"
+)
+
+var diffSections = []string{
+	SectionManifest, SectionPermission, SectionComponent, SectionFeature, SectionResource,
+	SectionNative, SectionDex, SectionCertificate, SectionSize, SectionEntry,
+}
+
+// Kinds of changes.
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeChanged = "changed"
+)
+
+// Change is a difference between two APKs.
+type Change struct {
+	Section string `json:"section"`
+	Kind    string `json:"kind"` // added, removed or changed
+	Name    string `json:"name"` // e.g. versionCode, android.permission.CAMERA or @string/app_name
+	Old     string `json:"old,omitempty"`
+	New     string `json:"new,omitempty"`
+}
+
+// APKDiff is the differences between two builds of an app.
+type APKDiff struct {
+	Old     string   `json:"old"` // path of the old APK
+	New     string   `json:"new"` // path of the new APK
+	Changes []Change `json:"changes"`
+}
+
+// diffSnapshot are the properties of an APK which are compared, by section
+// and name.
+type diffSnapshot map[string]map[string]string
+
+func (s diffSnapshot) set(section, name, value string) {
+	if s[section] == nil {
+		s[section] = make(map[string]string)
+	}
+	s[section][name] = value
+}
+
+// Diff compares the APK files oldPath and newPath: their manifests,
+// resources, zip entries and sizes, native libraries, DEX files and signing
+// certificates.
+func Diff(oldPath, newPath string) (*APKDiff, error) {
+	oldSnapshot, err := snapshotFile(oldPath)
+	if err != nil {
+		return nil, err
+	}
+	newSnapshot, err := snapshotFile(newPath)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &APKDiff{Old: oldPath, New: newPath, Changes: []Change{}}
+	for _, section := range diffSections {
+		oldValues, newValues := oldSnapshot[section], newSnapshot[section]
+		var names []string
+		for name := range oldValues {
+			names = append(names, name)
+		}
+		for name := range newValues {
+			if _, ok := oldValues[name]; !ok {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			oldValue, inOld := oldValues[name]
+			newValue, inNew := newValues[name]
+			switch {
+			case !inOld:
+				d.Changes = append(d.Changes, Change{Section: section, Kind: ChangeAdded, Name: name, New: newValue})
+			case !inNew:
+				d.Changes = append(d.Changes, Change{Section: section, Kind: ChangeRemoved, Name: name, Old: oldValue})
+			case oldValue != newValue:
+				d.Changes = append(d.Changes, Change{Section: section, Kind: ChangeChanged, Name: name, Old: oldValue, New: newValue})
+			}
+		}
+	}
+	return d, nil
+}
+
+// Section returns the changes of section.
+func (d *APKDiff) Section(section string) []Change {
+	var changes []Change
+	for _, c := range d.Changes {
+		if c.Section == section {
+			changes = append(changes, c)
+		}
+	}
+	return changes
+}
+
+// WriteText writes the changes to w, grouped by section, one per line
+// prefixed with +, - or ~ for added, removed and changed.
+func (d *APKDiff) WriteText(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "--- %s\n+++ %s\n", d.Old, d.New); err != nil {
+		return err
+	}
+	section := ""
+	for _, c := range d.Changes {
+		if c.Section != section {
+			section = c.Section
+			if _, err := fmt.Fprintf(w, "\n%s\n", section); err != nil {
+				return err
+			}
+		}
+		var line string
+		switch c.Kind {
+		case ChangeAdded:
+			line = "+ " + c.Name + formatDiffValue(c.New)
+		case ChangeRemoved:
+			line = "- " + c.Name + formatDiffValue(c.Old)
+		default:
+			line = fmt.Sprintf("~ %s: %s -> %s", c.Name, c.Old, c.New)
+		}
+		if _, err := fmt.Fprintf(w, "  %s\n", line); err != nil {
+			return err
+		}
+	}
+	if len(d.Changes) == 0 {
+		_, err := fmt.Fprintln(w, "\nno changes")
+		return err
+	}
+	return nil
+}
+
+func formatDiffValue(value string) string {
+	if value == "" {
+		return ""
+	}
+	return " (" + value + ")"
+}
+
+// snapshotFile opens the APK file name and takes its snapshot.
+func snapshotFile(name string) (diffSnapshot, error) {
+	k, err := openFile(name, Option{})
+	if err != nil {
+		return nil, err
+	}
+	defer k.close()
+
+	return k.snapshot(), nil
+}
+
+// snapshot returns the properties of the APK which Diff compares.
+func (k *apk) snapshot() diffSnapshot {
+	s := make(diffSnapshot)
+	m := &k.apkManifest
+
+	s.set(SectionManifest, "package", m.Package)
+	s.set(SectionManifest, "versionName", m.VersionName)
+	s.set(SectionManifest, "versionCode", strconv.FormatInt(m.VersionCode, 10))
+	s.set(SectionManifest, "minSdkVersion", strconv.Itoa(m.SDK.Min))
+	s.set(SectionManifest, "targetSdkVersion", strconv.Itoa(m.SDK.Target))
+	s.set(SectionManifest, "maxSdkVersion", strconv.Itoa(m.SDK.Max))
+	s.set(SectionManifest, "label", k.parseApkLabel())
+	s.set(SectionManifest, "application", k.qualifiedClassName(m.App.Name))
+	s.set(SectionManifest, "debuggable", strconv.FormatBool(m.App.Debuggable))
+
+	for _, p := range k.permissions() {
+		var attrs []string
+		if p.SDK23 {
+			attrs = append(attrs, "sdk-23")
+		}
+		if p.MaxSdkVersion > 0 {
+			attrs = append(attrs, "maxSdkVersion="+strconv.Itoa(p.MaxSdkVersion))
+		}
+		s.set(SectionPermission, p.Name, strings.Join(attrs, ", "))
+	}
+
+	for _, c := range k.attackSurface().Components {
+		value := "not exported"
+		if c.Exported {
+			value = "exported"
+		}
+		for _, g := range []struct {
+			name  string
+			guard *PermissionGuard
+		}{{"permission", c.Permission}, {"readPermission", c.ReadPermission}, {"writePermission", c.WritePermission}} {
+			if g.guard != nil {
+				value += ", " + g.name + "=" + g.guard.Name
+			}
+		}
+		s.set(SectionComponent, c.Kind+" "+c.Name, value)
+	}
+
+	if req := k.featureRequirements(); req != nil {
+		for _, f := range req.Features {
+			value := "optional"
+			if f.Required {
+				value = "required"
+			}
+			if f.Implied {
+				value += ", implied"
+			}
+			s.set(SectionFeature, f.Name, value)
+		}
+		s.set(SectionFeature, "glEsVersion", req.GLESVersion.String())
+	}
+
+	if k.table != nil {
+		for _, res := range k.table.Resources() {
+			name := "@" + res.Type + "/" + res.Name
+			if res.Package != m.Package {
+				name = "@" + res.Package + ":" + res.Type + "/" + res.Name
+			}
+			for _, v := range res.Values {
+				key := name
+				if v.Qualifiers != "" {
+					key += " [" + v.Qualifiers + "]"
+				}
+				s.set(SectionResource, key, formatResourceValue(v))
+			}
+		}
+	}
+
+	sizes := k.sizeReport()
+	s.set(SectionSize, "apk", strconv.FormatInt(sizes.Size, 10))
+	for _, g := range sizes.Categories {
+		s.set(SectionSize, g.Name, strconv.FormatUint(g.CompressedSize, 10))
+	}
+	for _, e := range sizes.Entries {
+		s.set(SectionEntry, e.Name, fmt.Sprintf("%d bytes, %d compressed, %s, crc32 %08x",
+			e.UncompressedSize, e.CompressedSize, e.Method, e.CRC32))
+		if e.Category == CategoryNative && strings.HasSuffix(e.Name, ".so") {
+			s.set(SectionNative, strings.TrimPrefix(e.Name, "lib/"), fmt.Sprintf("%d bytes", e.UncompressedSize))
+		}
+	}
+	s.set(SectionNative, "abis", strings.Join(k.abis, ", "))
+
+	report, err := k.dexReport()
+	if err != nil {
+		s.set(SectionDex, "error", err.Error())
+	}
+	if report != nil {
+		s.set(SectionDex, "files", strconv.Itoa(len(report.Files)))
+		s.set(SectionDex, "methods", strconv.Itoa(report.Methods))
+		s.set(SectionDex, "fields", strconv.Itoa(report.Fields))
+		s.set(SectionDex, "classes", strconv.Itoa(report.ClassDefs))
+		s.set(SectionDex, "strings", strconv.Itoa(report.Strings))
+		for _, f := range report.Files {
+			s.set(SectionDex, f.Name, fmt.Sprintf("version %03d, %d methods, %d fields, %d classes",
+				f.Version, f.Methods, f.Fields, f.ClassDefs))
+		}
+	}
+
+	// unsigned APKs have no certificate.
+	if cert, err := k.loadSignature(); err == nil {
+		s.set(SectionCertificate, "sha256", cert.Sha256)
+	}
+	return s
+}
+
+// formatResourceValue formats the value of a resource on one line.
+func formatResourceValue(v ResourceValue) string {
+	if v.Items == nil && v.Parent == "" {
+		return v.Value
+	}
+	var items []string
+	if v.Parent != "" {
+		items = append(items, "parent="+v.Parent)
+	}
+	for _, item := range v.Items {
+		items = append(items, item.Name+"="+item.Value)
+	}
+	return "{" + strings.Join(items, ", ") + "}"
+}
@@ -0,0 +1,97 @@
+package apkparser
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	permission := func(name string) *xmlElement {
+		return &xmlElement{Name: "uses-permission", Attrs: []xmlAttr{{NS: androidNamespace, Name: "name", Value: name}}}
+	}
+	oldManifest := testManifest(permission("android.permission.INTERNET"), permission("android.permission.READ_CONTACTS"))
+	newManifest := testManifest(permission("android.permission.INTERNET"), permission("android.permission.CAMERA"))
+	newManifest.Attrs[1].Data = 43
+	newTable := encodeTable(0x7f, "com.example.app", []tableType{
+		{ID: 1, Name: "string", Entries: []*tableValue{
+			{Name: "app_name", Type: TypeString, String: "Example 2"},
+			{Name: "untranslated", Type: TypeString, String: "Only English"},
+		}},
+		{ID: 2, Name: "color", Entries: []*tableValue{
+			{Name: "accent", Type: TypeIntColorARGB8, Data: 0xff336699},
+		}},
+	})
+
+	oldName := writeAPK(t,
+		zipFile{"AndroidManifest.xml", encodeXML(oldManifest)},
+		zipFile{"resources.arsc", testTable()},
+		zipFile{"classes.dex", encodeDex("Ljava/lang/Object;")},
+		zipFile{"lib/arm64-v8a/libnative.so", []byte("\x7fELF")},
+	)
+	newName := writeAPK(t,
+		zipFile{"AndroidManifest.xml", encodeXML(newManifest)},
+		zipFile{"resources.arsc", newTable},
+		zipFile{"classes.dex", encodeDex("Ljava/lang/Object;")},
+		zipFile{"lib/arm64-v8a/libnative.so", []byte("\x7fELF")},
+		zipFile{"lib/x86_64/libnative.so", []byte("\x7fELF")},
+	)
+
+	same, err := Diff(oldName, oldName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(same.Changes) != 0 {
+		t.Errorf("changes of an APK to itself: %+v", same.Changes)
+	}
+
+	diff, err := Diff(oldName, newName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		section string
+		want    []Change
+	}{
+		{SectionManifest, []Change{
+			{SectionManifest, ChangeChanged, "versionCode", "42", "43"},
+		}},
+		{SectionPermission, []Change{
+			{SectionPermission, ChangeAdded, "android.permission.CAMERA", "", ""},
+			{SectionPermission, ChangeRemoved, "android.permission.READ_CONTACTS", "", ""},
+		}},
+		{SectionFeature, []Change{
+			{SectionFeature, ChangeAdded, "android.hardware.camera", "", "required, implied"},
+			{SectionFeature, ChangeAdded, "android.hardware.camera.autofocus", "", "required, implied"},
+		}},
+		{SectionResource, []Change{
+			{SectionResource, ChangeChanged, "@string/app_name", "Example", "Example 2"},
+			{SectionResource, ChangeRemoved, "@string/app_name [fr]", "Exemple", ""},
+			{SectionResource, ChangeRemoved, "@string/french_only [fr]", "Seulement en français", ""},
+		}},
+		{SectionNative, []Change{
+			{SectionNative, ChangeChanged, "abis", "arm64-v8a", "arm64-v8a, x86_64"},
+			{SectionNative, ChangeAdded, "x86_64/libnative.so", "", "4 bytes"},
+		}},
+		{SectionDex, nil},
+	} {
+		if got := diff.Section(tt.section); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s changes:\ngot  %+v\nwant %+v", tt.section, got, tt.want)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := diff.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"\npermission\n  + android.permission.CAMERA\n  - android.permission.READ_CONTACTS\n",
+		"  ~ versionCode: 42 -> 43\n",
+		"  + lib/x86_64/libnative.so (4 bytes, ",
+	} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("text diff doesn't contain %q:\n%s", line, buf.String())
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package apkparser
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"strings"
+)
+
+// adaptiveIconSize is the edge length used to compose an adaptive icon
+// whose layers are all solid colors.
+const adaptiveIconSize = 432
+
+// legacyIconSDKVersion is the platform version used to look up the bitmap
+// fallback of a drawable, before vector (API 21) and adaptive (API 26)
+// drawables existed.
+const legacyIconSDKVersion = 20
+
+// adaptiveIcon is an adaptive launcher icon, res/mipmap-anydpi-v26/*.xml.
+// https://developer.android.com/develop/ui/views/launch/icon_design_adaptive
+type adaptiveIcon struct {
+	XMLName    xml.Name          `xml:"adaptive-icon"`
+	Background adaptiveIconLayer `xml:"background"`
+	Foreground adaptiveIconLayer `xml:"foreground"`
+}
+
+// adaptiveIconLayer is the background or foreground layer of an adaptive icon.
+type adaptiveIconLayer struct {
+	Drawable string `xml:"drawable,attr"`
+}
+
+// errNoDrawable is returned by image if no drawable is referenced.
+var errNoDrawable = errors.New("no drawable declared")
+
+// image resolves the drawable referenced by ref and decodes it.
+// When the best match for resConfig can't be decoded, e.g. it is a vector
+// drawable, the bitmap provided for older platforms is used instead.
+func (k *apk) image(ref string, resConfig *ResTableConfig) (image.Image, error) {
+	if ref == "" {
+		return nil, errNoDrawable
+	}
+	id, err := ParseResID(ref)
+	if err != nil {
+		return nil, err
+	}
+	m, err := k.drawable(id, resConfig, 0)
+	if err == nil {
+		return m, nil
+	}
+
+	legacy := ResTableConfig{}
+	if resConfig != nil {
+		legacy = *resConfig
+	}
+	if legacy.SDKVersion == 0 || legacy.SDKVersion > legacyIconSDKVersion {
+		legacy.SDKVersion = legacyIconSDKVersion
+		if m, errLegacy := k.drawable(id, &legacy, 0); errLegacy == nil {
+			return m, nil
+		}
+	}
+	return nil, err
+}
+
+// drawable decodes the drawable resource id. Bitmaps, nine-patches, colors
+// and adaptive icons are supported.
+func (k *apk) drawable(id ResID, resConfig *ResTableConfig, depth int) (image.Image, error) {
+	if depth >= maxReferenceDepth {
+		return nil, fmt.Errorf("too many nested drawables resolving %s", id)
+	}
+	v, err := k.table.getValue(id, resConfig)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case v.DataType >= TypeFirstColorInt && v.DataType <= TypeLastColorInt:
+		return image.NewUniform(argbColor(v.Data)), nil
+	case v.DataType != TypeString:
+		return nil, fmt.Errorf("drawable %s is not a file", id)
+	}
+
+	name, err := k.table.getString(ResStringPoolRef(v.Data))
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(name, ".xml") {
+		return k.adaptiveIcon(name, resConfig, depth)
+	}
+	data, err := k.readZipFile(name)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case strings.HasSuffix(name, ".9.png"):
+		np, err := DecodeNinePatch(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("decode %s: %w", name, err)
+		}
+		return np.Image, nil
+	}
+	m, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode %s: %w", name, err)
+	}
+	return m, nil
+}
+
+// adaptiveIcon composes the layers of the adaptive icon stored in name, and
+// crops the result to the part a launcher shows.
+func (k *apk) adaptiveIcon(name string, resConfig *ResTableConfig, depth int) (image.Image, error) {
+	xmlFile, err := k.readXML(name)
+	if err != nil {
+		return nil, err
+	}
+	text, err := io.ReadAll(xmlFile.Reader())
+	if err != nil {
+		return nil, err
+	}
+	var icon adaptiveIcon
+	if err := xml.Unmarshal(text, &icon); err != nil {
+		return nil, fmt.Errorf("%s is not an adaptive icon: %w", name, err)
+	}
+
+	var layers []image.Image
+	for _, layer := range []adaptiveIconLayer{icon.Background, icon.Foreground} {
+		if layer.Drawable == "" {
+			continue
+		}
+		id, err := ParseResID(layer.Drawable)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		m, err := k.drawable(id, resConfig, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, m)
+	}
+	if len(layers) == 0 {
+		return nil, fmt.Errorf("%s has no layers", name)
+	}
+
+	// layers are 108dp square, of which the inner 72dp are visible.
+	size := adaptiveIconSize
+	for _, m := range layers {
+		if _, ok := m.(*image.Uniform); !ok && m.Bounds().Dx() > 0 {
+			size = m.Bounds().Dx()
+		}
+	}
+	canvas := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for _, m := range layers {
+		if _, ok := m.(*image.Uniform); !ok {
+			m = scaleImage(m, size, size)
+		}
+		draw.Draw(canvas, canvas.Bounds(), m, image.Point{}, draw.Over)
+	}
+	inset := size / 6
+	return canvas.SubImage(image.Rect(inset, inset, size-inset, size-inset)), nil
+}
+
+// scaleImage resizes m to w x h using nearest-neighbor sampling.
+func scaleImage(m image.Image, w, h int) *image.NRGBA {
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	b := m.Bounds()
+	if b.Empty() {
+		return dst
+	}
+	for y := 0; y < h; y++ {
+		sy := b.Min.Y + y*b.Dy()/h
+		for x := 0; x < w; x++ {
+			sx := b.Min.X + x*b.Dx()/w
+			dst.Set(x, y, m.At(sx, sy))
+		}
+	}
+	return dst
+}
+
+// argbColor converts a packed 0xAARRGGBB color value.
+func argbColor(c uint32) color.NRGBA {
+	return color.NRGBA{
+		A: uint8(c >> 24),
+		R: uint8(c >> 16),
+		G: uint8(c >> 8),
+		B: uint8(c),
+	}
+}
@@ -0,0 +1,158 @@
+package apkparser
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+var (
+	testRed    = color.NRGBA{R: 0xff, A: 0xff}
+	testGreen  = color.NRGBA{G: 0xff, A: 0xff}
+	testBlue   = color.NRGBA{B: 0xff, A: 0xff}
+	testYellow = color.NRGBA{R: 0xff, G: 0xff, A: 0xff}
+)
+
+// encodePNG returns a w x h png filled with c, of which only the square
+// [inner, w-inner) is filled if inner is positive.
+func encodePNG(t *testing.T, w, h, inner int, c color.Color) []byte {
+	t.Helper()
+	m := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := inner; y < h-inner; y++ {
+		for x := inner; x < w-inner; x++ {
+			m.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, m); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// iconAPK returns an APK declaring the drawables of the application and
+// activity attributes attrs and activityAttrs:
+//
+//	@0x7f010000 legacy, a vector drawable on API 26 and a red bitmap before
+//	@0x7f010001 round, a blue bitmap
+//	@0x7f010002 banner, a yellow bitmap
+//	@0x7f010003 adaptive, a red foreground over a green background
+//	@0x7f010004 foreground, red in the middle of a 12 x 12 bitmap
+//	@0x7f020000 background, green
+func iconAPK(t *testing.T, attrs, activityAttrs []xmlAttr) string {
+	t.Helper()
+	table := encodeTable(0x7f, "com.example.app", []tableType{
+		{ID: 1, Name: "drawable", Config: ResTableConfig{SDKVersion: 26}, Entries: []*tableValue{
+			{Name: "legacy", Type: TypeString, String: "res/drawable-v26/legacy.xml"},
+		}},
+		{ID: 1, Name: "drawable", Entries: []*tableValue{
+			{Name: "legacy", Type: TypeString, String: "res/drawable/legacy.png"},
+			{Name: "round", Type: TypeString, String: "res/drawable/round.png"},
+			{Name: "banner", Type: TypeString, String: "res/drawable/banner.png"},
+			{Name: "adaptive", Type: TypeString, String: "res/mipmap-anydpi-v26/adaptive.xml"},
+			{Name: "foreground", Type: TypeString, String: "res/drawable/foreground.png"},
+		}},
+		{ID: 2, Name: "color", Entries: []*tableValue{
+			{Name: "background", Type: TypeIntColorARGB8, Data: 0xff00ff00},
+		}},
+	})
+	manifest := testManifest(&xmlElement{Name: "application", Attrs: attrs, Children: []*xmlElement{
+		{Name: "activity", Attrs: append([]xmlAttr{{NS: androidNamespace, Name: "name", Value: ".Main"}}, activityAttrs...)},
+	}})
+	adaptive := &xmlElement{Name: "adaptive-icon", Children: []*xmlElement{
+		{Name: "background", Attrs: []xmlAttr{{NS: androidNamespace, Name: "drawable", Type: TypeReference, Data: 0x7f020000}}},
+		{Name: "foreground", Attrs: []xmlAttr{{NS: androidNamespace, Name: "drawable", Type: TypeReference, Data: 0x7f010004}}},
+	}}
+	return writeAPK(t,
+		zipFile{"AndroidManifest.xml", encodeXML(manifest)},
+		zipFile{"resources.arsc", table},
+		zipFile{"res/drawable-v26/legacy.xml", encodeXML(&xmlElement{Name: "vector"})},
+		zipFile{"res/drawable/legacy.png", encodePNG(t, 4, 4, 0, testRed)},
+		zipFile{"res/drawable/round.png", encodePNG(t, 4, 4, 0, testBlue)},
+		zipFile{"res/drawable/banner.png", encodePNG(t, 8, 4, 0, testYellow)},
+		zipFile{"res/mipmap-anydpi-v26/adaptive.xml", encodeXML(adaptive)},
+		zipFile{"res/drawable/foreground.png", encodePNG(t, 12, 12, 4, testRed)},
+	)
+}
+
+// drawableRef returns the attribute name referencing the drawable id.
+func drawableRef(name string, id uint32) xmlAttr {
+	return xmlAttr{NS: androidNamespace, Name: name, Type: TypeReference, Data: id}
+}
+
+func TestIcons(t *testing.T) {
+	name := iconAPK(t, []xmlAttr{
+		drawableRef("icon", 0x7f010000),
+		drawableRef("roundIcon", 0x7f010001),
+		drawableRef("logo", 0x7f010003),
+	}, []xmlAttr{drawableRef("banner", 0x7f010002)})
+	info, err := New(name, Option{WithIcon: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		m    image.Image
+		want color.Color
+	}{
+		{"legacy icon", info.Icon, testRed},
+		{"round icon", info.RoundIcon, testBlue},
+		// the banner of the activity, as the application has none.
+		{"banner", info.Banner, testYellow},
+	}
+	for _, tt := range tests {
+		if tt.m == nil {
+			t.Errorf("%s is nil", tt.name)
+			continue
+		}
+		b := tt.m.Bounds()
+		if got := color.NRGBAModel.Convert(tt.m.At(b.Min.X, b.Min.Y)); got != tt.want {
+			t.Errorf("%s is %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// the adaptive logo is cropped to the inner 8 x 8 of its 12 x 12 layers.
+	if info.Logo == nil {
+		t.Fatal("Logo is nil")
+	}
+	b := info.Logo.Bounds()
+	if b.Dx() != 8 || b.Dy() != 8 {
+		t.Errorf("Logo bounds = %v, want 8 x 8", b)
+	}
+	if got := color.NRGBAModel.Convert(info.Logo.At(b.Min.X, b.Min.Y)); got != testGreen {
+		t.Errorf("Logo background is %v, want %v", got, testGreen)
+	}
+	if got := color.NRGBAModel.Convert(info.Logo.At(6, 6)); got != testRed {
+		t.Errorf("Logo foreground is %v, want %v", got, testRed)
+	}
+}
+
+func TestIconFallbacks(t *testing.T) {
+	k, err := openFile(iconAPK(t, []xmlAttr{
+		drawableRef("icon", 0x7f010000),
+		drawableRef("banner", 0x7f010001),
+	}, []xmlAttr{drawableRef("banner", 0x7f010002)}), Option{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer k.close()
+
+	// the vector drawable of API 26 can't be decoded, unlike its bitmap.
+	if _, err := k.drawable(0x7f010000, iconResConfig, 0); err == nil {
+		t.Error("drawable() of a vector drawable succeeded")
+	}
+	if m, err := k.icon(iconResConfig); err != nil || color.NRGBAModel.Convert(m.At(0, 0)) != testRed {
+		t.Errorf("icon() = %v, want the red bitmap", err)
+	}
+
+	// the banner of the application takes precedence.
+	if m, err := k.banner(iconResConfig); err != nil || color.NRGBAModel.Convert(m.At(0, 0)) != testBlue {
+		t.Errorf("banner() = %v, want the banner of the application", err)
+	}
+	if _, err := k.roundIcon(iconResConfig); err == nil {
+		t.Error("roundIcon() without a round icon succeeded")
+	}
+}
@@ -0,0 +1,85 @@
+package apkparser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Errors of the parser, which may be wrapped in the errors returned, so
+// that they are tested with errors.Is.
+var (
+	// ErrNotAPK is returned if the file is not a zip archive.
+	ErrNotAPK = errors.New("apkparser: not an APK")
+	// ErrNoManifest is returned if the APK has no AndroidManifest.xml.
+	ErrNoManifest = errors.New("apkparser: AndroidManifest.xml not found")
+	// ErrEntryNotFound is returned if a zip entry of the APK is missing.
+	ErrEntryNotFound = errors.New("apkparser: zip entry not found")
+	// ErrMalformed is returned if a binary XML file or a resource table is
+	// corrupted.
+	ErrMalformed = errors.New("apkparser: malformed resource")
+	// ErrInvalidSignature is returned if the signature of the APK can't be
+	// verified.
+	ErrInvalidSignature = errors.New("apkparser: invalid signature")
+)
+
+// EntryError records an error and the operation and zip entry that caused
+// it, like os.PathError.
+type EntryError struct {
+	Op   string // e.g. open, read or parse
+	Name string // the zip entry, e.g. AndroidManifest.xml
+	Err  error
+}
+
+func (e *EntryError) Error() string {
+	return e.Op + " " + e.Name + ": " + e.Err.Error()
+}
+
+// Unwrap returns the cause of e.
+func (e *EntryError) Unwrap() error {
+	return e.Err
+}
+
+// ChunkError records an error and the resource chunk that caused it, in a
+// binary XML file or a resource table. Offset is relative to the parent
+// chunk, if any.
+type ChunkError struct {
+	Type   ChunkType
+	Offset int64
+	Err    error
+}
+
+func (e *ChunkError) Error() string {
+	return fmt.Sprintf("chunk 0x%04X at %d: %v", uint16(e.Type), e.Offset, e.Err)
+}
+
+// Unwrap returns the cause of e.
+func (e *ChunkError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrMalformed, which e is unless a limit is
+// exceeded.
+func (e *ChunkError) Is(target error) bool {
+	var limitErr *LimitError
+	return target == ErrMalformed && !errors.As(e.Err, &limitErr)
+}
+
+// kindError is an error of a kind, one of the errors of the parser, with the
+// error that caused it.
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string {
+	return e.kind.Error() + ": " + strings.TrimPrefix(e.err.Error(), "apkparser: ")
+}
+
+func (e *kindError) Is(target error) bool {
+	return target == e.kind
+}
+
+func (e *kindError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,88 @@
+package apkparser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestErrors(t *testing.T) {
+	manifest := encodeXML(testManifest())
+	corrupted := append([]byte(nil), manifest...)
+	// the size of the string pool, which follows the header of the file.
+	binary.LittleEndian.PutUint32(corrupted[12:], 0xffffffff)
+
+	notAPK := filepath.Join(t.TempDir(), "test.apk")
+	if err := os.WriteFile(notAPK, []byte("not a zip archive"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		apk    string
+		option Option
+		want   error
+		entry  string // of the EntryError, if any
+	}{
+		{"not an APK", notAPK, Option{}, ErrNotAPK, ""},
+		{"not an APK in tolerant mode", notAPK, Option{Tolerant: true}, ErrNotAPK, ""},
+		{"no manifest", writeAPK(t, zipFile{"resources.arsc", testTable()}), Option{}, ErrNoManifest, ""},
+		{"no resources", writeAPK(t, zipFile{"AndroidManifest.xml", manifest}), Option{}, ErrEntryNotFound, "resources.arsc"},
+		{
+			"corrupted manifest",
+			writeAPK(t, zipFile{"AndroidManifest.xml", corrupted}, zipFile{"resources.arsc", testTable()}),
+			Option{}, ErrMalformed, "AndroidManifest.xml",
+		},
+		{
+			"corrupted resources",
+			writeAPK(t, zipFile{"AndroidManifest.xml", manifest}, zipFile{"resources.arsc", testTable()[:20]}),
+			Option{}, ErrMalformed, "resources.arsc",
+		},
+		{
+			"unsigned",
+			writeAPK(t, zipFile{"AndroidManifest.xml", manifest}, zipFile{"resources.arsc", testTable()}),
+			Option{WithSignature: true}, ErrInvalidSignature, "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.apk, tt.option)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("New() error = %v, want %v", err, tt.want)
+			}
+			var entryErr *EntryError
+			if isEntry := errors.As(err, &entryErr); isEntry != (tt.entry != "") || isEntry && entryErr.Name != tt.entry {
+				t.Errorf("New() error = %v, want EntryError of %q", err, tt.entry)
+			}
+		})
+	}
+}
+
+func TestChunkError(t *testing.T) {
+	valid := encodeXML(testManifest())
+	// the string pool follows the header of the file, and the namespace and
+	// the root element follow the string pool.
+	poolSize := int(binary.LittleEndian.Uint32(valid[12:]))
+	element := 8 + poolSize + 24
+	data := append([]byte(nil), valid...)
+	binary.LittleEndian.PutUint32(data[element+20:], 1000)
+
+	_, err := NewXMLFile(bytes.NewReader(data))
+	var chunkErr *ChunkError
+	if !errors.As(err, &chunkErr) || chunkErr.Type != ResXMLStartElementType || chunkErr.Offset != int64(element) {
+		t.Errorf("NewXMLFile() error = %v, want ChunkError of the element at %d", err, element)
+	}
+	if !errors.Is(err, ErrMalformed) {
+		t.Errorf("NewXMLFile() error = %v, want ErrMalformed", err)
+	}
+
+	// exceeding a limit isn't malformed.
+	_, err = newXMLFile(bytes.NewReader(valid), Limits{MaxStringCount: 1})
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || errors.Is(err, ErrMalformed) {
+		t.Errorf("newXMLFile() error = %v, want LimitError", err)
+	}
+}
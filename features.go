@@ -0,0 +1,328 @@
+package apkparser
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sdkLollipop is the platform version from which location permissions no
+// longer imply the GPS and network location features.
+const sdkLollipop = 21
+
+// GLESVersion is an OpenGL ES version, with the major version in the upper
+// 16 bits and the minor version in the lower 16 bits, e.g. 0x00030001 for 3.1.
+type GLESVersion uint32
+
+// defaultGLESVersion is the OpenGL ES version of apps that declare none.
+const defaultGLESVersion GLESVersion = 0x00010000
+
+// Major returns the major version.
+func (v GLESVersion) Major() int {
+	return int(v >> 16)
+}
+
+// Minor returns the minor version.
+func (v GLESVersion) Minor() int {
+	return int(v & 0xffff)
+}
+
+// String returns the version, e.g. 3.1.
+func (v GLESVersion) String() string {
+	return fmt.Sprintf("%d.%d", v.Major(), v.Minor())
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (v GLESVersion) MarshalText() ([]byte, error) {
+	return []byte(v.String()), nil
+}
+
+// Feature is a hardware or software feature an app uses.
+type Feature struct {
+	Name     string `json:"name"`
+	Required bool   `json:"required"`
+	// Implied is set for the features Google Play derives from the
+	// permissions and activities of the app, explained by Reason.
+	Implied bool   `json:"implied,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// Configuration is a combination of input hardware an app requires,
+// <uses-configuration>.
+type Configuration struct {
+	FiveWayNav   bool   `json:"fiveWayNav,omitempty"`
+	HardKeyboard bool   `json:"hardKeyboard,omitempty"`
+	KeyboardType string `json:"keyboardType,omitempty"` // nokeys, qwerty or twelvekey
+	Navigation   string `json:"navigation,omitempty"`   // nonav, dpad, trackball or wheel
+	TouchScreen  string `json:"touchScreen,omitempty"`  // notouch, stylus or finger
+}
+
+// SupportsScreens are the screen sizes an app supports, <supports-screens>
+// with the defaults of its SDK versions applied.
+type SupportsScreens struct {
+	Small                   bool `json:"small"`
+	Normal                  bool `json:"normal"`
+	Large                   bool `json:"large"`
+	XLarge                  bool `json:"xlarge"`
+	Resizeable              bool `json:"resizeable"`
+	AnyDensity              bool `json:"anyDensity"`
+	RequiresSmallestWidthDp int  `json:"requiresSmallestWidthDp,omitempty"`
+	CompatibleWidthLimitDp  int  `json:"compatibleWidthLimitDp,omitempty"`
+	LargestWidthLimitDp     int  `json:"largestWidthLimitDp,omitempty"`
+}
+
+// CompatibleScreen is a screen configuration of <compatible-screens>.
+type CompatibleScreen struct {
+	Size    string `json:"size"`    // small, normal, large or xlarge
+	Density int    `json:"density"` // dpi
+}
+
+// Library is a shared library an app links against, <uses-library>.
+type Library struct {
+	Name     string `json:"name"`
+	Required bool   `json:"required"`
+}
+
+// FeatureRequirements are the requirements of an app on devices that Google
+// Play filters apps by.
+// https://developer.android.com/google/play/filters
+type FeatureRequirements struct {
+	Features          []Feature          `json:"features,omitempty"`
+	GLESVersion       GLESVersion        `json:"glEsVersion"` // required OpenGL ES version
+	Configurations    []Configuration    `json:"configurations,omitempty"`
+	SupportsScreens   SupportsScreens    `json:"supportsScreens"`
+	CompatibleScreens []CompatibleScreen `json:"compatibleScreens,omitempty"`
+	GLTextures        []string           `json:"glTextures,omitempty"` // texture compression formats
+	Libraries         []Library          `json:"libraries,omitempty"`
+}
+
+// Values of the enum attributes of <uses-configuration>; 0 is undefined.
+var (
+	keyboardTypes = []string{"", "nokeys", "qwerty", "twelvekey"}
+	navigations   = []string{"", "nonav", "dpad", "trackball", "wheel"}
+	touchScreens  = []string{"", "notouch", "stylus", "finger"}
+)
+
+// screenSizes are the values of the screenSize attribute of
+// <compatible-screens>.
+var screenSizes = map[string]string{
+	"200": "small",
+	"300": "normal",
+	"400": "large",
+	"500": "xlarge",
+}
+
+// screenDensities are the named values of the screenDensity attribute of
+// <compatible-screens>.
+var screenDensities = map[string]int{
+	"ldpi":    120,
+	"mdpi":    160,
+	"hdpi":    240,
+	"xhdpi":   320,
+	"xxhdpi":  480,
+	"xxxhdpi": 640,
+}
+
+// impliedFeatures are the features permissions imply, as Google Play does.
+// https://developer.android.com/guide/topics/manifest/uses-feature-element#permissions
+var impliedFeatures = map[string][]string{
+	"android.permission.ACCESS_COARSE_LOCATION":         {"android.hardware.location"},
+	"android.permission.ACCESS_FINE_LOCATION":           {"android.hardware.location"},
+	"android.permission.ACCESS_LOCATION_EXTRA_COMMANDS": {"android.hardware.location"},
+	"android.permission.ACCESS_MOCK_LOCATION":           {"android.hardware.location"},
+	"android.permission.INSTALL_LOCATION_PROVIDER":      {"android.hardware.location"},
+	"android.permission.ACCESS_WIFI_STATE":              {"android.hardware.wifi"},
+	"android.permission.CHANGE_WIFI_MULTICAST_STATE":    {"android.hardware.wifi"},
+	"android.permission.CHANGE_WIFI_STATE":              {"android.hardware.wifi"},
+	"android.permission.BLUETOOTH":                      {"android.hardware.bluetooth"},
+	"android.permission.BLUETOOTH_ADMIN":                {"android.hardware.bluetooth"},
+	"android.permission.CAMERA":                         {"android.hardware.camera", "android.hardware.camera.autofocus"},
+	"android.permission.RECORD_AUDIO":                   {"android.hardware.microphone"},
+	"android.permission.CALL_PHONE":                     {"android.hardware.telephony"},
+	"android.permission.CALL_PRIVILEGED":                {"android.hardware.telephony"},
+	"android.permission.MODIFY_PHONE_STATE":             {"android.hardware.telephony"},
+	"android.permission.PROCESS_OUTGOING_CALLS":         {"android.hardware.telephony"},
+	"android.permission.READ_SMS":                       {"android.hardware.telephony"},
+	"android.permission.RECEIVE_MMS":                    {"android.hardware.telephony"},
+	"android.permission.RECEIVE_SMS":                    {"android.hardware.telephony"},
+	"android.permission.RECEIVE_WAP_PUSH":               {"android.hardware.telephony"},
+	"android.permission.SEND_SMS":                       {"android.hardware.telephony"},
+	"android.permission.WRITE_APN_SETTINGS":             {"android.hardware.telephony"},
+	"android.permission.WRITE_SMS":                      {"android.hardware.telephony"},
+}
+
+// impliedBySubFeature maps sub-features to the feature they imply.
+var impliedBySubFeature = map[string]string{
+	"android.hardware.camera.autofocus":              "android.hardware.camera",
+	"android.hardware.camera.flash":                  "android.hardware.camera",
+	"android.hardware.location.gps":                  "android.hardware.location",
+	"android.hardware.location.network":              "android.hardware.location",
+	"android.hardware.telephony.cdma":                "android.hardware.telephony",
+	"android.hardware.telephony.gsm":                 "android.hardware.telephony",
+	"android.hardware.faketouch.multitouch.distinct": "android.hardware.faketouch",
+}
+
+// Values of android:screenOrientation that lock the orientation.
+var (
+	landscapeOrientations = []string{"0", "6", "8", "11", "landscape", "sensorLandscape", "reverseLandscape", "userLandscape"}
+	portraitOrientations  = []string{"1", "7", "9", "12", "portrait", "sensorPortrait", "reversePortrait", "userPortrait"}
+)
+
+// enumName returns the name of the value s of an enum attribute.
+func enumName(names []string, s string) string {
+	if i, err := strconv.Atoi(s); err == nil && i >= 0 && i < len(names) {
+		return names[i]
+	}
+	return s
+}
+
+// featureRequirements returns the feature requirements of the APK.
+func (k *apk) featureRequirements() *FeatureRequirements {
+	m := &k.apkManifest
+	req := &FeatureRequirements{GLESVersion: defaultGLESVersion}
+
+	declared := make(map[string]bool)
+	for _, f := range m.Features {
+		required := f.Required == nil || *f.Required
+		if f.Name == "" {
+			if v, err := strconv.ParseUint(strings.TrimPrefix(f.GLESVersion, "0x"), 16, 32); err == nil && required && GLESVersion(v) > req.GLESVersion {
+				req.GLESVersion = GLESVersion(v)
+			}
+			continue
+		}
+		declared[f.Name] = true
+		req.Features = append(req.Features, Feature{Name: f.Name, Required: required})
+	}
+	req.Features = append(req.Features, k.impliedFeatures(declared)...)
+
+	for _, c := range m.Configurations {
+		req.Configurations = append(req.Configurations, Configuration{
+			FiveWayNav:   c.FiveWayNav,
+			HardKeyboard: c.HardKeyboard,
+			KeyboardType: enumName(keyboardTypes, c.KeyboardType),
+			Navigation:   enumName(navigations, c.Navigation),
+			TouchScreen:  enumName(touchScreens, c.TouchScreen),
+		})
+	}
+	req.SupportsScreens = k.supportsScreens()
+	for _, s := range m.CompatibleScreens {
+		screen := CompatibleScreen{Size: s.ScreenSize}
+		if size, ok := screenSizes[s.ScreenSize]; ok {
+			screen.Size = size
+		}
+		if density, ok := screenDensities[s.ScreenDensity]; ok {
+			screen.Density = density
+		} else {
+			screen.Density, _ = strconv.Atoi(s.ScreenDensity)
+		}
+		req.CompatibleScreens = append(req.CompatibleScreens, screen)
+	}
+	for _, t := range m.GLTextures {
+		req.GLTextures = append(req.GLTextures, t.Name)
+	}
+	for _, l := range m.App.UsesLibraries {
+		req.Libraries = append(req.Libraries, Library{Name: l.Name, Required: l.Required == nil || *l.Required})
+	}
+	return req
+}
+
+// impliedFeatures returns the features Google Play implies from the
+// permissions, sub-features and activities of the APK, except the declared
+// ones, sorted by name.
+func (k *apk) impliedFeatures(declared map[string]bool) []Feature {
+	targetSdk := k.apkManifest.SDK.Target
+	if targetSdk == 0 {
+		targetSdk = k.apkManifest.SDK.Min
+	}
+	reasons := make(map[string]string)
+	imply := func(name, reason string) {
+		if _, ok := reasons[name]; !ok && !declared[name] {
+			reasons[name] = reason
+		}
+	}
+
+	for _, p := range k.permissions() {
+		reason := "requested " + p.Name + " permission"
+		for _, name := range impliedFeatures[p.Name] {
+			if name == "android.hardware.bluetooth" && targetSdk <= 4 {
+				continue
+			}
+			imply(name, reason)
+		}
+		if targetSdk < sdkLollipop {
+			switch p.Name {
+			case "android.permission.ACCESS_FINE_LOCATION":
+				imply("android.hardware.location.gps", reason)
+			case "android.permission.ACCESS_COARSE_LOCATION":
+				imply("android.hardware.location.network", reason)
+			}
+		}
+	}
+	for _, f := range k.apkManifest.Features {
+		if parent, ok := impliedBySubFeature[f.Name]; ok && (f.Required == nil || *f.Required) {
+			imply(parent, "requested "+f.Name+" feature")
+		}
+	}
+	for _, act := range k.apkManifest.App.Activities {
+		switch {
+		case containsString(landscapeOrientations, act.Orientation):
+			imply("android.hardware.screen.landscape", "one or more activities have specified a landscape orientation")
+		case containsString(portraitOrientations, act.Orientation):
+			imply("android.hardware.screen.portrait", "one or more activities have specified a portrait orientation")
+		}
+	}
+	if !declared["android.hardware.touchscreen"] {
+		imply("android.hardware.faketouch", "default feature for all apps")
+	}
+
+	features := make([]Feature, 0, len(reasons))
+	for name, reason := range reasons {
+		features = append(features, Feature{Name: name, Required: true, Implied: true, Reason: reason})
+	}
+	sort.Slice(features, func(i, j int) bool {
+		return features[i].Name < features[j].Name
+	})
+	return features
+}
+
+// supportsScreens returns the screen sizes the APK supports. Apps targeting
+// API 4 and later support all sizes unless they opt out, and extra large
+// screens from API 9.
+func (k *apk) supportsScreens() SupportsScreens {
+	sdk := k.apkManifest.SDK.Target
+	if sdk == 0 {
+		sdk = k.apkManifest.SDK.Min
+	}
+	s := SupportsScreens{
+		Small:      sdk >= 4,
+		Normal:     true,
+		Large:      sdk >= 4,
+		XLarge:     sdk >= 9,
+		Resizeable: sdk >= 4,
+		AnyDensity: sdk >= 4,
+	}
+	declared := k.apkManifest.SupportsScreens
+	if declared == nil {
+		return s
+	}
+	for _, v := range []struct {
+		attr  *bool
+		value *bool
+	}{
+		{declared.SmallScreens, &s.Small},
+		{declared.NormalScreens, &s.Normal},
+		{declared.LargeScreens, &s.Large},
+		{declared.XLargeScreens, &s.XLarge},
+		{declared.Resizeable, &s.Resizeable},
+		{declared.AnyDensity, &s.AnyDensity},
+	} {
+		if v.attr != nil {
+			*v.value = *v.attr
+		}
+	}
+	s.RequiresSmallestWidthDp = declared.RequiresSmallestWidthDp
+	s.CompatibleWidthLimitDp = declared.CompatibleWidthLimitDp
+	s.LargestWidthLimitDp = declared.LargestWidthLimitDp
+	return s
+}
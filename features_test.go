@@ -0,0 +1,105 @@
+package apkparser
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestFeatureRequirements(t *testing.T) {
+	android := func(name, value string) xmlAttr {
+		return xmlAttr{NS: androidNamespace, Name: name, Value: value}
+	}
+	boolean := func(name string, v bool) xmlAttr {
+		a := xmlAttr{NS: androidNamespace, Name: name, Type: TypeIntBoolean}
+		if v {
+			a.Data = 0xFFFFFFFF
+		}
+		return a
+	}
+	integer := func(name string, v uint32) xmlAttr {
+		return xmlAttr{NS: androidNamespace, Name: name, Type: TypeIntDec, Data: v}
+	}
+	elem := func(name string, attrs ...xmlAttr) *xmlElement {
+		return &xmlElement{Name: name, Attrs: attrs}
+	}
+	manifest := testManifest(
+		elem("uses-sdk", integer("minSdkVersion", 19), integer("targetSdkVersion", 19)),
+		elem("uses-permission", android("name", "android.permission.CAMERA")),
+		elem("uses-permission", android("name", "android.permission.ACCESS_FINE_LOCATION")),
+		elem("uses-feature", android("name", "android.hardware.camera.autofocus"), boolean("required", false)),
+		elem("uses-feature", android("name", "android.hardware.telephony.gsm")),
+		elem("uses-feature", xmlAttr{NS: androidNamespace, Name: "glEsVersion", Type: TypeIntHex, Data: 0x00030001}),
+		elem("uses-configuration", integer("reqTouchScreen", 3), integer("reqKeyboardType", 2), boolean("reqHardKeyboard", true)),
+		elem("supports-screens", boolean("smallScreens", false), integer("requiresSmallestWidthDp", 600)),
+		&xmlElement{Name: "compatible-screens", Children: []*xmlElement{
+			elem("screen", integer("screenSize", 300), integer("screenDensity", 480)),
+			elem("screen", integer("screenSize", 400), android("screenDensity", "xhdpi")),
+		}},
+		elem("supports-gl-texture", android("name", "GL_OES_compressed_ETC1_RGB8_texture")),
+		&xmlElement{Name: "application", Children: []*xmlElement{
+			elem("uses-library", android("name", "org.apache.http.legacy"), boolean("required", false)),
+			elem("uses-library", android("name", "com.google.android.maps")),
+			elem("activity", android("name", ".MainActivity"), integer("screenOrientation", 6)),
+		}},
+	)
+	name := writeAPK(t,
+		zipFile{"AndroidManifest.xml", encodeXML(manifest)},
+		zipFile{"resources.arsc", testTable()},
+	)
+	info, err := New(name, Option{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := info.Features
+
+	wantFeatures := []Feature{
+		{Name: "android.hardware.camera.autofocus", Required: false},
+		{Name: "android.hardware.telephony.gsm", Required: true},
+		{"android.hardware.camera", true, true, "requested android.permission.CAMERA permission"},
+		{"android.hardware.faketouch", true, true, "default feature for all apps"},
+		{"android.hardware.location", true, true, "requested android.permission.ACCESS_FINE_LOCATION permission"},
+		{"android.hardware.location.gps", true, true, "requested android.permission.ACCESS_FINE_LOCATION permission"},
+		{"android.hardware.screen.landscape", true, true, "one or more activities have specified a landscape orientation"},
+		{"android.hardware.telephony", true, true, "requested android.hardware.telephony.gsm feature"},
+	}
+	if !reflect.DeepEqual(req.Features, wantFeatures) {
+		t.Errorf("features:\ngot  %+v\nwant %+v", req.Features, wantFeatures)
+	}
+	if req.GLESVersion.String() != "3.1" {
+		t.Errorf("GLESVersion = %v, want 3.1", req.GLESVersion)
+	}
+	wantConfig := []Configuration{{HardKeyboard: true, KeyboardType: "qwerty", TouchScreen: "finger"}}
+	if !reflect.DeepEqual(req.Configurations, wantConfig) {
+		t.Errorf("configurations = %+v, want %+v", req.Configurations, wantConfig)
+	}
+	wantScreens := SupportsScreens{
+		Normal: true, Large: true, XLarge: true, Resizeable: true, AnyDensity: true,
+		RequiresSmallestWidthDp: 600,
+	}
+	if req.SupportsScreens != wantScreens {
+		t.Errorf("supports screens = %+v, want %+v", req.SupportsScreens, wantScreens)
+	}
+	wantCompatible := []CompatibleScreen{{"normal", 480}, {"large", 320}}
+	if !reflect.DeepEqual(req.CompatibleScreens, wantCompatible) {
+		t.Errorf("compatible screens = %+v, want %+v", req.CompatibleScreens, wantCompatible)
+	}
+	if want := []string{"GL_OES_compressed_ETC1_RGB8_texture"}; !reflect.DeepEqual(req.GLTextures, want) {
+		t.Errorf("GL textures = %q, want %q", req.GLTextures, want)
+	}
+	wantLibraries := []Library{{"org.apache.http.legacy", false}, {"com.google.android.maps", true}}
+	if !reflect.DeepEqual(req.Libraries, wantLibraries) {
+		t.Errorf("libraries = %+v, want %+v", req.Libraries, wantLibraries)
+	}
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		GLESVersion string `json:"glEsVersion"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded.GLESVersion != "3.1" {
+		t.Errorf("JSON glEsVersion = %q, %v", decoded.GLESVersion, err)
+	}
+}
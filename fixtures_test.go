@@ -0,0 +1,622 @@
+package apkparser
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"hash/adler32"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"unicode/utf16"
+	"unsafe"
+)
+
+// This file builds synthetic binary XML files, resource tables and APKs,
+// so that the parsers can be tested without checked-in binaries.
+
+// stringPool collects the strings of a fixture and returns their indexes.
+type stringPool struct {
+	strings []string
+	index   map[string]ResStringPoolRef
+}
+
+func (p *stringPool) ref(s string) ResStringPoolRef {
+	if p.index == nil {
+		p.index = make(map[string]ResStringPoolRef)
+	}
+	if ref, ok := p.index[s]; ok {
+		return ref
+	}
+	ref := ResStringPoolRef(len(p.strings))
+	p.strings = append(p.strings, s)
+	p.index[s] = ref
+	return ref
+}
+
+// encode encodes the pool as a UTF-16 string pool chunk.
+func (p *stringPool) encode() []byte {
+	var data bytes.Buffer
+	offsets := make([]uint32, len(p.strings))
+	for i, s := range p.strings {
+		offsets[i] = uint32(data.Len())
+		chars := utf16.Encode([]rune(s))
+		_ = binary.Write(&data, binary.LittleEndian, uint16(len(chars)))
+		_ = binary.Write(&data, binary.LittleEndian, chars)
+		_ = binary.Write(&data, binary.LittleEndian, uint16(0))
+	}
+	for data.Len()%4 != 0 {
+		data.WriteByte(0)
+	}
+
+	headerSize := uint32(unsafe.Sizeof(ResStringPoolHeader{}))
+	header := ResStringPoolHeader{
+		Header: ResChunkHeader{
+			Type:       ResStringPoolChunkType,
+			HeaderSize: uint16(headerSize),
+			Size:       headerSize + 4*uint32(len(offsets)) + uint32(data.Len()),
+		},
+		StringCount: uint32(len(offsets)),
+		StringStart: headerSize + 4*uint32(len(offsets)),
+	}
+	var buf bytes.Buffer
+	_ = binary.Write(&buf, binary.LittleEndian, header)
+	_ = binary.Write(&buf, binary.LittleEndian, offsets)
+	buf.Write(data.Bytes())
+	return buf.Bytes()
+}
+
+// xmlAttr is an attribute of an xmlElement. Attributes without a type are
+// strings.
+type xmlAttr struct {
+	NS, Name, Value string
+	Type            DataType
+	Data            uint32
+}
+
+// xmlElement is an element of a binary XML fixture.
+type xmlElement struct {
+	Name     string
+	Attrs    []xmlAttr
+	Children []*xmlElement
+	Text     string
+}
+
+// encodeXML encodes root as a binary XML file declaring the android namespace.
+func encodeXML(root *xmlElement) []byte {
+	pool := new(stringPool)
+	var body bytes.Buffer
+	node := func(typ ChunkType, size uint32) {
+		_ = binary.Write(&body, binary.LittleEndian, ResXMLTreeNode{
+			Header:     ResChunkHeader{Type: typ, HeaderSize: 16, Size: size},
+			LineNumber: 1,
+			Comment:    NilResStringPoolRef,
+		})
+	}
+	nsRef := func(ns string) ResStringPoolRef {
+		if ns == "" {
+			return NilResStringPoolRef
+		}
+		return pool.ref(ns)
+	}
+
+	namespace := ResXMLTreeNamespaceExt{Prefix: pool.ref("android"), URI: pool.ref(androidNamespace)}
+	node(ResXMLStartNamespaceType, 24)
+	_ = binary.Write(&body, binary.LittleEndian, namespace)
+
+	var encode func(e *xmlElement)
+	encode = func(e *xmlElement) {
+		node(ResXMLStartElementType, uint32(16+20+20*len(e.Attrs)))
+		_ = binary.Write(&body, binary.LittleEndian, ResXMLTreeAttrExt{
+			NS:             NilResStringPoolRef,
+			Name:           pool.ref(e.Name),
+			AttributeStart: 20,
+			AttributeSize:  20,
+			AttributeCount: uint16(len(e.Attrs)),
+		})
+		for _, a := range e.Attrs {
+			attr := ResXMLTreeAttribute{
+				NS:       nsRef(a.NS),
+				Name:     pool.ref(a.Name),
+				RawValue: NilResStringPoolRef,
+				TypedValue: ResValue{
+					Size:     8,
+					DataType: a.Type,
+					Data:     a.Data,
+				},
+			}
+			if a.Type == TypeNull {
+				attr.RawValue = pool.ref(a.Value)
+				attr.TypedValue.DataType = TypeString
+				attr.TypedValue.Data = uint32(attr.RawValue)
+			}
+			_ = binary.Write(&body, binary.LittleEndian, attr)
+		}
+		if e.Text != "" {
+			node(ResXMLCDataType, 16+4+8)
+			_ = binary.Write(&body, binary.LittleEndian, pool.ref(e.Text))
+			_ = binary.Write(&body, binary.LittleEndian, ResValue{Size: 8})
+		}
+		for _, c := range e.Children {
+			encode(c)
+		}
+		node(ResXMLEndElementType, 24)
+		_ = binary.Write(&body, binary.LittleEndian, ResXMLTreeEndElementExt{
+			NS:   NilResStringPoolRef,
+			Name: pool.ref(e.Name),
+		})
+	}
+	encode(root)
+
+	node(ResXMLEndNamespaceType, 24)
+	_ = binary.Write(&body, binary.LittleEndian, namespace)
+
+	strings := pool.encode()
+	var buf bytes.Buffer
+	_ = binary.Write(&buf, binary.LittleEndian, ResChunkHeader{
+		Type:       ResXMLChunkType,
+		HeaderSize: 8,
+		Size:       uint32(8 + len(strings) + body.Len()),
+	})
+	buf.Write(strings)
+	buf.Write(body.Bytes())
+	return buf.Bytes()
+}
+
+// tableValue is an entry of a tableType fixture. String values are stored in
+// the global string pool.
+type tableValue struct {
+	Name   string
+	Type   DataType
+	Data   uint32
+	String string
+}
+
+// tableType is a resource type of a table fixture under one configuration.
+// Entries missing from the configuration are nil.
+type tableType struct {
+	ID      uint8
+	Name    string
+	Config  ResTableConfig
+	Entries []*tableValue
+}
+
+// encodeTable encodes a resources.arsc with a single package.
+func encodeTable(id uint32, name string, types []tableType) []byte {
+	values := new(stringPool)
+	typeNames := new(stringPool)
+	keys := new(stringPool)
+	for i := 1; i <= len(types); i++ {
+		for _, t := range types {
+			if int(t.ID) == i {
+				typeNames.ref(t.Name)
+			}
+		}
+	}
+
+	var chunks bytes.Buffer
+	specs := make(map[uint8]bool)
+	for _, t := range types {
+		if !specs[t.ID] {
+			specs[t.ID] = true
+			_ = binary.Write(&chunks, binary.LittleEndian, ResTableTypeSpec{
+				Header: ResChunkHeader{
+					Type:       ResTableTypeSpecType,
+					HeaderSize: 16,
+					Size:       uint32(16 + 4*len(t.Entries)),
+				},
+				ID:         t.ID,
+				EntryCount: uint32(len(t.Entries)),
+			})
+			_ = binary.Write(&chunks, binary.LittleEndian, make([]uint32, len(t.Entries)))
+		}
+
+		var entries bytes.Buffer
+		offsets := make([]uint32, len(t.Entries))
+		for i, e := range t.Entries {
+			if e == nil {
+				offsets[i] = 0xFFFFFFFF
+				continue
+			}
+			offsets[i] = uint32(entries.Len())
+			v := ResValue{Size: 8, DataType: e.Type, Data: e.Data}
+			if e.Type == TypeString {
+				v.Data = uint32(values.ref(e.String))
+			}
+			_ = binary.Write(&entries, binary.LittleEndian, ResTableEntry{Size: 8, Key: keys.ref(e.Name)})
+			_ = binary.Write(&entries, binary.LittleEndian, v)
+		}
+		config := t.Config
+		config.Size = uint32(unsafe.Sizeof(config))
+		headerSize := uint32(unsafe.Sizeof(ResTableType{}))
+		_ = binary.Write(&chunks, binary.LittleEndian, ResTableType{
+			Header: ResChunkHeader{
+				Type:       ResTableTypeType,
+				HeaderSize: uint16(headerSize),
+				Size:       headerSize + 4*uint32(len(offsets)) + uint32(entries.Len()),
+			},
+			ID:           t.ID,
+			EntryCount:   uint32(len(offsets)),
+			EntriesStart: headerSize + 4*uint32(len(offsets)),
+			Config:       config,
+		})
+		_ = binary.Write(&chunks, binary.LittleEndian, offsets)
+		chunks.Write(entries.Bytes())
+	}
+
+	typeStrings, keyStrings := typeNames.encode(), keys.encode()
+	headerSize := uint32(unsafe.Sizeof(ResTablePackage{}))
+	pkg := ResTablePackage{
+		Header: ResChunkHeader{
+			Type:       ResTablePackageType,
+			HeaderSize: uint16(headerSize),
+			Size:       headerSize + uint32(len(typeStrings)+len(keyStrings)+chunks.Len()),
+		},
+		ID:          id,
+		TypeStrings: headerSize,
+		KeyStrings:  headerSize + uint32(len(typeStrings)),
+	}
+	copy(pkg.Name[:], utf16.Encode([]rune(name)))
+
+	globalStrings := values.encode()
+	var buf bytes.Buffer
+	_ = binary.Write(&buf, binary.LittleEndian, ResTableHeader{
+		Header: ResChunkHeader{
+			Type:       ResTableChunkType,
+			HeaderSize: 12,
+			Size:       12 + uint32(len(globalStrings)) + pkg.Header.Size,
+		},
+		PackageCount: 1,
+	})
+	buf.Write(globalStrings)
+	_ = binary.Write(&buf, binary.LittleEndian, pkg)
+	buf.Write(typeStrings)
+	buf.Write(keyStrings)
+	buf.Write(chunks.Bytes())
+	return buf.Bytes()
+}
+
+// encodeZip returns a zip archive of files, stored in the given order.
+func encodeZip(t testing.TB, files ...zipFile) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, f := range files {
+		method := zip.Deflate
+		if f.Name == "resources.arsc" {
+			// stored, as apps targeting Android 11 must.
+			method = zip.Store
+		}
+		fw, err := w.CreateHeader(&zip.FileHeader{Name: f.Name, Method: method})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write(f.Data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// zipFile is a file of a zip fixture.
+type zipFile struct {
+	Name string
+	Data []byte
+}
+
+// writeAPK writes a zip archive of files to a temporary APK file.
+func writeAPK(t testing.TB, files ...zipFile) string {
+	t.Helper()
+	name := filepath.Join(t.TempDir(), "test.apk")
+	if err := os.WriteFile(name, encodeZip(t, files...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+// testManifest returns a minimal manifest of the package com.example.app.
+func testManifest(children ...*xmlElement) *xmlElement {
+	return &xmlElement{
+		Name: "manifest",
+		Attrs: []xmlAttr{
+			{Name: "package", Value: "com.example.app"},
+			{NS: androidNamespace, Name: "versionCode", Type: TypeIntDec, Data: 42},
+			{NS: androidNamespace, Name: "versionName", Value: "1.2.3"},
+		},
+		Children: children,
+	}
+}
+
+// testTable returns a resource table of the package com.example.app with
+// an app name translated to French, and a string only defined in French.
+func testTable() []byte {
+	return encodeTable(0x7f, "com.example.app", []tableType{
+		{ID: 1, Name: "string", Entries: []*tableValue{
+			{Name: "app_name", Type: TypeString, String: "Example"},
+			{Name: "untranslated", Type: TypeString, String: "Only English"},
+		}},
+		{ID: 1, Name: "string", Config: ResTableConfig{Language: [2]uint8{'f', 'r'}}, Entries: []*tableValue{
+			{Name: "app_name", Type: TypeString, String: "Exemple"},
+			nil,
+			{Name: "french_only", Type: TypeString, String: "Seulement en français"},
+		}},
+		{ID: 2, Name: "color", Entries: []*tableValue{
+			{Name: "accent", Type: TypeIntColorARGB8, Data: 0xff336699},
+		}},
+	})
+}
+
+// testDexClass is a class of a DEX file made by encodeDexClasses. Types are
+// descriptors and methods have their method descriptor as Type.
+type testDexClass struct {
+	Descriptor string
+	Flags      AccessFlags
+	Superclass string
+	Interfaces []string
+	SourceFile string
+	Fields     []testDexMember // static fields have AccStatic
+	Methods    []testDexMember // direct methods have AccStatic, AccPrivate or AccConstructor
+}
+
+type testDexMember struct {
+	Name  string
+	Type  string
+	Flags AccessFlags
+}
+
+// encodeDex encodes a DEX file whose string and type tables hold the type
+// descriptors types.
+func encodeDex(types ...string) []byte {
+	return encodeDexClasses(types)
+}
+
+// encodeDexClasses encodes a DEX file defining classes, whose tables also
+// hold the type descriptors types.
+func encodeDexClasses(types []string, classes ...testDexClass) []byte {
+	// splitDescriptor returns the parameters and return type of a method
+	// descriptor.
+	splitDescriptor := func(descriptor string) ([]string, string) {
+		var params []string
+		s := descriptor[1:]
+		for s[0] != ')' {
+			n := 0
+			for s[n] == '[' {
+				n++
+			}
+			if s[n] == 'L' {
+				n = strings.IndexByte(s, ';')
+			}
+			params = append(params, s[:n+1])
+			s = s[n+1:]
+		}
+		return params, s[1:]
+	}
+	shorty := func(params []string, ret string) string {
+		b := []byte{ret[0]}
+		for _, p := range params {
+			b = append(b, p[0])
+		}
+		return strings.NewReplacer("[", "L").Replace(string(b))
+	}
+
+	stringSet := make(map[string]bool)
+	typeSet := make(map[string]bool)
+	addType := func(t string) {
+		stringSet[t] = true
+		typeSet[t] = true
+	}
+	for _, t := range types {
+		addType(t)
+	}
+	var protos []string
+	protoSet := make(map[string]bool)
+	for _, c := range classes {
+		addType(c.Descriptor)
+		if c.Superclass != "" {
+			addType(c.Superclass)
+		}
+		for _, t := range c.Interfaces {
+			addType(t)
+		}
+		if c.SourceFile != "" {
+			stringSet[c.SourceFile] = true
+		}
+		for _, f := range c.Fields {
+			stringSet[f.Name] = true
+			addType(f.Type)
+		}
+		for _, m := range c.Methods {
+			stringSet[m.Name] = true
+			params, ret := splitDescriptor(m.Type)
+			for _, t := range append(params, ret) {
+				addType(t)
+			}
+			stringSet[shorty(params, ret)] = true
+			if !protoSet[m.Type] {
+				protoSet[m.Type] = true
+				protos = append(protos, m.Type)
+			}
+		}
+	}
+	var strs []string
+	for s := range stringSet {
+		strs = append(strs, s)
+	}
+	sort.Strings(strs)
+	stringIdx := make(map[string]uint32)
+	for i, s := range strs {
+		stringIdx[s] = uint32(i)
+	}
+	var typeIds []uint32
+	typeIdx := make(map[string]uint32)
+	for _, s := range strs {
+		if typeSet[s] {
+			typeIdx[s] = uint32(len(typeIds))
+			typeIds = append(typeIds, stringIdx[s])
+		}
+	}
+	protoIdx := make(map[string]uint32)
+	for i, p := range protos {
+		protoIdx[p] = uint32(i)
+	}
+	var fieldCount, methodCount uint32
+	for _, c := range classes {
+		fieldCount += uint32(len(c.Fields))
+		methodCount += uint32(len(c.Methods))
+	}
+
+	header := DexHeader{
+		HeaderSize:    uint32(unsafe.Sizeof(DexHeader{})),
+		EndianTag:     dexEndianConstant,
+		StringIdsSize: uint32(len(strs)),
+		TypeIdsSize:   uint32(len(typeIds)),
+		ProtoIdsSize:  uint32(len(protos)),
+		FieldIdsSize:  fieldCount,
+		MethodIdsSize: methodCount,
+		ClassDefsSize: uint32(len(classes)),
+	}
+	copy(header.Magic[:], "dex\n035\x00")
+	off := header.HeaderSize
+	table := func(size, entry uint32) uint32 {
+		if size == 0 {
+			return 0
+		}
+		tableOff := off
+		off += size * entry
+		return tableOff
+	}
+	header.StringIdsOff = table(header.StringIdsSize, 4)
+	header.TypeIdsOff = table(header.TypeIdsSize, 4)
+	header.ProtoIdsOff = table(header.ProtoIdsSize, 12)
+	header.FieldIdsOff = table(header.FieldIdsSize, 8)
+	header.MethodIdsOff = table(header.MethodIdsSize, 8)
+	header.ClassDefsOff = table(header.ClassDefsSize, 32)
+	header.DataOff = off
+
+	var data bytes.Buffer
+	le := binary.LittleEndian
+	uleb128 := func(v uint32) {
+		for v >= 0x80 {
+			data.WriteByte(byte(v) | 0x80)
+			v >>= 7
+		}
+		data.WriteByte(byte(v))
+	}
+	align := func() {
+		for data.Len()%4 != 0 {
+			data.WriteByte(0)
+		}
+	}
+	typeList := func(list []string) uint32 {
+		if len(list) == 0 {
+			return 0
+		}
+		align()
+		listOff := header.DataOff + uint32(data.Len())
+		_ = binary.Write(&data, le, uint32(len(list)))
+		for _, t := range list {
+			_ = binary.Write(&data, le, uint16(typeIdx[t]))
+		}
+		return listOff
+	}
+
+	stringOffsets := make([]uint32, len(strs))
+	for i, s := range strs {
+		stringOffsets[i] = header.DataOff + uint32(data.Len())
+		uleb128(uint32(len(utf16.Encode([]rune(s)))))
+		data.WriteString(s)
+		data.WriteByte(0)
+	}
+	var protoIds []uint32
+	for _, p := range protos {
+		params, ret := splitDescriptor(p)
+		protoIds = append(protoIds, stringIdx[shorty(params, ret)], typeIdx[ret], typeList(params))
+	}
+	var fieldIds, methodIds []uint16 // class, type or proto, then the name as 2 uint16
+	var classDefs []uint32
+	var fieldIdx, methodIdx uint32
+	for _, c := range classes {
+		class := uint16(typeIdx[c.Descriptor])
+		superclass, sourceFile := uint32(dexNoIndex), uint32(dexNoIndex)
+		if c.Superclass != "" {
+			superclass = typeIdx[c.Superclass]
+		}
+		if c.SourceFile != "" {
+			sourceFile = stringIdx[c.SourceFile]
+		}
+		interfaces := typeList(c.Interfaces)
+
+		var static, instance, direct, virtual []testDexMember
+		var staticIdx, instanceIdx, directIdx, virtualIdx []uint32
+		for _, f := range c.Fields {
+			name := stringIdx[f.Name]
+			fieldIds = append(fieldIds, class, uint16(typeIdx[f.Type]), uint16(name), uint16(name>>16))
+			if f.Flags&AccStatic != 0 {
+				static, staticIdx = append(static, f), append(staticIdx, fieldIdx)
+			} else {
+				instance, instanceIdx = append(instance, f), append(instanceIdx, fieldIdx)
+			}
+			fieldIdx++
+		}
+		for _, m := range c.Methods {
+			name := stringIdx[m.Name]
+			methodIds = append(methodIds, class, uint16(protoIdx[m.Type]), uint16(name), uint16(name>>16))
+			if m.Flags&(AccStatic|AccPrivate|AccConstructor) != 0 {
+				direct, directIdx = append(direct, m), append(directIdx, methodIdx)
+			} else {
+				virtual, virtualIdx = append(virtual, m), append(virtualIdx, methodIdx)
+			}
+			methodIdx++
+		}
+		classData := header.DataOff + uint32(data.Len())
+		uleb128(uint32(len(static)))
+		uleb128(uint32(len(instance)))
+		uleb128(uint32(len(direct)))
+		uleb128(uint32(len(virtual)))
+		members := func(list []testDexMember, idx []uint32, code bool) {
+			var prev uint32
+			for i, m := range list {
+				uleb128(idx[i] - prev)
+				uleb128(uint32(m.Flags))
+				if code {
+					uleb128(0)
+				}
+				prev = idx[i]
+			}
+		}
+		members(static, staticIdx, false)
+		members(instance, instanceIdx, false)
+		members(direct, directIdx, true)
+		members(virtual, virtualIdx, true)
+
+		classDefs = append(classDefs, uint32(class), uint32(c.Flags), superclass, interfaces, sourceFile, 0, classData, 0)
+	}
+	align()
+	header.DataSize = uint32(data.Len())
+	header.FileSize = header.DataOff + header.DataSize
+
+	var buf bytes.Buffer
+	_ = binary.Write(&buf, le, header)
+	_ = binary.Write(&buf, le, stringOffsets)
+	_ = binary.Write(&buf, le, typeIds)
+	_ = binary.Write(&buf, le, protoIds)
+	_ = binary.Write(&buf, le, fieldIds)
+	_ = binary.Write(&buf, le, methodIds)
+	_ = binary.Write(&buf, le, classDefs)
+	buf.Write(data.Bytes())
+	return signDex(buf.Bytes())
+}
+
+// signDex updates the signature and checksum of the DEX file dex.
+func signDex(dex []byte) []byte {
+	signature := sha1.Sum(dex[32:])
+	copy(dex[12:32], signature[:])
+	binary.LittleEndian.PutUint32(dex[8:], adler32.Checksum(dex[12:]))
+	return dex
+}
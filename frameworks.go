@@ -0,0 +1,243 @@
+package apkparser
+
+import (
+	"io"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Framework is a framework, engine or language an app is built with.
+type Framework struct {
+	Name     string   `json:"name"`              // e.g. Flutter, React Native or Unity
+	Version  string   `json:"version,omitempty"` // if known; for Flutter, the engine revision
+	Evidence []string `json:"evidence"`
+}
+
+// frameworkSignature identifies a framework by the files, manifest
+// components and meta-data, and classes it adds to APKs.
+type frameworkSignature struct {
+	name       string
+	files      []string // patterns of path.Match, or directories ending in /
+	components []string // Application, activity, service, receiver or provider classes
+	metaData   []string // names of application meta-data
+	prefixes   []string // Java packages or classes
+	version    *versionSource
+}
+
+// versionSource extracts the version of a framework from the first match
+// of a regular expression in a file.
+type versionSource struct {
+	files   []string // patterns of path.Match
+	pattern *regexp.Regexp
+	limit   int64 // bytes of the file to search, or 0 for all of it within Limits
+	native  bool  // in native libraries, which are only searched if asked for
+}
+
+var frameworkSignatures = []frameworkSignature{
+	{
+		name:       "Flutter",
+		files:      []string{"lib/*/libflutter.so", "assets/flutter_assets/"},
+		components: []string{"io.flutter.embedding.android.FlutterActivity", "io.flutter.app.FlutterApplication"},
+		metaData:   []string{"flutterEmbedding"},
+		prefixes:   []string{"io.flutter"},
+		// the git revision of the engine, e.g. for
+		// https://github.com/flutter/engine/commit/<revision>
+		version: &versionSource{files: []string{"lib/*/libflutter.so"}, pattern: regexp.MustCompile(`\x00([0-9a-f]{40})\x00`), native: true},
+	},
+	{
+		name:     "React Native",
+		files:    []string{"assets/index.android.bundle", "lib/*/libreactnativejni.so", "lib/*/libreact_nativemodule_core.so"},
+		prefixes: []string{"com.facebook.react"},
+	},
+	{
+		name:     "Hermes",
+		files:    []string{"lib/*/libhermes.so"},
+		prefixes: []string{"com.facebook.hermes"},
+	},
+	{
+		name:       "Unity",
+		files:      []string{"lib/*/libunity.so", "lib/*/libil2cpp.so", "assets/bin/Data/"},
+		components: []string{"com.unity3d.player.UnityPlayerActivity", "com.unity3d.player.UnityPlayerGameActivity"},
+		metaData:   []string{"unity.build-id", "unity.splash-mode"},
+		prefixes:   []string{"com.unity3d.player"},
+		// e.g. 2021.3.5f1, in the header of the serialized files.
+		version: &versionSource{files: []string{"assets/bin/Data/globalgamemanagers", "assets/bin/Data/data.unity3d", "assets/bin/Data/mainData"},
+			pattern: regexp.MustCompile(`\b((?:20\d\d|[45])\.\d+\.\d+[abfpx]\d+)\b`), limit: 4 << 10},
+	},
+	{
+		name:     "Unreal Engine",
+		files:    []string{"lib/*/libUE4.so", "lib/*/libUnreal.so"},
+		prefixes: []string{"com.epicgames.ue4", "com.epicgames.unreal"},
+	},
+	{
+		name:     "Cocos2d-x",
+		files:    []string{"lib/*/libcocos2dcpp.so", "lib/*/libcocos2djs.so", "lib/*/libcocos2dlua.so", "lib/*/libcocos.so"},
+		prefixes: []string{"org.cocos2dx"},
+	},
+	{
+		name:     "Godot",
+		files:    []string{"lib/*/libgodot_android.so"},
+		prefixes: []string{"org.godotengine.godot"},
+	},
+	{
+		name:     "Qt",
+		files:    []string{"lib/*/libQt5Core*.so", "lib/*/libQt6Core*.so"},
+		prefixes: []string{"org.qtproject.qt5", "org.qtproject.qt"},
+	},
+	{
+		name:       "Xamarin",
+		files:      []string{"assemblies/", "lib/*/libmonodroid.so", "lib/*/libxamarin-app.so"},
+		components: []string{"mono.MonoRuntimeProvider"},
+		prefixes:   []string{"mono.android"},
+	},
+	{
+		name:     "Cordova",
+		files:    []string{"assets/www/cordova.js", "assets/www/cordova_plugins.js"},
+		prefixes: []string{"org.apache.cordova"},
+		version:  &versionSource{files: []string{"assets/www/cordova.js"}, pattern: regexp.MustCompile(`PLATFORM_VERSION_BUILD_LABEL\s*=\s*'([^']+)'`), limit: 1 << 20},
+	},
+	{
+		name:     "Capacitor",
+		files:    []string{"assets/capacitor.config.json", "assets/capacitor.plugins.json"},
+		prefixes: []string{"com.getcapacitor"},
+	},
+	{
+		name:     "Kotlin",
+		files:    []string{"kotlin/*.kotlin_builtins", "META-INF/kotlin-stdlib*.kotlin_module"},
+		prefixes: []string{"kotlin"},
+	},
+	{
+		name:     "Jetpack Compose",
+		files:    []string{"META-INF/androidx.compose.ui_ui.version"},
+		prefixes: []string{"androidx.compose"},
+		version:  &versionSource{files: []string{"META-INF/androidx.compose.ui_ui.version"}, pattern: regexp.MustCompile(`^(\S+)`), limit: 64},
+	},
+}
+
+// frameworks detects the frameworks the APK is built with, from its files
+// and manifest and, if any, the names of its classes. The versions in native
+// libraries are only searched if native is true.
+func (k *apk) frameworks(classNames []string, native bool) []Framework {
+	var names []string
+	for _, f := range k.archive.files {
+		names = append(names, f.Name)
+	}
+	app := k.apkManifest.App
+	var components []string
+	if app.Name != "" {
+		components = append(components, k.qualifiedClassName(app.Name))
+	}
+	for _, a := range app.Activities {
+		components = append(components, k.qualifiedClassName(a.Name))
+	}
+	for _, s := range app.Services {
+		components = append(components, k.qualifiedClassName(s.Name))
+	}
+	for _, r := range app.Receivers {
+		components = append(components, k.qualifiedClassName(r.Name))
+	}
+	for _, p := range app.Providers {
+		components = append(components, k.qualifiedClassName(p.Name))
+	}
+	// number of classes in each package and its parents.
+	packages := make(map[string]int)
+	for _, name := range classNames {
+		for {
+			packages[name]++
+			dot := strings.LastIndexByte(name, '.')
+			if dot < 0 {
+				break
+			}
+			name = name[:dot]
+		}
+	}
+
+	var frameworks []Framework
+	for _, sig := range frameworkSignatures {
+		var evidence []string
+		for _, pattern := range sig.files {
+			for _, name := range names {
+				if matchEntry(pattern, name) {
+					evidence = append(evidence, "file "+name)
+					break
+				}
+			}
+		}
+		for _, c := range sig.components {
+			if containsString(components, c) {
+				evidence = append(evidence, "component "+c)
+			}
+		}
+		for _, m := range app.MetaData {
+			if containsString(sig.metaData, m.Name) {
+				evidence = append(evidence, "meta-data "+m.Name)
+			}
+		}
+		for _, prefix := range sig.prefixes {
+			if packages[prefix] > 0 {
+				evidence = append(evidence, "package "+prefix)
+			}
+		}
+		if len(evidence) == 0 {
+			continue
+		}
+		framework := Framework{Name: sig.name, Evidence: evidence}
+		if sig.version != nil && (native || !sig.version.native) {
+			framework.Version = k.findVersion(sig.version)
+		}
+		frameworks = append(frameworks, framework)
+	}
+	return frameworks
+}
+
+// findVersion returns the version in the first file of src which has one.
+func (k *apk) findVersion(src *versionSource) string {
+	for _, pattern := range src.files {
+		for _, f := range k.archive.files {
+			if ok, _ := path.Match(pattern, f.Name); !ok {
+				continue
+			}
+			if src.limit > 0 {
+				if v := searchZipFile(f, src.pattern, src.limit); v != "" {
+					return v
+				}
+				continue
+			}
+			data, err := k.readZipFile(f.Name)
+			if err != nil {
+				k.failed(WarningFrameworks, err)
+				continue
+			}
+			if m := src.pattern.FindSubmatch(data); m != nil {
+				return string(m[1])
+			}
+		}
+	}
+	return ""
+}
+
+// searchZipFile returns the first submatch of re in the first limit bytes of
+// f, or "" if there is none.
+func searchZipFile(f *zipEntry, re *regexp.Regexp, limit int64) string {
+	rc, err := f.Open()
+	if err != nil {
+		return ""
+	}
+	defer rc.Close()
+	data, _ := io.ReadAll(io.LimitReader(rc, limit))
+	if m := re.FindSubmatch(data); m != nil {
+		return string(m[1])
+	}
+	return ""
+}
+
+// matchEntry returns whether the zip entry name matches pattern, a pattern
+// of path.Match or a directory ending in /.
+func matchEntry(pattern, name string) bool {
+	if strings.HasSuffix(pattern, "/") {
+		return strings.HasPrefix(name, pattern)
+	}
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
@@ -0,0 +1,101 @@
+package apkparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFrameworks(t *testing.T) {
+	const engine = "1a65d409c7a1438a34d21b60bf30a6fd5db59314"
+	manifest := testManifest(&xmlElement{Name: "application", Children: []*xmlElement{
+		{Name: "activity", Attrs: []xmlAttr{{NS: androidNamespace, Name: "name", Value: "io.flutter.embedding.android.FlutterActivity"}}},
+		{Name: "meta-data", Attrs: []xmlAttr{
+			{NS: androidNamespace, Name: "name", Value: "flutterEmbedding"},
+			{NS: androidNamespace, Name: "value", Value: "2"},
+		}},
+	}})
+	name := writeAPK(t,
+		zipFile{"AndroidManifest.xml", encodeXML(manifest)},
+		zipFile{"resources.arsc", testTable()},
+		zipFile{"classes.dex", encodeDex("Lio/flutter/embedding/engine/FlutterEngine;", "Lkotlin/Unit;", "Ljava/lang/Object;")},
+		zipFile{"lib/arm64-v8a/libflutter.so", []byte("\x7fELF\x00dart:core\x00" + engine + "\x00")},
+		zipFile{"lib/arm64-v8a/libapp.so", []byte("\x7fELF")},
+		zipFile{"assets/flutter_assets/AssetManifest.json", []byte("{}")},
+		zipFile{"assets/bin/Data/globalgamemanagers", []byte("\x00\x00\x00\x00\x00\x00\x00\x162021.3.5f1\x00\x00")},
+		zipFile{"assets/www/cordova.js", []byte("var PLATFORM_VERSION_BUILD_LABEL = '10.1.2';\n")},
+	)
+
+	info, err := New(name, Option{WithEngineVersion: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Framework{
+		{Name: "Flutter", Version: engine, Evidence: []string{
+			"file lib/arm64-v8a/libflutter.so",
+			"file assets/flutter_assets/AssetManifest.json",
+			"component io.flutter.embedding.android.FlutterActivity",
+			"meta-data flutterEmbedding",
+		}},
+		{Name: "Unity", Version: "2021.3.5f1", Evidence: []string{"file assets/bin/Data/globalgamemanagers"}},
+		{Name: "Cordova", Version: "10.1.2", Evidence: []string{"file assets/www/cordova.js"}},
+	}
+	if !reflect.DeepEqual(info.Frameworks, want) {
+		t.Errorf("frameworks:\ngot  %+v\nwant %+v", info.Frameworks, want)
+	}
+
+	// libflutter.so is only searched with WithEngineVersion.
+	info, err = New(name, Option{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := info.Frameworks[0].Version; v != "" {
+		t.Errorf("flutter version without WithEngineVersion = %q", v)
+	}
+
+	// the classes of the DEX files are only used with WithDex.
+	info, err = New(name, Option{WithDex: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range info.Frameworks {
+		names = append(names, f.Name)
+	}
+	if want := []string{"Flutter", "Unity", "Cordova", "Kotlin"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("frameworks with DEX = %q, want %q", names, want)
+	}
+	if evidence := info.Frameworks[0].Evidence; evidence[len(evidence)-1] != "package io.flutter" {
+		t.Errorf("flutter evidence = %q", evidence)
+	}
+}
+
+func TestFrameworkVersionLimits(t *testing.T) {
+	const engine = "1a65d409c7a1438a34d21b60bf30a6fd5db59314"
+	library := make([]byte, 64<<10)
+	copy(library[len(library)-42:], "\x00"+engine+"\x00")
+	name := writeAPK(t,
+		zipFile{"AndroidManifest.xml", encodeXML(testManifest())},
+		zipFile{"resources.arsc", testTable()},
+		zipFile{"lib/arm64-v8a/libflutter.so", library},
+	)
+
+	info, err := New(name, Option{WithEngineVersion: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.Frameworks) != 1 || info.Frameworks[0].Version != engine {
+		t.Fatalf("frameworks = %+v", info.Frameworks)
+	}
+
+	// the library is read within the limits of the entries.
+	info, err = New(name, Option{WithEngineVersion: true, BestEffort: true, Limits: Limits{MaxEntrySize: 32 << 10}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.Frameworks) != 1 || info.Frameworks[0].Version != "" {
+		t.Errorf("frameworks = %+v", info.Frameworks)
+	}
+	if len(info.Warnings) != 1 || info.Warnings[0].Code != WarningFrameworks {
+		t.Errorf("Warnings = %v, want a %s warning", info.Warnings, WarningFrameworks)
+	}
+}
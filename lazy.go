@@ -0,0 +1,194 @@
+package apkparser
+
+import (
+	"image"
+	"io"
+)
+
+// APK is an APK file whose parts are parsed on demand, when first requested,
+// and cached, so that scanning many APKs costs only what is needed of each.
+// It is safe for concurrent use.
+type APK struct {
+	k *apk
+}
+
+// Manifest is the identity of an APK declared in its manifest.
+type Manifest struct {
+	Package          string   `json:"package"`
+	VersionName      string   `json:"versionName,omitempty"`
+	VersionCode      int64    `json:"versionCode,omitempty"`
+	MinSdkVersion    int      `json:"minSdkVersion"`
+	TargetSdkVersion int      `json:"targetSdkVersion"`
+	MaxSdkVersion    int      `json:"maxSdkVersion"`
+	Permissions      []string `json:"permissions,omitempty"`
+}
+
+// Digests are the hex digests of an APK file.
+type Digests struct {
+	MD5    string `json:"md5"`
+	SHA1   string `json:"sha1"`
+	SHA256 string `json:"sha256"`
+}
+
+// Open opens the APK file name and reads its zip directory, like New reads
+// it with option. Nothing else is parsed until requested. The APK must be
+// closed with Close.
+func Open(name string, option Option) (*APK, error) {
+	k, err := openLazyFile(name, option)
+	if err != nil {
+		return nil, err
+	}
+	return &APK{k}, nil
+}
+
+// OpenReader returns the APK read from r, which has size bytes, like Open.
+func OpenReader(r io.ReaderAt, size int64, option Option) (*APK, error) {
+	k, err := newAPK(r, size, option)
+	if err != nil {
+		return nil, err
+	}
+	return &APK{k}, nil
+}
+
+// Close closes the file of an APK opened with Open.
+func (a *APK) Close() error {
+	return a.k.close()
+}
+
+// Manifest returns the identity of the APK, parsing AndroidManifest.xml.
+func (a *APK) Manifest() (*Manifest, error) {
+	if err := a.k.loadManifest(); err != nil {
+		return nil, err
+	}
+	m := &a.k.apkManifest
+	return &Manifest{
+		Package:          m.Package,
+		VersionName:      m.VersionName,
+		VersionCode:      m.VersionCode,
+		MinSdkVersion:    m.SDK.Min,
+		TargetSdkVersion: m.SDK.Target,
+		MaxSdkVersion:    m.SDK.Max,
+		Permissions:      formatPermissions(m.Permissions, m.PermissionsSDK23),
+	}, nil
+}
+
+// Resources returns the resource table of the APK, parsing resources.arsc.
+func (a *APK) Resources() (*TableFile, error) {
+	if err := a.k.loadResources(); err != nil {
+		return nil, err
+	}
+	return a.k.table, nil
+}
+
+// Label returns the label of the APK, resolved with its resources.
+func (a *APK) Label() (string, error) {
+	if err := a.k.loadManifest(); err != nil {
+		return "", err
+	}
+	if err := a.k.loadResources(); err != nil {
+		return "", err
+	}
+	return a.k.label(labelResConfig)
+}
+
+// Icon returns the icon of the APK, decoding its highest density artwork.
+func (a *APK) Icon() (image.Image, error) {
+	return a.k.loadIcon()
+}
+
+// ABIs returns the ABIs of the native libraries of the APK.
+func (a *APK) ABIs() []string {
+	a.k.loadABIs()
+	return append([]string(nil), a.k.abis...)
+}
+
+// Digests returns the digests of the APK file, reading all of it.
+func (a *APK) Digests() (Digests, error) {
+	return a.k.loadDigests()
+}
+
+// Signature verifies the signature of the APK and returns its certificate.
+func (a *APK) Signature() (*CertInfo, error) {
+	cert, err := a.k.loadSignature()
+	if err != nil {
+		return nil, err
+	}
+	c := *cert
+	return &c, nil
+}
+
+// Permissions returns the permissions the APK requests, with their
+// protection levels.
+func (a *APK) Permissions() ([]Permission, error) {
+	if err := a.k.loadManifest(); err != nil {
+		return nil, err
+	}
+	return a.k.permissions(), nil
+}
+
+// CustomPermissions returns the permissions the APK declares.
+func (a *APK) CustomPermissions() ([]PermissionDefinition, error) {
+	if err := a.k.loadManifest(); err != nil {
+		return nil, err
+	}
+	return a.k.customPermissions(), nil
+}
+
+// Features returns the hardware and software features the APK requires.
+func (a *APK) Features() (*FeatureRequirements, error) {
+	if err := a.k.loadManifest(); err != nil {
+		return nil, err
+	}
+	return a.k.featureRequirements(), nil
+}
+
+// DeepLinks returns the deep links the activities of the APK handle.
+func (a *APK) DeepLinks() ([]DeepLink, error) {
+	if err := a.k.loadManifest(); err != nil {
+		return nil, err
+	}
+	return a.k.deepLinks(), nil
+}
+
+// AttackSurface returns the components of the APK other apps can reach.
+func (a *APK) AttackSurface() (*AttackSurface, error) {
+	if err := a.k.loadManifest(); err != nil {
+		return nil, err
+	}
+	return a.k.attackSurface(), nil
+}
+
+// NetworkSecurity analyzes the network security config of the APK, parsing
+// its resources.
+func (a *APK) NetworkSecurity() (*NetworkSecurityReport, error) {
+	if err := a.k.loadManifest(); err != nil {
+		return nil, err
+	}
+	if err := a.k.loadResources(); err != nil {
+		return nil, err
+	}
+	return a.k.networkSecurity()
+}
+
+// Frameworks returns the frameworks the APK is built with, also detected by
+// its classes if opened WithDex, and with the versions in native libraries
+// if opened WithEngineVersion.
+func (a *APK) Frameworks() ([]Framework, error) {
+	if err := a.k.loadManifest(); err != nil {
+		return nil, err
+	}
+	var classNames []string
+	if a.k.withDex {
+		var err error
+		if classNames, err = a.k.classNames(); err != nil {
+			return nil, err
+		}
+	}
+	return a.k.frameworks(classNames, a.k.withEngineVersion), nil
+}
+
+// Warnings returns the anomalies of the APK found so far, such as malformed
+// zip entries in tolerant mode.
+func (a *APK) Warnings() []Warning {
+	return a.k.archive.recorded()
+}
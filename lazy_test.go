@@ -0,0 +1,153 @@
+package apkparser
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"testing"
+)
+
+func TestAPKLazy(t *testing.T) {
+	manifest := encodeXML(testManifest(&xmlElement{Name: "uses-permission", Attrs: []xmlAttr{
+		{NS: androidNamespace, Name: "name", Value: "android.permission.INTERNET"},
+	}}))
+	name := writeAPK(t,
+		zipFile{"AndroidManifest.xml", manifest},
+		zipFile{"resources.arsc", testTable()[:20]},
+		zipFile{"lib/arm64-v8a/libnative.so", []byte("\x7fELF")},
+	)
+	a, err := Open(name, Option{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	m, err := a.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Package != "com.example.app" || m.VersionCode != 42 || len(m.Permissions) != 1 {
+		t.Errorf("Manifest() = %+v", m)
+	}
+	if read := a.k.read.Load(); read != int64(len(manifest)) {
+		t.Errorf("%d bytes read for the manifest of %d bytes", read, len(manifest))
+	}
+
+	// the analyses of the manifest don't read the rest of the APK.
+	if p, err := a.Permissions(); err != nil || len(p) != 1 || p[0].Name != "android.permission.INTERNET" {
+		t.Errorf("Permissions() = %+v, %v", p, err)
+	}
+	if f, err := a.Features(); err != nil || f == nil {
+		t.Errorf("Features() = %+v, %v", f, err)
+	}
+	if s, err := a.AttackSurface(); err != nil || s == nil {
+		t.Errorf("AttackSurface() = %+v, %v", s, err)
+	}
+	if _, err := a.DeepLinks(); err != nil {
+		t.Errorf("DeepLinks() error = %v", err)
+	}
+	if _, err := a.CustomPermissions(); err != nil {
+		t.Errorf("CustomPermissions() error = %v", err)
+	}
+	if _, err := a.Frameworks(); err != nil {
+		t.Errorf("Frameworks() error = %v", err)
+	}
+	if read := a.k.read.Load(); read != int64(len(manifest)) {
+		t.Errorf("%d bytes read for the analyses of the manifest of %d bytes", read, len(manifest))
+	}
+
+	// the resources are malformed, which only fails what needs them.
+	if _, err := a.NetworkSecurity(); !errors.Is(err, ErrMalformed) {
+		t.Errorf("NetworkSecurity() error = %v, want ErrMalformed", err)
+	}
+	if _, err := a.Resources(); !errors.Is(err, ErrMalformed) {
+		t.Errorf("Resources() error = %v, want ErrMalformed", err)
+	}
+	if _, err := a.Label(); !errors.Is(err, ErrMalformed) {
+		t.Errorf("Label() error = %v, want ErrMalformed", err)
+	}
+	if abis := a.ABIs(); len(abis) != 1 || abis[0] != "arm64-v8a" {
+		t.Errorf("ABIs() = %q", abis)
+	}
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Digests{
+		MD5:    fmt.Sprintf("%x", md5.Sum(data)),
+		SHA256: fmt.Sprintf("%x", sha256.Sum256(data)),
+	}
+	if d, err := a.Digests(); err != nil || d.MD5 != want.MD5 || d.SHA256 != want.SHA256 || len(d.SHA1) != 40 {
+		t.Errorf("Digests() = %+v, %v, want %+v", d, err, want)
+	}
+
+	if _, err := New(name, Option{}); !errors.Is(err, ErrMalformed) {
+		t.Errorf("New() error = %v, want ErrMalformed", err)
+	}
+}
+
+func TestAPKConcurrent(t *testing.T) {
+	name := writeAPK(t,
+		zipFile{"AndroidManifest.xml", encodeXML(testManifest(&xmlElement{Name: "application", Attrs: []xmlAttr{
+			{NS: androidNamespace, Name: "label", Type: TypeReference, Data: 0x7f010000},
+		}}))},
+		zipFile{"resources.arsc", testTable()},
+	)
+	a, err := Open(name, Option{Tolerant: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	var wg sync.WaitGroup
+	labels := make([]string, 8)
+	for i := range labels {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := a.Manifest(); err != nil {
+				t.Error(err)
+			}
+			if _, err := a.Digests(); err != nil {
+				t.Error(err)
+			}
+			if _, err := a.Signature(); !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("Signature() error = %v, want ErrInvalidSignature", err)
+			}
+			_, _ = a.Icon()
+			labels[i], _ = a.Label()
+		}(i)
+	}
+	wg.Wait()
+	for _, label := range labels {
+		if label != "Example" {
+			t.Errorf("Label() = %q, want Example", label)
+		}
+	}
+}
+
+func TestReadLazy(t *testing.T) {
+	// the helpers which don't need the manifest nor the resources don't fail
+	// without them.
+	name := writeAPK(t,
+		zipFile{"resources.arsc", testTable()[:20]},
+		zipFile{"classes.dex", encodeDex("Lcom/example/app/Main;")},
+	)
+	if _, err := ReadSizeReport(name); err != nil {
+		t.Errorf("ReadSizeReport() error = %v", err)
+	}
+	if _, err := ReadDexClasses(name); err != nil {
+		t.Errorf("ReadDexClasses() error = %v", err)
+	}
+	if _, err := ReadTable(name); !errors.Is(err, ErrMalformed) {
+		t.Errorf("ReadTable() error = %v, want ErrMalformed", err)
+	}
+	if err := DecodeManifest(name, io.Discard, XMLEncodeOption{}); !errors.Is(err, ErrNoManifest) {
+		t.Errorf("DecodeManifest() error = %v, want ErrNoManifest", err)
+	}
+}
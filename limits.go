@@ -0,0 +1,75 @@
+package apkparser
+
+import (
+	"fmt"
+	"io"
+)
+
+// Limits bounds the resources used to parse an APK, against zip bombs and
+// other hostile inputs. Zero fields take the values of DefaultLimits, and
+// negative fields are unlimited.
+type Limits struct {
+	MaxEntrySize   int64 // uncompressed bytes of a zip entry read
+	MaxTotalSize   int64 // uncompressed bytes of all the zip entries read
+	MaxStringCount int   // strings and styles of a string pool
+	MaxXMLDepth    int   // nesting of the elements of a binary XML file
+	MaxDexItems    int   // items of an id table of a DEX file, e.g. type_ids
+}
+
+// DefaultLimits are the limits of the parsers, unless set in Option.
+var DefaultLimits = Limits{
+	MaxEntrySize:   256 << 20,
+	MaxTotalSize:   1 << 30,
+	MaxStringCount: 1 << 21,
+	MaxXMLDepth:    256,
+	MaxDexItems:    1 << 22,
+}
+
+// withDefaults returns l with the values of DefaultLimits for zero fields.
+func (l Limits) withDefaults() Limits {
+	if l.MaxEntrySize == 0 {
+		l.MaxEntrySize = DefaultLimits.MaxEntrySize
+	}
+	if l.MaxTotalSize == 0 {
+		l.MaxTotalSize = DefaultLimits.MaxTotalSize
+	}
+	if l.MaxStringCount == 0 {
+		l.MaxStringCount = DefaultLimits.MaxStringCount
+	}
+	if l.MaxXMLDepth == 0 {
+		l.MaxXMLDepth = DefaultLimits.MaxXMLDepth
+	}
+	if l.MaxDexItems == 0 {
+		l.MaxDexItems = DefaultLimits.MaxDexItems
+	}
+	return l
+}
+
+// LimitError reports an input which exceeds one of the Limits.
+type LimitError struct {
+	Limit string // the field of Limits, e.g. MaxEntrySize
+	Max   int64  // the value of the limit
+	Name  string // the zip entry, if known
+}
+
+func (e *LimitError) Error() string {
+	if e.Name == "" {
+		return fmt.Sprintf("apkparser: %s of %d exceeded", e.Limit, e.Max)
+	}
+	return fmt.Sprintf("apkparser: %s exceeds %s of %d", e.Name, e.Limit, e.Max)
+}
+
+// exceeds returns whether n exceeds the limit max, where negative limits are
+// unlimited.
+func exceeds(n, max int64) bool {
+	return max >= 0 && n > max
+}
+
+// readerSize returns the size of r, if it has a Size method like
+// bytes.Reader and io.SectionReader, or else the largest size.
+func readerSize(r io.ReaderAt) int64 {
+	if s, ok := r.(interface{ Size() int64 }); ok {
+		return s.Size()
+	}
+	return 1<<63 - 1
+}
@@ -0,0 +1,115 @@
+package apkparser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLimits(t *testing.T) {
+	manifest := encodeXML(testManifest(&xmlElement{Name: "application", Children: []*xmlElement{{Name: "activity"}}}))
+	table := testTable()
+	name := writeAPK(t,
+		zipFile{"AndroidManifest.xml", manifest},
+		zipFile{"resources.arsc", table},
+	)
+
+	tests := []struct {
+		name   string
+		limits Limits
+		limit  string // of the LimitError, if any
+	}{
+		{"defaults", Limits{}, ""},
+		{"unlimited", Limits{MaxEntrySize: -1, MaxTotalSize: -1, MaxStringCount: -1, MaxXMLDepth: -1, MaxDexItems: -1}, ""},
+		{"entry size", Limits{MaxEntrySize: int64(len(manifest)) - 1}, "MaxEntrySize"},
+		{"total size", Limits{MaxTotalSize: int64(len(manifest)+len(table)) - 1}, "MaxTotalSize"},
+		{"string count", Limits{MaxStringCount: 3}, "MaxStringCount"},
+		{"XML depth", Limits{MaxXMLDepth: 2}, "MaxXMLDepth"},
+		{"exact", Limits{MaxEntrySize: int64(len(table)), MaxTotalSize: int64(len(manifest) + len(table)), MaxXMLDepth: 3}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(name, Option{Limits: tt.limits})
+			var limitErr *LimitError
+			switch {
+			case tt.limit == "" && err != nil:
+				t.Errorf("New() error = %v", err)
+			case tt.limit != "" && !errors.As(err, &limitErr):
+				t.Errorf("New() error = %v, want LimitError", err)
+			case tt.limit != "" && limitErr.Limit != tt.limit:
+				t.Errorf("LimitError = %v, want %s", limitErr, tt.limit)
+			}
+		})
+	}
+}
+
+func TestLimitsLyingSize(t *testing.T) {
+	manifest := encodeXML(testManifest())
+	data := encodeZip(t, zipFile{"AndroidManifest.xml", manifest}, zipFile{"resources.arsc", testTable()})
+	_, central := zipHeaders(t, data, "AndroidManifest.xml")
+	binary.LittleEndian.PutUint32(data[central+24:], 1)
+	name := filepath.Join(t.TempDir(), "test.apk")
+	if err := os.WriteFile(name, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the data is read up to the limit, whatever its size in the archive.
+	_, err := New(name, Option{Tolerant: true, Limits: Limits{MaxEntrySize: int64(len(manifest)) / 2}})
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || limitErr.Limit != "MaxEntrySize" || limitErr.Name != "AndroidManifest.xml" {
+		t.Errorf("New() error = %v, want LimitError of AndroidManifest.xml", err)
+	}
+	if _, err := New(name, Option{Tolerant: true}); err != nil {
+		t.Errorf("New() error = %v", err)
+	}
+}
+
+func TestHostileXML(t *testing.T) {
+	valid := encodeXML(testManifest())
+	patch := func(offset int, value uint32) []byte {
+		data := append([]byte(nil), valid...)
+		binary.LittleEndian.PutUint32(data[offset:], value)
+		return data
+	}
+	// the string pool follows the header of the file, and the namespace and
+	// the root element follow the string pool.
+	poolSize := int(binary.LittleEndian.Uint32(valid[12:]))
+	element := 8 + poolSize + 24
+
+	tests := []struct {
+		name   string
+		data   []byte
+		limits Limits
+		limit  string // of the LimitError, if any
+	}{
+		{"string count", patch(16, 0x7fffffff), DefaultLimits, "MaxStringCount"},
+		{"unlimited string count", patch(16, 0x7fffffff), Limits{MaxStringCount: -1}, ""},
+		{"string pool size", patch(12, 0xffffffff), DefaultLimits, ""},
+		{"empty chunk", patch(12, 0), DefaultLimits, ""},
+		{"string out of range", patch(element+20, 1000), DefaultLimits, ""},
+		{"truncated", valid[:len(valid)-10], DefaultLimits, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newXMLFile(bytes.NewReader(tt.data), tt.limits)
+			if err == nil {
+				t.Fatal("newXMLFile() succeeded")
+			}
+			var limitErr *LimitError
+			if isLimit := errors.As(err, &limitErr); isLimit != (tt.limit != "") || isLimit && limitErr.Limit != tt.limit {
+				t.Errorf("newXMLFile() error = %v, want LimitError %q", err, tt.limit)
+			}
+		})
+	}
+
+	pool := &ResStringPool{Strings: []string{"a"}}
+	if s := pool.GetString(1); s != "" {
+		t.Errorf("GetString(1) = %q", s)
+	}
+	if s := (*ResStringPool)(nil).GetString(0); s != "" {
+		t.Errorf("GetString(0) of no pool = %q", s)
+	}
+}
@@ -0,0 +1,394 @@
+package apkparser
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Platform versions that changed the defaults of the network security config.
+const (
+	sdkNougat = 24 // user CAs are no longer trusted by default
+	sdkPie    = 28 // cleartext traffic is no longer permitted by default
+)
+
+// Sources of certificates of trust anchors that aren't raw resources.
+const (
+	TrustAnchorSystem = "system"
+	TrustAnchorUser   = "user"
+)
+
+// NetworkSecurityConfig is a network security config, referenced by
+// android:networkSecurityConfig.
+// https://developer.android.com/privacy-and-security/security-config
+type NetworkSecurityConfig struct {
+	BaseConfig     *NetworkConfig  `json:"baseConfig,omitempty"`
+	DomainConfigs  []*DomainConfig `json:"domainConfigs,omitempty"`
+	DebugOverrides *NetworkConfig  `json:"debugOverrides,omitempty"`
+}
+
+// NetworkConfig holds the settings shared by <base-config>, <domain-config>
+// and <debug-overrides>. Unset settings are inherited.
+type NetworkConfig struct {
+	CleartextTrafficPermitted *bool         `json:"cleartextTrafficPermitted,omitempty"`
+	TrustAnchors              []TrustAnchor `json:"trustAnchors,omitempty"`
+}
+
+// DomainConfig is a <domain-config>.
+type DomainConfig struct {
+	NetworkConfig
+	Domains       []Domain        `json:"domains"`
+	PinSet        *PinSet         `json:"pinSet,omitempty"`
+	DomainConfigs []*DomainConfig `json:"domainConfigs,omitempty"`
+}
+
+// Domain is a <domain> of a domain config.
+type Domain struct {
+	Name              string `json:"name"`
+	IncludeSubdomains bool   `json:"includeSubdomains"`
+}
+
+// TrustAnchor is a <certificates> source of trust anchors: TrustAnchorSystem,
+// TrustAnchorUser or a raw resource, e.g. @raw/my_ca.
+type TrustAnchor struct {
+	Src          string `json:"src"`
+	OverridePins bool   `json:"overridePins"`
+}
+
+// PinSet is a <pin-set> of public key pins.
+type PinSet struct {
+	Expiration string `json:"expiration,omitempty"` // yyyy-MM-dd
+	Pins       []Pin  `json:"pins"`
+}
+
+// Pin is a public key pin.
+type Pin struct {
+	Digest string `json:"digest"`
+	Value  string `json:"value"`
+}
+
+// Finding is an issue found by an analysis of an APK.
+type Finding struct {
+	Rule    string `json:"rule"`
+	Target  string `json:"target,omitempty"`
+	Message string `json:"message"`
+}
+
+// Rules of the network security findings.
+const (
+	RuleCleartextTraffic = "cleartext-traffic"
+	RuleUserCATrusted    = "user-ca-trusted"
+	RuleMissingPins      = "missing-pins"
+	RuleExpiredPins      = "expired-pins"
+	RuleInvalidPins      = "invalid-pins"
+	RuleDebugOverrides   = "debug-overrides"
+)
+
+// NetworkSecurityReport is the effective network security policy of an APK.
+type NetworkSecurityReport struct {
+	// ConfigResource names the network security config, e.g.
+	// @xml/network_security_config, if the app declares one.
+	ConfigResource string                 `json:"configResource,omitempty"`
+	Config         *NetworkSecurityConfig `json:"config,omitempty"`
+
+	// CleartextTrafficPermitted and UserCAsTrusted apply to the domains
+	// that no domain config covers.
+	CleartextTrafficPermitted bool `json:"cleartextTrafficPermitted"`
+	UserCAsTrusted            bool `json:"userCAsTrusted"`
+
+	CleartextDomains []string  `json:"cleartextDomains,omitempty"`
+	UserCADomains    []string  `json:"userCADomains,omitempty"`
+	UnpinnedDomains  []string  `json:"unpinnedDomains,omitempty"`
+	Findings         []Finding `json:"findings,omitempty"`
+}
+
+// networkSecurity analyzes the network security config of the APK, or the
+// platform defaults if it declares none.
+func (k *apk) networkSecurity() (*NetworkSecurityReport, error) {
+	app := &k.apkManifest.App
+	targetSdk := k.apkManifest.SDK.Target
+	if targetSdk == 0 {
+		targetSdk = k.apkManifest.SDK.Min
+	}
+
+	var config *NetworkSecurityConfig
+	var resource string
+	if app.NetworkSecurityConfig != "" {
+		id, err := ParseResID(app.NetworkSecurityConfig)
+		if err != nil {
+			return nil, err
+		}
+		xmlFile, err := k.readXMLResource(id, &ResTableConfig{})
+		if err != nil {
+			return nil, err
+		}
+		if config, err = parseNetworkSecurityConfig(xmlFile); err != nil {
+			return nil, err
+		}
+		resource = k.table.referenceName("@", id)
+	}
+
+	report := analyzeNetworkSecurity(config, targetSdk, app.UsesCleartextTraffic, app.Debuggable, time.Now())
+	report.ConfigResource = resource
+	return report, nil
+}
+
+// parseNetworkSecurityConfig parses a compiled network security config.
+func parseNetworkSecurityConfig(f *XMLFile) (*NetworkSecurityConfig, error) {
+	root := f.Root()
+	if root == nil || root.Name != "network-security-config" {
+		return nil, errors.New("not a network security config")
+	}
+	p := &nscParser{f: f}
+	config := new(NetworkSecurityConfig)
+	for _, e := range root.Elements("") {
+		switch e.Name {
+		case "base-config":
+			config.BaseConfig = p.networkConfig(e)
+		case "domain-config":
+			config.DomainConfigs = append(config.DomainConfigs, p.domainConfig(e))
+		case "debug-overrides":
+			config.DebugOverrides = p.networkConfig(e)
+		}
+	}
+	return config, p.err
+}
+
+// nscParser parses the elements of a network security config, keeping the
+// first error.
+type nscParser struct {
+	f   *XMLFile
+	err error
+}
+
+func (p *nscParser) attr(e *XMLElement, name string) string {
+	attr, ok := e.Attr("", name)
+	if !ok {
+		return ""
+	}
+	return p.f.AttrValue(attr, XMLEncodeOption{References: ReferenceName})
+}
+
+func (p *nscParser) boolAttr(e *XMLElement, name string) *bool {
+	s := p.attr(e, name)
+	if s == "" {
+		return nil
+	}
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		if p.err == nil {
+			p.err = fmt.Errorf("line %d: invalid %s %q", e.Line, name, s)
+		}
+		return nil
+	}
+	return &v
+}
+
+func (p *nscParser) networkConfig(e *XMLElement) *NetworkConfig {
+	config := &NetworkConfig{
+		CleartextTrafficPermitted: p.boolAttr(e, "cleartextTrafficPermitted"),
+	}
+	for _, anchors := range e.Elements("trust-anchors") {
+		// an empty <trust-anchors> trusts nothing, unlike a missing one.
+		config.TrustAnchors = []TrustAnchor{}
+		for _, c := range anchors.Elements("certificates") {
+			overridePins := p.boolAttr(c, "overridePins")
+			config.TrustAnchors = append(config.TrustAnchors, TrustAnchor{
+				Src:          p.attr(c, "src"),
+				OverridePins: overridePins != nil && *overridePins,
+			})
+		}
+	}
+	return config
+}
+
+func (p *nscParser) domainConfig(e *XMLElement) *DomainConfig {
+	config := &DomainConfig{NetworkConfig: *p.networkConfig(e)}
+	for _, c := range e.Elements("") {
+		switch c.Name {
+		case "domain":
+			includeSubdomains := p.boolAttr(c, "includeSubdomains")
+			config.Domains = append(config.Domains, Domain{
+				Name:              xmlText(c),
+				IncludeSubdomains: includeSubdomains != nil && *includeSubdomains,
+			})
+		case "pin-set":
+			pinSet := &PinSet{Expiration: p.attr(c, "expiration")}
+			for _, pin := range c.Elements("pin") {
+				pinSet.Pins = append(pinSet.Pins, Pin{
+					Digest: p.attr(pin, "digest"),
+					Value:  xmlText(pin),
+				})
+			}
+			config.PinSet = pinSet
+		case "domain-config":
+			config.DomainConfigs = append(config.DomainConfigs, p.domainConfig(c))
+		}
+	}
+	return config
+}
+
+// xmlText returns the text of e.
+func xmlText(e *XMLElement) string {
+	var s string
+	for _, c := range e.Children {
+		if text, ok := c.(XMLCharData); ok {
+			s += string(text)
+		}
+	}
+	return s
+}
+
+// effectiveNetworkConfig is a network config with every setting resolved.
+type effectiveNetworkConfig struct {
+	cleartext    bool
+	trustAnchors []TrustAnchor
+	pinSet       *PinSet
+	debugAnchors []TrustAnchor // added to the trust anchors of every config
+}
+
+func (c effectiveNetworkConfig) inherit(config *NetworkConfig) effectiveNetworkConfig {
+	if config.CleartextTrafficPermitted != nil {
+		c.cleartext = *config.CleartextTrafficPermitted
+	}
+	if config.TrustAnchors != nil {
+		c.trustAnchors = append(append([]TrustAnchor{}, config.TrustAnchors...), c.debugAnchors...)
+	}
+	return c
+}
+
+func (c effectiveNetworkConfig) trustsUserCAs() bool {
+	for _, anchor := range c.trustAnchors {
+		if anchor.Src == TrustAnchorUser {
+			return true
+		}
+	}
+	return false
+}
+
+// analyzeNetworkSecurity resolves the effective policy of config, which is
+// nil if the app declares none, following the inheritance rules and
+// platform defaults of the network security config.
+func analyzeNetworkSecurity(config *NetworkSecurityConfig, targetSdk int, usesCleartextTraffic *bool, debuggable bool, now time.Time) *NetworkSecurityReport {
+	base := effectiveNetworkConfig{
+		cleartext:    targetSdk < sdkPie,
+		trustAnchors: []TrustAnchor{{Src: TrustAnchorSystem}},
+	}
+	if targetSdk < sdkNougat {
+		base.trustAnchors = append(base.trustAnchors, TrustAnchor{Src: TrustAnchorUser})
+	}
+
+	report := &NetworkSecurityReport{Config: config}
+	if config == nil {
+		// android:usesCleartextTraffic is ignored when there is a config.
+		if usesCleartextTraffic != nil {
+			base.cleartext = *usesCleartextTraffic
+		}
+	} else {
+		if debuggable && config.DebugOverrides != nil {
+			report.Findings = append(report.Findings, Finding{
+				Rule:    RuleDebugOverrides,
+				Message: "debug overrides are active because the app is debuggable",
+			})
+			// like the platform, the anchors of the debug overrides are added
+			// to the base config and to every domain config.
+			base.debugAnchors = config.DebugOverrides.TrustAnchors
+			base.trustAnchors = append(append([]TrustAnchor{}, base.trustAnchors...), base.debugAnchors...)
+		}
+		if config.BaseConfig != nil {
+			base = base.inherit(config.BaseConfig)
+		}
+	}
+
+	report.CleartextTrafficPermitted = base.cleartext
+	report.UserCAsTrusted = base.trustsUserCAs()
+	if base.cleartext {
+		report.Findings = append(report.Findings, Finding{
+			Rule:    RuleCleartextTraffic,
+			Target:  "*",
+			Message: "cleartext traffic is permitted by default",
+		})
+	}
+	if report.UserCAsTrusted {
+		report.Findings = append(report.Findings, Finding{
+			Rule:    RuleUserCATrusted,
+			Target:  "*",
+			Message: "user-installed CAs are trusted by default",
+		})
+	}
+	if config != nil {
+		report.analyzeDomains(config.DomainConfigs, base, now)
+	}
+	return report
+}
+
+func (report *NetworkSecurityReport) analyzeDomains(configs []*DomainConfig, parent effectiveNetworkConfig, now time.Time) {
+	for _, config := range configs {
+		c := parent.inherit(&config.NetworkConfig)
+		if config.PinSet != nil {
+			c.pinSet = config.PinSet
+		}
+		for _, d := range config.Domains {
+			name := d.Name
+			if d.IncludeSubdomains {
+				name = "*." + name
+			}
+			if c.cleartext {
+				report.CleartextDomains = append(report.CleartextDomains, name)
+				report.Findings = append(report.Findings, Finding{
+					Rule:    RuleCleartextTraffic,
+					Target:  name,
+					Message: "cleartext traffic is permitted",
+				})
+			}
+			if c.trustsUserCAs() {
+				report.UserCADomains = append(report.UserCADomains, name)
+				report.Findings = append(report.Findings, Finding{
+					Rule:    RuleUserCATrusted,
+					Target:  name,
+					Message: "user-installed CAs are trusted",
+				})
+			}
+			expired, err := pinSetExpired(c.pinSet, now)
+			switch {
+			case c.pinSet == nil || len(c.pinSet.Pins) == 0:
+				report.UnpinnedDomains = append(report.UnpinnedDomains, name)
+				report.Findings = append(report.Findings, Finding{
+					Rule:    RuleMissingPins,
+					Target:  name,
+					Message: "no public key pins",
+				})
+			case err != nil:
+				report.Findings = append(report.Findings, Finding{
+					Rule:    RuleInvalidPins,
+					Target:  name,
+					Message: err.Error(),
+				})
+			case expired:
+				report.UnpinnedDomains = append(report.UnpinnedDomains, name)
+				report.Findings = append(report.Findings, Finding{
+					Rule:    RuleExpiredPins,
+					Target:  name,
+					Message: "public key pins expired on " + c.pinSet.Expiration,
+				})
+			}
+		}
+		report.analyzeDomains(config.DomainConfigs, c, now)
+	}
+}
+
+// pinSetExpired returns whether the pins of s, if any, are no longer
+// enforced at now, or an error if the expiration date is invalid. Android
+// fails to parse such a config, but the rest of it is still analyzed as
+// declared, with the pins reported as enforced.
+func pinSetExpired(s *PinSet, now time.Time) (bool, error) {
+	if s == nil || s.Expiration == "" {
+		return false, nil
+	}
+	expiration, err := time.Parse("2006-01-02", s.Expiration)
+	if err != nil {
+		return false, fmt.Errorf("invalid pin set expiration %q, which Android fails to parse; the pins are reported as enforced", s.Expiration)
+	}
+	return !now.Before(expiration), nil
+}
@@ -0,0 +1,164 @@
+package apkparser
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNetworkSecurity(t *testing.T) {
+	trueValue := xmlAttr{Type: TypeIntBoolean, Data: 0xFFFFFFFF}
+	attr := func(name string, a xmlAttr) xmlAttr {
+		a.Name = name
+		return a
+	}
+	config := &xmlElement{
+		Name: "network-security-config",
+		Children: []*xmlElement{
+			{Name: "base-config", Attrs: []xmlAttr{attr("cleartextTrafficPermitted", xmlAttr{Type: TypeIntBoolean})}},
+			{Name: "domain-config", Attrs: []xmlAttr{attr("cleartextTrafficPermitted", trueValue)}, Children: []*xmlElement{
+				{Name: "domain", Text: "legacy.example.com"},
+			}},
+			{Name: "domain-config", Children: []*xmlElement{
+				{Name: "domain", Attrs: []xmlAttr{attr("includeSubdomains", trueValue)}, Text: "example.com"},
+				{Name: "pin-set", Attrs: []xmlAttr{{Name: "expiration", Value: "2020-01-01"}}, Children: []*xmlElement{
+					{Name: "pin", Attrs: []xmlAttr{{Name: "digest", Value: "SHA-256"}}, Text: "7HIpactkIAq2Y49orFOOQKurWxmmSFZhBCoQYcRhJ3Y="},
+				}},
+				{Name: "trust-anchors", Children: []*xmlElement{
+					{Name: "certificates", Attrs: []xmlAttr{{Name: "src", Value: "user"}}},
+				}},
+				{Name: "domain-config", Children: []*xmlElement{
+					{Name: "domain", Text: "api.example.com"},
+					{Name: "pin-set", Children: []*xmlElement{
+						{Name: "pin", Attrs: []xmlAttr{{Name: "digest", Value: "SHA-256"}}, Text: "fwza0LRMXouZHRC8Ei+4PyuldPDcf3UKgO/04cDM1oE="},
+					}},
+				}},
+			}},
+			{Name: "debug-overrides", Children: []*xmlElement{
+				{Name: "trust-anchors", Children: []*xmlElement{
+					{Name: "certificates", Attrs: []xmlAttr{{Name: "src", Type: TypeReference, Data: 0x7f020000}}},
+				}},
+			}},
+		},
+	}
+	table := encodeTable(0x7f, "com.example.app", []tableType{
+		{ID: 1, Name: "xml", Entries: []*tableValue{
+			{Name: "network_security_config", Type: TypeString, String: "res/xml/network_security_config.xml"},
+		}},
+		{ID: 2, Name: "raw", Entries: []*tableValue{
+			{Name: "debug_ca", Type: TypeString, String: "res/raw/debug_ca.pem"},
+		}},
+	})
+	manifest := testManifest(
+		&xmlElement{Name: "uses-sdk", Attrs: []xmlAttr{
+			{NS: androidNamespace, Name: "targetSdkVersion", Type: TypeIntDec, Data: 33},
+		}},
+		&xmlElement{Name: "application", Attrs: []xmlAttr{
+			{NS: androidNamespace, Name: "networkSecurityConfig", Type: TypeReference, Data: 0x7f010000},
+			{NS: androidNamespace, Name: "usesCleartextTraffic", Type: TypeIntBoolean, Data: 0xFFFFFFFF},
+		}},
+	)
+	name := writeAPK(t,
+		zipFile{"AndroidManifest.xml", encodeXML(manifest)},
+		zipFile{"resources.arsc", table},
+		zipFile{"res/xml/network_security_config.xml", encodeXML(config)},
+	)
+
+	info, err := New(name, Option{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := info.NetworkSecurity
+	if report == nil {
+		t.Fatal("no network security report")
+	}
+	if report.ConfigResource != "@xml/network_security_config" {
+		t.Errorf("ConfigResource = %q", report.ConfigResource)
+	}
+	// usesCleartextTraffic is ignored in favor of the base config.
+	if report.CleartextTrafficPermitted || report.UserCAsTrusted {
+		t.Errorf("base config: cleartext %v, user CAs %v", report.CleartextTrafficPermitted, report.UserCAsTrusted)
+	}
+	if got := report.Config.DebugOverrides.TrustAnchors; len(got) != 1 || got[0].Src != "@raw/debug_ca" {
+		t.Errorf("debug overrides trust anchors = %+v", got)
+	}
+	nested := report.Config.DomainConfigs[1].DomainConfigs
+	if len(nested) != 1 || nested[0].Domains[0].Name != "api.example.com" {
+		t.Errorf("nested domain configs = %+v", nested)
+	}
+
+	want := []Finding{
+		{RuleCleartextTraffic, "legacy.example.com", "cleartext traffic is permitted"},
+		{RuleMissingPins, "legacy.example.com", "no public key pins"},
+		{RuleUserCATrusted, "*.example.com", "user-installed CAs are trusted"},
+		{RuleExpiredPins, "*.example.com", "public key pins expired on 2020-01-01"},
+		{RuleUserCATrusted, "api.example.com", "user-installed CAs are trusted"},
+	}
+	if !reflect.DeepEqual(report.Findings, want) {
+		t.Errorf("findings:\ngot  %+v\nwant %+v", report.Findings, want)
+	}
+	if want := []string{"legacy.example.com", "*.example.com"}; !reflect.DeepEqual(report.UnpinnedDomains, want) {
+		t.Errorf("UnpinnedDomains = %q, want %q", report.UnpinnedDomains, want)
+	}
+}
+
+func TestNetworkSecurityDefaults(t *testing.T) {
+	yes, no := true, false
+	for _, tt := range []struct {
+		targetSdk            int
+		usesCleartextTraffic *bool
+		cleartext, userCAs   bool
+	}{
+		{23, nil, true, true},
+		{24, nil, true, false},
+		{28, nil, false, false},
+		{28, &yes, true, false},
+		{27, &no, false, false},
+	} {
+		report := analyzeNetworkSecurity(nil, tt.targetSdk, tt.usesCleartextTraffic, false, time.Now())
+		if report.CleartextTrafficPermitted != tt.cleartext || report.UserCAsTrusted != tt.userCAs {
+			t.Errorf("targetSdk %d, usesCleartextTraffic %v: cleartext %v, user CAs %v; want %v, %v",
+				tt.targetSdk, tt.usesCleartextTraffic, report.CleartextTrafficPermitted, report.UserCAsTrusted, tt.cleartext, tt.userCAs)
+		}
+	}
+}
+
+func TestNetworkSecurityDebugOverrides(t *testing.T) {
+	pins := []Pin{{Digest: "SHA-256", Value: "7HIpactkIAq2Y49orFOOQKurWxmmSFZhBCoQYcRhJ3Y="}}
+	config := &NetworkSecurityConfig{
+		DomainConfigs: []*DomainConfig{
+			{
+				NetworkConfig: NetworkConfig{TrustAnchors: []TrustAnchor{{Src: TrustAnchorSystem}}},
+				Domains:       []Domain{{Name: "api.example.com"}},
+				PinSet:        &PinSet{Pins: pins},
+			},
+			{
+				Domains: []Domain{{Name: "cdn.example.com"}},
+				PinSet:  &PinSet{Expiration: "2020-13-45", Pins: pins},
+			},
+		},
+		DebugOverrides: &NetworkConfig{TrustAnchors: []TrustAnchor{{Src: TrustAnchorUser}}},
+	}
+
+	// the anchors of the debug overrides are added to the domain config,
+	// which declares its own anchors.
+	report := analyzeNetworkSecurity(config, 33, nil, true, time.Now())
+	want := []Finding{
+		{RuleDebugOverrides, "", "debug overrides are active because the app is debuggable"},
+		{RuleUserCATrusted, "*", "user-installed CAs are trusted by default"},
+		{RuleUserCATrusted, "api.example.com", "user-installed CAs are trusted"},
+		{RuleUserCATrusted, "cdn.example.com", "user-installed CAs are trusted"},
+		{RuleInvalidPins, "cdn.example.com", `invalid pin set expiration "2020-13-45", which Android fails to parse; the pins are reported as enforced`},
+	}
+	if !reflect.DeepEqual(report.Findings, want) {
+		t.Errorf("findings:\ngot  %+v\nwant %+v", report.Findings, want)
+	}
+	if report.UnpinnedDomains != nil {
+		t.Errorf("UnpinnedDomains = %q", report.UnpinnedDomains)
+	}
+
+	report = analyzeNetworkSecurity(config, 33, nil, false, time.Now())
+	if report.UserCAsTrusted || report.UserCADomains != nil {
+		t.Errorf("user CAs trusted without debuggable: %v, %q", report.UserCAsTrusted, report.UserCADomains)
+	}
+}
@@ -0,0 +1,398 @@
+package apkparser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+)
+
+// pngSignature is the first eight bytes of every PNG file.
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// PNG chunks written by AAPT when it compiles a nine-patch.
+const (
+	ninePatchChunk    = "npTc"
+	layoutBoundsChunk = "npLb"
+	outlineChunk      = "npOl"
+)
+
+// ninePatchHeaderSize is the size of the serialized Res_png_9patch header
+// that precedes the divs and colors in the npTc chunk.
+const ninePatchHeaderSize = 32
+
+// Values of NinePatch.Colors that aren't colors.
+const (
+	NinePatchTransparentColor = 0x00000000 // the region is fully transparent
+	NinePatchNoColor          = 0x00000001 // the region is not a solid color
+)
+
+// Insets are distances from the edges of a nine-patch.
+type Insets struct {
+	Left   int `json:"left"`
+	Top    int `json:"top"`
+	Right  int `json:"right"`
+	Bottom int `json:"bottom"`
+}
+
+// NinePatchOutline is the rounded rect outline AAPT computed for a nine-patch,
+// used by the platform to cast shadows.
+type NinePatchOutline struct {
+	Insets Insets  `json:"insets"`
+	Radius float32 `json:"radius"`
+	Alpha  uint32  `json:"alpha"`
+}
+
+// NinePatch is a stretchable bitmap, res/drawable*/*.9.png.
+// https://developer.android.com/studio/write/draw9patch
+type NinePatch struct {
+	// Image is the content of the bitmap, without the 1px border of
+	// nine-patches that weren't compiled by AAPT.
+	Image image.Image `json:"-"`
+
+	// XDivs and YDivs are pairs of [start, end) offsets of the stretchable
+	// columns and rows of Image.
+	XDivs []int `json:"xDivs"`
+	YDivs []int `json:"yDivs"`
+
+	// Padding is the area around the content of a view using the nine-patch
+	// as background.
+	Padding Insets `json:"padding"`
+
+	// Colors holds the solid color of every region, or one of
+	// NinePatchTransparentColor and NinePatchNoColor.
+	Colors []uint32 `json:"colors,omitempty"`
+
+	// LayoutBounds are the optical insets of the nine-patch.
+	LayoutBounds *Insets `json:"layoutBounds,omitempty"`
+
+	// Outline is the outline of the nine-patch, if AAPT computed one.
+	Outline *NinePatchOutline `json:"outline,omitempty"`
+}
+
+// DecodeNinePatch decodes a nine-patch PNG. Both nine-patches compiled by AAPT,
+// which carry the npTc, npLb and npOl chunks, and source nine-patches, which
+// carry their stretch regions in a border of black pixels, are supported.
+func DecodeNinePatch(r io.Reader) (*NinePatch, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	m, err := decodePNG(data)
+	if err != nil {
+		return nil, err
+	}
+
+	np := new(NinePatch)
+	chunks, err := readPNGChunks(data)
+	if err != nil {
+		return nil, err
+	}
+	npTc, ok := chunks[ninePatchChunk]
+	if !ok {
+		if err := np.readBorder(m); err != nil {
+			return nil, err
+		}
+		return np, nil
+	}
+
+	np.Image = m
+	if err := np.readNinePatchChunk(npTc); err != nil {
+		return nil, err
+	}
+	if npLb, ok := chunks[layoutBoundsChunk]; ok {
+		if len(npLb) < 16 {
+			return nil, errors.New("apkparser: npLb chunk is too short")
+		}
+		np.LayoutBounds = &Insets{
+			Left:   int(int32(binary.BigEndian.Uint32(npLb[0:]))),
+			Top:    int(int32(binary.BigEndian.Uint32(npLb[4:]))),
+			Right:  int(int32(binary.BigEndian.Uint32(npLb[8:]))),
+			Bottom: int(int32(binary.BigEndian.Uint32(npLb[12:]))),
+		}
+	}
+	if npOl, ok := chunks[outlineChunk]; ok {
+		if len(npOl) < 24 {
+			return nil, errors.New("apkparser: npOl chunk is too short")
+		}
+		np.Outline = &NinePatchOutline{
+			Insets: Insets{
+				Left:   int(int32(binary.BigEndian.Uint32(npOl[0:]))),
+				Top:    int(int32(binary.BigEndian.Uint32(npOl[4:]))),
+				Right:  int(int32(binary.BigEndian.Uint32(npOl[8:]))),
+				Bottom: int(int32(binary.BigEndian.Uint32(npOl[12:]))),
+			},
+			Radius: math.Float32frombits(binary.BigEndian.Uint32(npOl[16:])),
+			Alpha:  binary.BigEndian.Uint32(npOl[20:]),
+		}
+	}
+	return np, nil
+}
+
+func decodePNG(data []byte) (image.Image, error) {
+	m, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if format != "png" {
+		return nil, fmt.Errorf("apkparser: nine-patch is %s, not png", format)
+	}
+	return m, nil
+}
+
+// readPNGChunks returns the data of the chunks in a PNG file by type.
+func readPNGChunks(data []byte) (map[string][]byte, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, errors.New("apkparser: not a png file")
+	}
+	chunks := make(map[string][]byte)
+	data = data[len(pngSignature):]
+	for len(data) >= 12 {
+		length := binary.BigEndian.Uint32(data)
+		typ := string(data[4:8])
+		if uint64(length)+12 > uint64(len(data)) {
+			return nil, fmt.Errorf("apkparser: png chunk %q is truncated", typ)
+		}
+		chunks[typ] = data[8 : 8+length]
+		if typ == "IEND" {
+			break
+		}
+		data = data[12+length:]
+	}
+	return chunks, nil
+}
+
+// readNinePatchChunk parses a serialized Res_png_9patch.
+func (np *NinePatch) readNinePatchChunk(b []byte) error {
+	if len(b) < ninePatchHeaderSize {
+		return errors.New("apkparser: npTc chunk is too short")
+	}
+	numXDivs, numYDivs, numColors := int(b[1]), int(b[2]), int(b[3])
+	if len(b) < ninePatchHeaderSize+4*(numXDivs+numYDivs+numColors) {
+		return errors.New("apkparser: npTc chunk is truncated")
+	}
+	if numXDivs%2 != 0 || numYDivs%2 != 0 {
+		return errors.New("apkparser: npTc chunk has odd number of divs")
+	}
+	np.Padding = Insets{
+		Left:   int(int32(binary.BigEndian.Uint32(b[12:]))),
+		Right:  int(int32(binary.BigEndian.Uint32(b[16:]))),
+		Top:    int(int32(binary.BigEndian.Uint32(b[20:]))),
+		Bottom: int(int32(binary.BigEndian.Uint32(b[24:]))),
+	}
+
+	offset := ninePatchHeaderSize
+	readInts := func(n int) []int {
+		v := make([]int, n)
+		for i := range v {
+			v[i] = int(int32(binary.BigEndian.Uint32(b[offset:])))
+			offset += 4
+		}
+		return v
+	}
+	np.XDivs = readInts(numXDivs)
+	np.YDivs = readInts(numYDivs)
+	np.Colors = make([]uint32, numColors)
+	for i := range np.Colors {
+		np.Colors[i] = binary.BigEndian.Uint32(b[offset:])
+		offset += 4
+	}
+	return nil
+}
+
+// readBorder reads the stretch regions, padding and layout bounds from the
+// 1px border of a source nine-patch.
+func (np *NinePatch) readBorder(m image.Image) error {
+	b := m.Bounds()
+	if b.Dx() < 3 || b.Dy() < 3 {
+		return errors.New("apkparser: nine-patch is too small")
+	}
+	inner := image.Rect(b.Min.X+1, b.Min.Y+1, b.Max.X-1, b.Max.Y-1)
+	np.Image = subImage(m, inner)
+
+	top := borderPixels(m, image.Pt(inner.Min.X, b.Min.Y), image.Pt(1, 0), inner.Dx())
+	left := borderPixels(m, image.Pt(b.Min.X, inner.Min.Y), image.Pt(0, 1), inner.Dy())
+	bottom := borderPixels(m, image.Pt(inner.Min.X, b.Max.Y-1), image.Pt(1, 0), inner.Dx())
+	right := borderPixels(m, image.Pt(b.Max.X-1, inner.Min.Y), image.Pt(0, 1), inner.Dy())
+
+	np.XDivs = borderRanges(top, isTickColor)
+	np.YDivs = borderRanges(left, isTickColor)
+	if len(np.XDivs) == 0 || len(np.YDivs) == 0 {
+		return errors.New("apkparser: nine-patch has no stretch regions")
+	}
+
+	// padding defaults to the stretch regions when it isn't marked.
+	hpad := borderRanges(bottom, isTickColor)
+	if len(hpad) == 0 {
+		hpad = np.XDivs
+	}
+	vpad := borderRanges(right, isTickColor)
+	if len(vpad) == 0 {
+		vpad = np.YDivs
+	}
+	np.Padding = Insets{
+		Left:   hpad[0],
+		Top:    vpad[0],
+		Right:  inner.Dx() - hpad[len(hpad)-1],
+		Bottom: inner.Dy() - vpad[len(vpad)-1],
+	}
+
+	var bounds Insets
+	bounds.Left, bounds.Right = edgeRun(bottom, isLayoutBoundColor)
+	bounds.Top, bounds.Bottom = edgeRun(right, isLayoutBoundColor)
+	if bounds != (Insets{}) {
+		np.LayoutBounds = &bounds
+	}
+	return nil
+}
+
+func borderPixels(m image.Image, start, step image.Point, n int) []color.NRGBA {
+	pixels := make([]color.NRGBA, n)
+	for i := range pixels {
+		p := start.Add(step.Mul(i))
+		pixels[i] = color.NRGBAModel.Convert(m.At(p.X, p.Y)).(color.NRGBA)
+	}
+	return pixels
+}
+
+// borderRanges returns the [start, end) pairs of the runs of pixels matching fn.
+func borderRanges(pixels []color.NRGBA, fn func(color.NRGBA) bool) []int {
+	var ranges []int
+	in := false
+	for i, c := range pixels {
+		if fn(c) != in {
+			ranges = append(ranges, i)
+			in = !in
+		}
+	}
+	if in {
+		ranges = append(ranges, len(pixels))
+	}
+	return ranges
+}
+
+// edgeRun returns the length of the runs of pixels matching fn at both ends.
+func edgeRun(pixels []color.NRGBA, fn func(color.NRGBA) bool) (head, tail int) {
+	for head < len(pixels) && fn(pixels[head]) {
+		head++
+	}
+	for tail < len(pixels)-head && fn(pixels[len(pixels)-1-tail]) {
+		tail++
+	}
+	return
+}
+
+func isTickColor(c color.NRGBA) bool {
+	return c == color.NRGBA{A: 0xff}
+}
+
+func isLayoutBoundColor(c color.NRGBA) bool {
+	return c == color.NRGBA{R: 0xff, A: 0xff}
+}
+
+func subImage(m image.Image, r image.Rectangle) image.Image {
+	if s, ok := m.(interface {
+		SubImage(image.Rectangle) image.Image
+	}); ok {
+		return s.SubImage(r)
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	for y := 0; y < r.Dy(); y++ {
+		for x := 0; x < r.Dx(); x++ {
+			dst.Set(x, y, m.At(r.Min.X+x, r.Min.Y+y))
+		}
+	}
+	return dst
+}
+
+// Render draws the nine-patch at the given size, stretching only its
+// stretchable regions. When the size is smaller than the fixed regions,
+// the fixed regions are shrunk proportionally.
+func (np *NinePatch) Render(width, height int) (image.Image, error) {
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("apkparser: invalid nine-patch size %dx%d", width, height)
+	}
+	b := np.Image.Bounds()
+	xs, err := stretchAxis(np.XDivs, b.Dx(), width)
+	if err != nil {
+		return nil, err
+	}
+	ys, err := stretchAxis(np.YDivs, b.Dy(), height)
+	if err != nil {
+		return nil, err
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y, sy := range ys {
+		for x, sx := range xs {
+			dst.Set(x, y, np.Image.At(b.Min.X+sx, b.Min.Y+sy))
+		}
+	}
+	return dst, nil
+}
+
+// stretchAxis maps every destination offset along one axis of length dstLen
+// to an offset in the source of length srcLen.
+func stretchAxis(divs []int, srcLen, dstLen int) ([]int, error) {
+	type segment struct {
+		start, end int
+		stretch    bool
+	}
+	var segments []segment
+	prev, stretchLen := 0, 0
+	for i := 0; i+1 < len(divs); i += 2 {
+		start, end := divs[i], divs[i+1]
+		if start < prev || end < start || end > srcLen {
+			return nil, fmt.Errorf("apkparser: invalid nine-patch div [%d, %d)", start, end)
+		}
+		if start > prev {
+			segments = append(segments, segment{prev, start, false})
+		}
+		segments = append(segments, segment{start, end, true})
+		stretchLen += end - start
+		prev = end
+	}
+	if prev < srcLen {
+		segments = append(segments, segment{prev, srcLen, false})
+	}
+	fixedLen := srcLen - stretchLen
+
+	// distribute the destination length over the segments; the remainder of
+	// the integer divisions goes to the last segment that may grow.
+	lengths := make([]int, len(segments))
+	remain := dstLen
+	last := -1
+	for i, s := range segments {
+		n := s.end - s.start
+		switch {
+		case dstLen >= fixedLen && !s.stretch:
+			lengths[i] = n
+		case dstLen >= fixedLen && stretchLen > 0:
+			lengths[i] = n * (dstLen - fixedLen) / stretchLen
+			last = i
+		case dstLen < fixedLen && !s.stretch:
+			lengths[i] = n * dstLen / fixedLen
+			last = i
+		}
+		remain -= lengths[i]
+	}
+	if last < 0 {
+		// nothing may stretch; scale the whole axis.
+		segments = []segment{{0, srcLen, true}}
+		lengths = []int{dstLen}
+		last, remain = 0, 0
+	}
+	lengths[last] += remain
+
+	offsets := make([]int, 0, dstLen)
+	for i, s := range segments {
+		for j := 0; j < lengths[i]; j++ {
+			offsets = append(offsets, s.start+j*(s.end-s.start)/lengths[i])
+		}
+	}
+	return offsets, nil
+}
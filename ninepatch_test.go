@@ -0,0 +1,103 @@
+package apkparser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+// insertPNGChunk inserts a chunk right after the IHDR chunk of a PNG file.
+func insertPNGChunk(t *testing.T, data []byte, typ string, payload []byte) []byte {
+	t.Helper()
+	ihdrEnd := len(pngSignature) + 8 + 13 + 4
+	chunk := make([]byte, 8, 12+len(payload))
+	binary.BigEndian.PutUint32(chunk, uint32(len(payload)))
+	copy(chunk[4:], typ)
+	chunk = append(chunk, payload...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+
+	out := append([]byte{}, data[:ihdrEnd]...)
+	out = append(out, chunk...)
+	return append(out, data[ihdrEnd:]...)
+}
+
+func TestDecodeCompiledNinePatch(t *testing.T) {
+	m := image.NewNRGBA(image.Rect(0, 0, 3, 3))
+	for i := 0; i < 9; i++ {
+		m.Pix[4*i] = uint8(i)
+		m.Pix[4*i+3] = 0xff
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, m); err != nil {
+		t.Fatal(err)
+	}
+
+	npTc := []byte{0, 2, 2, 0}
+	for _, v := range []uint32{0, 0, 1, 2, 3, 4, 0, 1, 2, 1, 2} {
+		npTc = binary.BigEndian.AppendUint32(npTc, v)
+	}
+	npLb := []byte{0, 0, 0, 1, 0, 0, 0, 2, 0, 0, 0, 3, 0, 0, 0, 4}
+	data := insertPNGChunk(t, buf.Bytes(), "npTc", npTc)
+	data = insertPNGChunk(t, data, "npLb", npLb)
+
+	np, err := DecodeNinePatch(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Insets{Left: 1, Right: 2, Top: 3, Bottom: 4}); np.Padding != want {
+		t.Errorf("Padding = %+v, want %+v", np.Padding, want)
+	}
+	if want := (Insets{Left: 1, Top: 2, Right: 3, Bottom: 4}); np.LayoutBounds == nil || *np.LayoutBounds != want {
+		t.Errorf("LayoutBounds = %+v, want %+v", np.LayoutBounds, want)
+	}
+	if len(np.XDivs) != 2 || np.XDivs[0] != 1 || np.XDivs[1] != 2 {
+		t.Errorf("XDivs = %v, want [1 2]", np.XDivs)
+	}
+
+	out, err := np.Render(7, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the middle column is stretched, the edge columns keep their size.
+	for x, want := range []uint8{0, 1, 1, 1, 1, 1, 2} {
+		if r, _, _, _ := out.At(x, 0).RGBA(); uint8(r>>8) != want {
+			t.Errorf("pixel (%d, 0) = %d, want %d", x, r>>8, want)
+		}
+	}
+}
+
+func TestDecodeSourceNinePatch(t *testing.T) {
+	m := image.NewNRGBA(image.Rect(0, 0, 6, 6))
+	black := color.NRGBA{A: 0xff}
+	m.Set(2, 0, black)
+	m.Set(3, 0, black)
+	m.Set(0, 1, black)
+	m.Set(1, 5, black)
+	m.Set(2, 5, black)
+	m.Set(3, 5, black)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, m); err != nil {
+		t.Fatal(err)
+	}
+
+	np, err := DecodeNinePatch(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := np.Image.Bounds(); b.Dx() != 4 || b.Dy() != 4 {
+		t.Errorf("content is %dx%d, want 4x4", b.Dx(), b.Dy())
+	}
+	if len(np.XDivs) != 2 || np.XDivs[0] != 1 || np.XDivs[1] != 3 {
+		t.Errorf("XDivs = %v, want [1 3]", np.XDivs)
+	}
+	if len(np.YDivs) != 2 || np.YDivs[0] != 0 || np.YDivs[1] != 1 {
+		t.Errorf("YDivs = %v, want [0 1]", np.YDivs)
+	}
+	if want := (Insets{Left: 0, Top: 0, Right: 1, Bottom: 3}); np.Padding != want {
+		t.Errorf("Padding = %+v, want %+v", np.Padding, want)
+	}
+}
@@ -3,6 +3,7 @@ package apkparser
 import (
 	"errors"
 	"image"
+	"io"
 	"math"
 
 	ap "github.com/avast/apkparser"
@@ -19,15 +20,33 @@ type AppInfo struct {
 	Version          string      `json:"version,omitempty"`     // 版本名称
 	Build            int64       `json:"build,omitempty"`       // 版本号
 	Icon             image.Image `json:"icon,omitempty"`        // app icon
+	RoundIcon        image.Image `json:"roundIcon,omitempty"`   // app round icon
+	Banner           image.Image `json:"banner,omitempty"`      // Android TV banner
+	Logo             image.Image `json:"logo,omitempty"`        // app logo
 	Size             int64       `json:"size,omitempty"`        // app size in bytes
 	CertInfo         *CertInfo   `json:"certInfo,omitempty"`    // app 证书信息
 	Md5              string      `json:"md5,omitempty"`         // app md5
 	SupportOS64      bool        `json:"supportOS64,omitempty"` // 是否支持64位
 	SupportOS32      bool        `json:"supportOS32,omitempty"` // 是否支持32位
+	ABIs             []string    `json:"abis,omitempty"`        // 原生库支持的ABI
 	Permissions      []string    `json:"permissions,omitempty"` // 权限列表
 	MinSdkVersion    int         `json:"minSdkVersion"`         // 最小兼容rom版本
 	MaxSdkVersion    int         `json:"maxSdkVersion"`         // 最大兼容rom版本
 	TargetSdkVersion int         `json:"targetSdkVersion"`      // 推荐rom版本
+
+	NetworkSecurity *NetworkSecurityReport `json:"networkSecurity,omitempty"` // 网络安全配置分析
+	DeepLinks       []DeepLink             `json:"deepLinks,omitempty"`       // 深度链接
+	AttackSurface   *AttackSurface         `json:"attackSurface,omitempty"`   // 导出组件攻击面
+
+	PermissionDetails []Permission           `json:"permissionDetails,omitempty"` // 权限详情(保护级别、权限组、分类)
+	CustomPermissions []PermissionDefinition `json:"customPermissions,omitempty"` // 应用自定义的权限
+	Features          *FeatureRequirements   `json:"features,omitempty"`          // 设备特性要求
+	Frameworks        []Framework            `json:"frameworks,omitempty"`        // 使用的开发框架(Flutter、React Native、Unity等)
+	SDKs              []SDK                  `json:"sdks,omitempty"`              // 集成的第三方SDK
+	Dex               *DexReport             `json:"dex,omitempty"`               // dex文件信息(版本、方法数、multidex)
+	Protection        *ProtectionReport      `json:"protection,omitempty"`        // 混淆和加固检测
+
+	Warnings []Warning `json:"warnings,omitempty"` // 解析中发现的异常(如畸形的zip文件)
 }
 type CertInfo struct {
 	Md5    string `json:"md5,omitempty"`
@@ -42,39 +61,82 @@ type CertInfo struct {
 }
 
 type Option struct {
-	WithSignature        bool // 是否需要获取签名信息
-	IgnoreSignatureError bool // 是否忽略签名错误，默认不忽略
-	WithIcon             bool // 是否需要获取icon信息
+	WithSignature        bool   // 是否需要获取签名信息
+	IgnoreSignatureError bool   // 是否忽略签名错误，默认不忽略
+	WithIcon             bool   // 是否需要获取icon信息(包括圆形图标、横幅和logo)
+	WithDex              bool   // 是否需要解析dex文件(dex文件信息、识别第三方SDK和开发框架、混淆和加固检测)
+	WithEngineVersion    bool   // 是否需要从原生库中提取开发框架的引擎版本(如Flutter，需要解压libflutter.so)
+	Tolerant             bool   // 容错模式，像Android的libziparchive一样读取畸形的zip文件，并报告发现的异常
+	BestEffort           bool   // 尽力模式，资源、图标、名称、签名、ABI、dex、网络安全配置解析失败时返回已解析的信息，并在Warnings中报告失败的阶段
+	Limits               Limits // 解析的资源限制(防止zip炸弹等恶意输入)，零值字段使用DefaultLimits
+
+	SDKDatabase *SDKDatabase // 识别第三方SDK的规则库，默认为DefaultSDKDatabase
 }
 
+// Codes of the warnings about the stages of parsing which failed in
+// best-effort mode.
+const (
+	WarningResources       = "resources"        // resources.arsc is missing or malformed
+	WarningLabel           = "label"            // the label can't be resolved
+	WarningIcon            = "icon"             // an icon, banner or logo can't be decoded
+	WarningSignature       = "signature"        // the signature can't be verified
+	WarningABI             = "abi"              // native libraries outside of known ABIs
+	WarningDex             = "dex"              // the DEX files can't be parsed
+	WarningFrameworks      = "frameworks"       // the classes to detect frameworks by can't be read
+	WarningProtection      = "protection"       // the DEX files to detect obfuscation by can't be read
+	WarningSDK             = "sdk"              // the classes to detect SDKs by can't be read
+	WarningNetworkSecurity = "network-security" // the network security config can't be parsed
+)
+
 func New(name string, option Option) (*AppInfo, error) {
-	infoApk, err := openFile(name)
+	infoApk, err := Open(name, option)
 	if err != nil {
 		return nil, err
 	}
 	// 释放资源
-	defer infoApk.close()
+	defer infoApk.Close()
+
+	return infoApk.k.appInfo(option)
+}
+
+// appInfo returns the information of the APK selected by option, parsing
+// the parts of the APK it needs.
+func (k *apk) appInfo(option Option) (*AppInfo, error) {
+	if err := k.load(); err != nil {
+		return nil, err
+	}
+	digests, _ := k.loadDigests()
 
 	info := &AppInfo{
-		Name:             infoApk.parseApkLabel(),
-		BundleId:         infoApk.apkManifest.Package,
-		Version:          infoApk.apkManifest.VersionName,
-		Build:            infoApk.apkManifest.VersionCode,
-		Size:             infoApk.size,
-		Md5:              infoApk.md5,
-		SupportOS64:      infoApk.supportOs64,
-		SupportOS32:      infoApk.supportOs32,
-		Permissions:      formatPermissions(infoApk.apkManifest.Permissions),
-		MinSdkVersion:    infoApk.apkManifest.SDK.Min,
-		MaxSdkVersion:    infoApk.apkManifest.SDK.Max,
-		TargetSdkVersion: infoApk.apkManifest.SDK.Target,
+		Name:             k.parseApkLabel(),
+		BundleId:         k.apkManifest.Package,
+		Version:          k.apkManifest.VersionName,
+		Build:            k.apkManifest.VersionCode,
+		Size:             k.size,
+		Md5:              digests.MD5,
+		SupportOS64:      k.supportOs64,
+		SupportOS32:      k.supportOs32,
+		ABIs:             k.abis,
+		Permissions:      formatPermissions(k.apkManifest.Permissions, k.apkManifest.PermissionsSDK23),
+		MinSdkVersion:    k.apkManifest.SDK.Min,
+		MaxSdkVersion:    k.apkManifest.SDK.Max,
+		TargetSdkVersion: k.apkManifest.SDK.Target,
+		NetworkSecurity:  k.parseApkNetworkSecurity(),
+		DeepLinks:        k.deepLinks(),
+		AttackSurface:    k.attackSurface(),
+
+		PermissionDetails: k.permissions(),
+		CustomPermissions: k.customPermissions(),
+		Features:          k.featureRequirements(),
+		Frameworks:        k.parseApkFrameworks(option.WithDex, option.WithEngineVersion),
 	}
 
 	// 获取证书信息
 	if option.WithSignature {
-		certInfo, errCert := getSignature(infoApk)
+		certInfo, errCert := k.loadSignature()
 		if errCert != nil {
-			if !option.IgnoreSignatureError {
+			k.failed(WarningSignature, errCert)
+			if !option.IgnoreSignatureError && !k.bestEffort {
 				return nil, errCert
 			}
 		} else {
@@ -83,18 +145,116 @@ func New(name string, option Option) (*AppInfo, error) {
 	}
 	if option.WithIcon {
 		// 获取icon信息
-		info.Icon = infoApk.parseApkIcon()
+		info.Icon = k.parseApkIcon()
+		info.RoundIcon = k.parseApkRoundIcon()
+		info.Banner = k.parseApkBanner()
+		info.Logo = k.parseApkLogo()
+	}
+	if option.WithDex {
+		// 解析dex文件信息、识别第三方SDK、检测混淆和加固
+		info.Dex = k.parseApkDex()
+		info.SDKs = k.parseApkSDKs(option.SDKDatabase)
+		info.Protection = k.parseApkProtection()
 	}
+	info.Warnings = k.archive.recorded()
 
 	return info, nil
 }
 
+// ReadTable returns the resource table, resources.arsc, of the APK file name.
+func ReadTable(name string) (*TableFile, error) {
+	infoApk, err := openLazyFile(name, Option{})
+	if err != nil {
+		return nil, err
+	}
+	defer infoApk.close()
+
+	if err := infoApk.loadResources(); err != nil {
+		return nil, err
+	}
+	return infoApk.table, nil
+}
+
+// DecodeManifest writes the AndroidManifest.xml of the APK file name to w in
+// text format. References to resources are substituted with the resource
+// table of the APK according to option.References, unless it can't be read.
+func DecodeManifest(name string, w io.Writer, option XMLEncodeOption) error {
+	infoApk, err := openLazyFile(name, Option{})
+	if err != nil {
+		return err
+	}
+	defer infoApk.close()
+
+	if err := infoApk.loadManifest(); err != nil {
+		return err
+	}
+	if infoApk.loadResources() == nil {
+		infoApk.manifestXML.table = infoApk.table
+	}
+	return infoApk.manifestXML.Encode(w, option)
+}
+
+// ReadXML returns the compiled XML file at path in the APK file name, e.g.
+// res/xml/network_security_config.xml. References to resources are resolved
+// with the resource table of the APK when the file is encoded, unless it
+// can't be read.
+func ReadXML(name, path string) (*XMLFile, error) {
+	infoApk, err := openLazyFile(name, Option{})
+	if err != nil {
+		return nil, err
+	}
+	defer infoApk.close()
+
+	return infoApk.readXML(path)
+}
+
+// ReadXMLResource returns the compiled XML file of the resource id, e.g.
+// @xml/file_paths, in the APK file name, under the configuration resConfig.
+// The resource table of the APK is required to find the file.
+func ReadXMLResource(name string, id ResID, resConfig *ResTableConfig) (*XMLFile, error) {
+	infoApk, err := openLazyFile(name, Option{})
+	if err != nil {
+		return nil, err
+	}
+	defer infoApk.close()
+
+	if err := infoApk.loadResources(); err != nil {
+		return nil, err
+	}
+	return infoApk.readXMLResource(id, resConfig)
+}
+
+// ReadDexClasses returns an iterator over the classes of the DEX files,
+// classes.dex and classesN.dex, of the APK file name, in load order.
+func ReadDexClasses(name string) (*DexClassIterator, error) {
+	infoApk, err := openLazyFile(name, Option{})
+	if err != nil {
+		return nil, err
+	}
+	defer infoApk.close()
+
+	return infoApk.dexClasses()
+}
+
+// ReadSizeReport returns the sizes of the zip entries of the APK file name,
+// with totals by category and top-level directory.
+func ReadSizeReport(name string) (*SizeReport, error) {
+	infoApk, err := openLazyFile(name, Option{})
+	if err != nil {
+		return nil, err
+	}
+	defer infoApk.close()
+
+	return infoApk.sizeReport(), nil
+}
+
 // 获取apk签名
 func getSignature(apk *apk) (*CertInfo, error) {
 	// res, err := apkverifier.Verify(apkPath, nil)
-	optionalZip, err := ap.OpenZipReader(apk.f)
+	r := io.NewSectionReader(apk.r, 0, apk.size)
+	optionalZip, err := ap.OpenZipReader(r)
 	if err != nil {
-		return nil, err
+		return nil, &kindError{ErrInvalidSignature, err}
 	}
 	defer optionalZip.Close()
 	maxSdkVersion := apk.apkManifest.SDK.Max
@@ -102,18 +262,18 @@ func getSignature(apk *apk) (*CertInfo, error) {
 		maxSdkVersion = math.MaxInt32
 	}
 	res, err := apkverifier.VerifyWithSdkVersionReader(
-		apk.f,
+		r,
 		optionalZip,
 		int32(apk.apkManifest.SDK.Min),
 		int32(maxSdkVersion),
 	)
 	if err != nil {
-		return nil, err
+		return nil, &kindError{ErrInvalidSignature, err}
 	}
 
 	cert, _ := apkverifier.PickBestApkCert(res.SignerCerts)
 	if cert == nil {
-		return nil, errors.New("no certificate found")
+		return nil, &kindError{ErrInvalidSignature, errors.New("no certificate found")}
 	}
 
 	return &CertInfo{
@@ -129,10 +289,12 @@ func getSignature(apk *apk) (*CertInfo, error) {
 	}, nil
 }
 
-func formatPermissions(permissions []permission) []string {
+func formatPermissions(lists ...[]permission) []string {
 	var result []string
-	for _, v := range permissions {
-		result = append(result, v.Name)
+	for _, permissions := range lists {
+		for _, v := range permissions {
+			result = append(result, v.Name)
+		}
 	}
 
 	return result
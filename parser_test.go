@@ -1,9 +1,14 @@
 package apkparser
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
 	"image/png"
+	"io"
 	"log"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -44,3 +49,226 @@ func TestAppParser(t *testing.T) {
 		}
 	}
 }
+
+func FuzzNew(f *testing.F) {
+	manifest := encodeXML(testManifest(&xmlElement{Name: "application", Attrs: []xmlAttr{
+		{NS: androidNamespace, Name: "label", Type: TypeReference, Data: 0x7f010000},
+		{NS: androidNamespace, Name: "icon", Type: TypeReference, Data: 0x7f010001},
+	}, Children: []*xmlElement{{Name: "activity", Attrs: []xmlAttr{{NS: androidNamespace, Name: "name", Value: ".Main"}}}}}))
+	// a DEX header whose type_ids don't fit in the file.
+	hostile := encodeDex("La;")
+	binary.LittleEndian.PutUint32(hostile[0x40:], 0x40000000)
+	for _, tolerant := range []bool{false, true} {
+		f.Add(encodeZip(f,
+			zipFile{"AndroidManifest.xml", manifest},
+			zipFile{"resources.arsc", testTable()},
+			zipFile{"classes.dex", hostile},
+		), tolerant)
+		f.Add(encodeZip(f,
+			zipFile{"AndroidManifest.xml", manifest},
+			zipFile{"resources.arsc", testTable()},
+		), tolerant)
+		f.Add(encodeZip(f,
+			zipFile{"AndroidManifest.xml", manifest},
+			zipFile{"resources.arsc", testTable()},
+			zipFile{"classes.dex", encodeDexClasses(nil, testDexClass{Descriptor: "Lcom/example/app/Main;", Superclass: "Ljava/lang/Object;"})},
+			zipFile{"lib/arm64-v8a/libnative.so", []byte("\x7fELF")},
+		), tolerant)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte, tolerant bool) {
+		limits := Limits{MaxEntrySize: 1 << 20, MaxTotalSize: 8 << 20}
+		k, err := openZipReader(bytes.NewReader(data), int64(len(data)), Option{Tolerant: tolerant, BestEffort: true, Limits: limits})
+		if err != nil {
+			return
+		}
+		_, _ = k.appInfo(Option{WithSignature: true, IgnoreSignatureError: true, WithIcon: true, WithDex: true, WithEngineVersion: true})
+		_ = k.sizeReport()
+		_ = k.snapshot()
+		if it, err := k.dexClasses(); err == nil {
+			for it.Next() {
+				_ = it.Class()
+			}
+		}
+	})
+}
+
+func TestBestEffort(t *testing.T) {
+	manifest := encodeXML(testManifest(&xmlElement{Name: "application", Attrs: []xmlAttr{
+		{NS: androidNamespace, Name: "label", Type: TypeReference, Data: 0x7f0100ff},
+		{NS: androidNamespace, Name: "icon", Type: TypeReference, Data: 0x7f010000},
+	}}))
+	table := encodeTable(0x7f, "com.example.app", []tableType{
+		{ID: 1, Name: "drawable", Entries: []*tableValue{
+			{Name: "icon", Type: TypeString, String: "res/drawable/icon.png"},
+		}},
+	})
+	damaged := writeAPK(t,
+		zipFile{"AndroidManifest.xml", manifest},
+		zipFile{"resources.arsc", table},
+		zipFile{"res/drawable/icon.png", []byte("\x89PNG")},
+		zipFile{"lib/arm64-v8a/libnative.so", []byte("\x7fELF")},
+		zipFile{"lib/mystery/libnative.so", []byte("\x7fELF")},
+	)
+	noResources := writeAPK(t, zipFile{"AndroidManifest.xml", manifest})
+
+	tests := []struct {
+		name   string
+		apk    string
+		option Option
+		codes  []string
+	}{
+		{
+			name:   "damaged",
+			apk:    damaged,
+			option: Option{WithIcon: true, WithSignature: true},
+			codes:  []string{WarningABI, WarningLabel, WarningSignature, WarningIcon},
+		},
+		{
+			name:  "no resources",
+			apk:   noResources,
+			codes: []string{WarningResources, WarningLabel},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.apk, tt.option); err == nil {
+				t.Error("New() succeeded without BestEffort")
+			}
+
+			option := tt.option
+			option.BestEffort = true
+			info, err := New(tt.apk, option)
+			if err != nil {
+				t.Fatalf("New(BestEffort) error = %v", err)
+			}
+			if info.BundleId != "com.example.app" || info.Build != 42 {
+				t.Errorf("BundleId = %q, Build = %d", info.BundleId, info.Build)
+			}
+			var codes []string
+			for _, w := range info.Warnings {
+				codes = append(codes, w.Code)
+			}
+			if len(codes) != len(tt.codes) {
+				t.Fatalf("Warnings = %v, want codes %q", info.Warnings, tt.codes)
+			}
+			for i, code := range tt.codes {
+				if codes[i] != code {
+					t.Errorf("Warnings = %v, want codes %q", info.Warnings, tt.codes)
+				}
+			}
+		})
+	}
+}
+
+func TestBestEffortDex(t *testing.T) {
+	broken := encodeDex("Lcom/example/app/Main;")[:0x40]
+	name := writeAPK(t,
+		zipFile{"AndroidManifest.xml", encodeXML(testManifest())},
+		zipFile{"resources.arsc", testTable()},
+		zipFile{"classes.dex", broken},
+	)
+
+	// the DEX files don't prevent parsing the rest of the APK.
+	info, err := New(name, Option{WithDex: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Dex != nil || len(info.Warnings) != 0 {
+		t.Errorf("Dex = %+v, Warnings = %v", info.Dex, info.Warnings)
+	}
+
+	info, err = New(name, Option{WithDex: true, BestEffort: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{WarningDex: true, WarningFrameworks: true, WarningSDK: true, WarningProtection: true}
+	for _, w := range info.Warnings {
+		if !want[w.Code] || w.Entry != "classes.dex" {
+			t.Errorf("unexpected warning %+v", w)
+		}
+		delete(want, w.Code)
+	}
+	if len(want) != 0 {
+		t.Errorf("Warnings = %v, missing codes %v", info.Warnings, want)
+	}
+	if info.Protection == nil {
+		t.Error("no protection report")
+	}
+}
+
+func TestReadXML(t *testing.T) {
+	table := encodeTable(0x7f, "com.example.app", []tableType{
+		{ID: 1, Name: "xml", Entries: []*tableValue{
+			{Name: "file_paths", Type: TypeString, String: "res/xml/file_paths.xml"},
+		}},
+		{ID: 2, Name: "string", Entries: []*tableValue{
+			{Name: "images", Type: TypeString, String: "images"},
+		}},
+		{ID: 3, Name: "color", Entries: []*tableValue{
+			{Name: "accent", Type: TypeIntColorARGB8, Data: 0xff336699},
+		}},
+	})
+	paths := &xmlElement{Name: "paths", Children: []*xmlElement{{Name: "files-path", Attrs: []xmlAttr{
+		{Name: "name", Type: TypeReference, Data: 0x7f020000},
+		{Name: "path", Value: "images/"},
+	}}}}
+	name := writeAPK(t,
+		zipFile{"AndroidManifest.xml", encodeXML(testManifest())},
+		zipFile{"resources.arsc", table},
+		zipFile{"res/xml/file_paths.xml", encodeXML(paths)},
+	)
+
+	check := func(f *XMLFile, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		children := f.Root().Elements("files-path")
+		if f.Root().Name != "paths" || len(children) != 1 {
+			t.Fatalf("Root() = %+v", f.Root())
+		}
+		attr, _ := children[0].Attr("", "name")
+		if v := f.AttrValue(attr, XMLEncodeOption{References: ReferenceName}); v != "@string/images" {
+			t.Errorf("name = %q, want @string/images", v)
+		}
+		if v := f.AttrValue(attr, XMLEncodeOption{References: ReferenceValue}); v != "images" {
+			t.Errorf("name = %q, want images", v)
+		}
+	}
+	check(ReadXML(name, "res/xml/file_paths.xml"))
+	check(ReadXMLResource(name, 0x7f010000, &ResTableConfig{}))
+
+	_, err := ReadXML(name, "res/xml/missing.xml")
+	var entryErr *EntryError
+	if !errors.Is(err, ErrEntryNotFound) || !errors.As(err, &entryErr) || entryErr.Name != "res/xml/missing.xml" {
+		t.Errorf("ReadXML() of a missing entry error = %v", err)
+	}
+	if _, err := ReadXMLResource(name, 0x7f030000, &ResTableConfig{}); err == nil || !strings.Contains(err.Error(), "is not a file") {
+		t.Errorf("ReadXMLResource() of a color error = %v", err)
+	}
+	if _, err := ReadXMLResource(name, 0x7f010001, &ResTableConfig{}); err == nil {
+		t.Error("ReadXMLResource() of a missing resource succeeded")
+	}
+
+	// without resources, the references are left as IDs.
+	name = writeAPK(t,
+		zipFile{"AndroidManifest.xml", encodeXML(testManifest())},
+		zipFile{"resources.arsc", table[:20]},
+		zipFile{"res/xml/file_paths.xml", encodeXML(paths)},
+	)
+	f, err := ReadXML(name, "res/xml/file_paths.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	attr, _ := f.Root().Elements("files-path")[0].Attr("", "name")
+	if v := f.AttrValue(attr, XMLEncodeOption{References: ReferenceName}); v != "@0x7F020000" {
+		t.Errorf("name without resources = %q, want @0x7F020000", v)
+	}
+	if err := DecodeManifest(name, io.Discard, XMLEncodeOption{References: ReferenceID}); err != nil {
+		t.Errorf("DecodeManifest() without resources error = %v", err)
+	}
+	if _, err := ReadXMLResource(name, 0x7f010000, &ResTableConfig{}); !errors.Is(err, ErrMalformed) {
+		t.Errorf("ReadXMLResource() without resources error = %v, want ErrMalformed", err)
+	}
+}
@@ -0,0 +1,225 @@
+package apkparser
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Base protection levels of permissions, the low 4 bits of
+// android:protectionLevel.
+const (
+	ProtectionNormal            = "normal"
+	ProtectionDangerous         = "dangerous"
+	ProtectionSignature         = "signature"
+	ProtectionSignatureOrSystem = "signatureOrSystem"
+	ProtectionInternal          = "internal"
+)
+
+// protectionBases are the base protection levels, by value.
+var protectionBases = []string{
+	ProtectionNormal,
+	ProtectionDangerous,
+	ProtectionSignature,
+	ProtectionSignatureOrSystem,
+	ProtectionInternal,
+}
+
+// protectionFlags are the flags of android:protectionLevel, in attrs.xml order.
+var protectionFlags = []struct {
+	mask uint32
+	name string
+}{
+	{0x10, "privileged"},
+	{0x20, "development"},
+	{0x40, "appop"},
+	{0x80, "pre23"},
+	{0x100, "installer"},
+	{0x200, "verifier"},
+	{0x400, "preinstalled"},
+	{0x800, "setup"},
+	{0x1000, "instant"},
+	{0x2000, "runtime"},
+	{0x4000, "oem"},
+	{0x8000, "vendorPrivileged"},
+	{0x10000, "textClassifier"},
+	{0x20000, "wellbeing"},
+	{0x40000, "documenter"},
+	{0x80000, "configurator"},
+	{0x100000, "incidentReportApprover"},
+	{0x200000, "appPredictor"},
+	{0x400000, "module"},
+	{0x800000, "companion"},
+	{0x1000000, "retailDemo"},
+	{0x2000000, "recents"},
+	{0x4000000, "role"},
+	{0x8000000, "knownSigner"},
+}
+
+// formatProtectionLevel formats the value of android:protectionLevel, e.g.
+// 0x00000012, as its names, e.g. signature|privileged. Values already
+// written as names are returned as is.
+func formatProtectionLevel(s string) string {
+	if s == "" {
+		return ProtectionNormal
+	}
+	if !strings.HasPrefix(s, "0x") {
+		return s
+	}
+	v, err := strconv.ParseUint(s[2:], 16, 32)
+	if err != nil {
+		return s
+	}
+	level := uint32(v)
+	var names []string
+	if base := int(level & 0xf); base < len(protectionBases) {
+		names = append(names, protectionBases[base])
+	} else {
+		names = append(names, "0x"+strconv.FormatUint(uint64(base), 16))
+	}
+	for _, flag := range protectionFlags {
+		if level&flag.mask != 0 {
+			names = append(names, flag.name)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// protectionBase returns the base protection level of a formatted level.
+func protectionBase(level string) string {
+	if i := strings.IndexByte(level, '|'); i >= 0 {
+		return level[:i]
+	}
+	return level
+}
+
+// protectionLevel returns the protection level of the permission name, as
+// declared by the APK or the framework, or "" if neither declares it.
+func (k *apk) protectionLevel(name string) string {
+	if def := k.permissionDefinition(name); def != nil {
+		return def.ProtectionLevel
+	}
+	return ""
+}
+
+// usesPermissionFlagNeverForLocation is the neverForLocation flag of
+// android:usesPermissionFlags.
+const usesPermissionFlagNeverForLocation = 0x10000
+
+// Classes of permissions, from the most to the least restricted.
+const (
+	PermissionPrivileged = "privileged" // only granted to privileged system apps
+	PermissionSignature  = "signature"  // only granted to apps signed with the same key
+	PermissionAppOp      = "appop"      // granted by the user in the special app access settings
+	PermissionDangerous  = "dangerous"  // granted by the user at runtime
+	PermissionNormal     = "normal"     // granted at install time
+	PermissionUnknown    = "unknown"    // declared by another app
+)
+
+// Permission is a permission an app requests.
+type Permission struct {
+	Name string `json:"name"`
+	// SDK23 is set for <uses-permission-sdk-23>, only requested on API 23+.
+	SDK23 bool `json:"sdk23,omitempty"`
+	// MaxSdkVersion is the last API level the permission is requested on.
+	MaxSdkVersion int `json:"maxSdkVersion,omitempty"`
+	// NeverForLocation is set when the app asserts it never derives
+	// physical location from the permission.
+	NeverForLocation bool `json:"neverForLocation,omitempty"`
+
+	ProtectionLevel string `json:"protectionLevel,omitempty"` // e.g. signature|privileged, "" if unknown
+	Group           string `json:"group,omitempty"`           // permission group
+	Class           string `json:"class"`                     // one of the Permission classes
+	Custom          bool   `json:"custom,omitempty"`          // declared by the app itself
+}
+
+// Sensitive returns whether the user has to grant the permission, at
+// runtime or in the special app access settings.
+func (p *Permission) Sensitive() bool {
+	return p.Class == PermissionDangerous || p.Class == PermissionAppOp
+}
+
+// PermissionDefinition is a permission an app declares.
+type PermissionDefinition struct {
+	Name            string `json:"name"`
+	Group           string `json:"group,omitempty"`
+	ProtectionLevel string `json:"protectionLevel"`
+}
+
+// classifyPermission returns the class of a permission with the given
+// protection level. Appop permissions are granted to any app through the
+// special app access settings, even if privileged apps get them too.
+func classifyPermission(level string) string {
+	if level == "" {
+		return PermissionUnknown
+	}
+	base := protectionBase(level)
+	flags := strings.Split(level, "|")[1:]
+	switch {
+	case containsString(flags, "appop"):
+		return PermissionAppOp
+	case base == ProtectionSignatureOrSystem || containsString(flags, "privileged"):
+		return PermissionPrivileged
+	case base == ProtectionSignature || base == ProtectionInternal:
+		return PermissionSignature
+	case base == ProtectionDangerous:
+		return PermissionDangerous
+	}
+	return PermissionNormal
+}
+
+// permissionDefinition returns the definition of the permission name, by
+// the APK or the framework, or nil if neither declares it.
+func (k *apk) permissionDefinition(name string) *PermissionDefinition {
+	for _, p := range k.apkManifest.DeclaredPermissions {
+		if p.Name == name {
+			return &PermissionDefinition{
+				Name:            p.Name,
+				Group:           p.PermissionGroup,
+				ProtectionLevel: formatProtectionLevel(p.ProtectionLevel),
+			}
+		}
+	}
+	if p, ok := frameworkPermissions[name]; ok {
+		return &PermissionDefinition{Name: name, Group: p.group, ProtectionLevel: p.level}
+	}
+	return nil
+}
+
+// permissions returns the permissions the APK requests, classified.
+func (k *apk) permissions() []Permission {
+	var permissions []Permission
+	add := func(uses []permission, sdk23 bool) {
+		for _, u := range uses {
+			p := Permission{
+				Name:          u.Name,
+				SDK23:         sdk23,
+				MaxSdkVersion: u.MaxSdkVersion,
+			}
+			if flags, err := strconv.ParseUint(strings.TrimPrefix(u.UsesPermissionFlags, "0x"), 16, 32); err == nil {
+				p.NeverForLocation = flags&usesPermissionFlagNeverForLocation != 0
+			} else {
+				p.NeverForLocation = strings.Contains(u.UsesPermissionFlags, "neverForLocation")
+			}
+			if def := k.permissionDefinition(u.Name); def != nil {
+				p.ProtectionLevel, p.Group = def.ProtectionLevel, def.Group
+			}
+			p.Class = classifyPermission(p.ProtectionLevel)
+			for _, d := range k.apkManifest.DeclaredPermissions {
+				p.Custom = p.Custom || d.Name == u.Name
+			}
+			permissions = append(permissions, p)
+		}
+	}
+	add(k.apkManifest.Permissions, false)
+	add(k.apkManifest.PermissionsSDK23, true)
+	return permissions
+}
+
+// customPermissions returns the permissions the APK declares.
+func (k *apk) customPermissions() []PermissionDefinition {
+	var definitions []PermissionDefinition
+	for _, p := range k.apkManifest.DeclaredPermissions {
+		definitions = append(definitions, *k.permissionDefinition(p.Name))
+	}
+	return definitions
+}
@@ -0,0 +1,196 @@
+package apkparser
+
+// frameworkPermission is the definition of a permission of the Android
+// framework.
+type frameworkPermission struct {
+	level string // protection level, e.g. signature|privileged
+	group string // permission group of dangerous permissions
+}
+
+// Permission groups of the dangerous permissions.
+const (
+	groupActivityRecognition = "android.permission-group.ACTIVITY_RECOGNITION"
+	groupCalendar            = "android.permission-group.CALENDAR"
+	groupCallLog             = "android.permission-group.CALL_LOG"
+	groupCamera              = "android.permission-group.CAMERA"
+	groupContacts            = "android.permission-group.CONTACTS"
+	groupLocation            = "android.permission-group.LOCATION"
+	groupMicrophone          = "android.permission-group.MICROPHONE"
+	groupNearbyDevices       = "android.permission-group.NEARBY_DEVICES"
+	groupNotifications       = "android.permission-group.NOTIFICATIONS"
+	groupPhone               = "android.permission-group.PHONE"
+	groupReadMediaAural      = "android.permission-group.READ_MEDIA_AURAL"
+	groupReadMediaVisual     = "android.permission-group.READ_MEDIA_VISUAL"
+	groupSensors             = "android.permission-group.SENSORS"
+	groupSMS                 = "android.permission-group.SMS"
+	groupStorage             = "android.permission-group.STORAGE"
+)
+
+// frameworkPermissions are the permissions of the Android framework apps
+// commonly request, from frameworks/base/core/res/AndroidManifest.xml.
+var frameworkPermissions = map[string]frameworkPermission{
+	// dangerous
+	"android.permission.ACCEPT_HANDOVER":                 {"dangerous", groupPhone},
+	"android.permission.ACCESS_BACKGROUND_LOCATION":      {"dangerous", groupLocation},
+	"android.permission.ACCESS_COARSE_LOCATION":          {"dangerous", groupLocation},
+	"android.permission.ACCESS_FINE_LOCATION":            {"dangerous", groupLocation},
+	"android.permission.ACCESS_MEDIA_LOCATION":           {"dangerous", ""},
+	"android.permission.ACTIVITY_RECOGNITION":            {"dangerous", groupActivityRecognition},
+	"android.permission.ANSWER_PHONE_CALLS":              {"dangerous", groupPhone},
+	"android.permission.BLUETOOTH_ADVERTISE":             {"dangerous", groupNearbyDevices},
+	"android.permission.BLUETOOTH_CONNECT":               {"dangerous", groupNearbyDevices},
+	"android.permission.BLUETOOTH_SCAN":                  {"dangerous", groupNearbyDevices},
+	"android.permission.BODY_SENSORS":                    {"dangerous", groupSensors},
+	"android.permission.BODY_SENSORS_BACKGROUND":         {"dangerous", groupSensors},
+	"android.permission.CALL_PHONE":                      {"dangerous", groupPhone},
+	"android.permission.CAMERA":                          {"dangerous", groupCamera},
+	"android.permission.GET_ACCOUNTS":                    {"dangerous", groupContacts},
+	"android.permission.NEARBY_WIFI_DEVICES":             {"dangerous", groupNearbyDevices},
+	"android.permission.POST_NOTIFICATIONS":              {"dangerous", groupNotifications},
+	"android.permission.PROCESS_OUTGOING_CALLS":          {"dangerous", groupCallLog},
+	"android.permission.READ_CALENDAR":                   {"dangerous", groupCalendar},
+	"android.permission.READ_CALL_LOG":                   {"dangerous", groupCallLog},
+	"android.permission.READ_CONTACTS":                   {"dangerous", groupContacts},
+	"android.permission.READ_EXTERNAL_STORAGE":           {"dangerous", groupStorage},
+	"android.permission.READ_MEDIA_AUDIO":                {"dangerous", groupReadMediaAural},
+	"android.permission.READ_MEDIA_IMAGES":               {"dangerous", groupReadMediaVisual},
+	"android.permission.READ_MEDIA_VIDEO":                {"dangerous", groupReadMediaVisual},
+	"android.permission.READ_MEDIA_VISUAL_USER_SELECTED": {"dangerous", groupReadMediaVisual},
+	"android.permission.READ_PHONE_NUMBERS":              {"dangerous", groupPhone},
+	"android.permission.READ_PHONE_STATE":                {"dangerous", groupPhone},
+	"android.permission.READ_SMS":                        {"dangerous", groupSMS},
+	"android.permission.RECEIVE_MMS":                     {"dangerous", groupSMS},
+	"android.permission.RECEIVE_SMS":                     {"dangerous", groupSMS},
+	"android.permission.RECEIVE_WAP_PUSH":                {"dangerous", groupSMS},
+	"android.permission.RECORD_AUDIO":                    {"dangerous", groupMicrophone},
+	"android.permission.SEND_SMS":                        {"dangerous", groupSMS},
+	"android.permission.USE_SIP":                         {"dangerous", groupPhone},
+	"android.permission.UWB_RANGING":                     {"dangerous", groupNearbyDevices},
+	"android.permission.WRITE_CALENDAR":                  {"dangerous", groupCalendar},
+	"android.permission.WRITE_CALL_LOG":                  {"dangerous", groupCallLog},
+	"android.permission.WRITE_CONTACTS":                  {"dangerous", groupContacts},
+	"android.permission.WRITE_EXTERNAL_STORAGE":          {"dangerous", groupStorage},
+	"com.android.voicemail.permission.ADD_VOICEMAIL":     {"dangerous", groupPhone},
+
+	// normal
+	"android.permission.ACCESS_LOCATION_EXTRA_COMMANDS":       {"normal", ""},
+	"android.permission.ACCESS_NETWORK_STATE":                 {"normal", ""},
+	"android.permission.ACCESS_NOTIFICATION_POLICY":           {"normal", ""},
+	"android.permission.ACCESS_WIFI_STATE":                    {"normal", ""},
+	"android.permission.BLUETOOTH":                            {"normal", ""},
+	"android.permission.BLUETOOTH_ADMIN":                      {"normal", ""},
+	"android.permission.BROADCAST_STICKY":                     {"normal", ""},
+	"android.permission.CHANGE_NETWORK_STATE":                 {"normal", ""},
+	"android.permission.CHANGE_WIFI_MULTICAST_STATE":          {"normal", ""},
+	"android.permission.CHANGE_WIFI_STATE":                    {"normal", ""},
+	"android.permission.DETECT_SCREEN_CAPTURE":                {"normal", ""},
+	"android.permission.DISABLE_KEYGUARD":                     {"normal", ""},
+	"android.permission.EXPAND_STATUS_BAR":                    {"normal", ""},
+	"android.permission.FOREGROUND_SERVICE":                   {"normal", ""},
+	"android.permission.FOREGROUND_SERVICE_CAMERA":            {"normal", ""},
+	"android.permission.FOREGROUND_SERVICE_CONNECTED_DEVICE":  {"normal", ""},
+	"android.permission.FOREGROUND_SERVICE_DATA_SYNC":         {"normal", ""},
+	"android.permission.FOREGROUND_SERVICE_HEALTH":            {"normal", ""},
+	"android.permission.FOREGROUND_SERVICE_LOCATION":          {"normal", ""},
+	"android.permission.FOREGROUND_SERVICE_MEDIA_PLAYBACK":    {"normal", ""},
+	"android.permission.FOREGROUND_SERVICE_MICROPHONE":        {"normal", ""},
+	"android.permission.FOREGROUND_SERVICE_PHONE_CALL":        {"normal", ""},
+	"android.permission.FOREGROUND_SERVICE_REMOTE_MESSAGING":  {"normal", ""},
+	"android.permission.FOREGROUND_SERVICE_SPECIAL_USE":       {"normal", ""},
+	"android.permission.GET_PACKAGE_SIZE":                     {"normal", ""},
+	"android.permission.GET_TASKS":                            {"normal", ""},
+	"android.permission.HIGH_SAMPLING_RATE_SENSORS":           {"normal", ""},
+	"android.permission.INTERNET":                             {"normal", ""},
+	"android.permission.KILL_BACKGROUND_PROCESSES":            {"normal", ""},
+	"android.permission.MANAGE_OWN_CALLS":                     {"normal", ""},
+	"android.permission.MODIFY_AUDIO_SETTINGS":                {"normal", ""},
+	"android.permission.NFC":                                  {"normal", ""},
+	"android.permission.NFC_PREFERRED_PAYMENT_INFO":           {"normal", ""},
+	"android.permission.NFC_TRANSACTION_EVENT":                {"normal", ""},
+	"android.permission.QUERY_ALL_PACKAGES":                   {"normal", ""},
+	"android.permission.READ_BASIC_PHONE_STATE":               {"normal", ""},
+	"android.permission.READ_SYNC_SETTINGS":                   {"normal", ""},
+	"android.permission.READ_SYNC_STATS":                      {"normal", ""},
+	"android.permission.RECEIVE_BOOT_COMPLETED":               {"normal", ""},
+	"android.permission.REORDER_TASKS":                        {"normal", ""},
+	"android.permission.REQUEST_COMPANION_RUN_IN_BACKGROUND":  {"normal", ""},
+	"android.permission.REQUEST_DELETE_PACKAGES":              {"normal", ""},
+	"android.permission.REQUEST_IGNORE_BATTERY_OPTIMIZATIONS": {"normal", ""},
+	"android.permission.RUN_USER_INITIATED_JOBS":              {"normal", ""},
+	"android.permission.SET_WALLPAPER":                        {"normal", ""},
+	"android.permission.SET_WALLPAPER_HINTS":                  {"normal", ""},
+	"android.permission.TRANSMIT_IR":                          {"normal", ""},
+	"android.permission.USE_BIOMETRIC":                        {"normal", ""},
+	"android.permission.USE_EXACT_ALARM":                      {"normal", ""},
+	"android.permission.USE_FINGERPRINT":                      {"normal", ""},
+	"android.permission.USE_FULL_SCREEN_INTENT":               {"normal|appop", ""},
+	"android.permission.VIBRATE":                              {"normal", ""},
+	"android.permission.WAKE_LOCK":                            {"normal", ""},
+	"android.permission.WRITE_SYNC_SETTINGS":                  {"normal", ""},
+	"com.android.alarm.permission.SET_ALARM":                  {"normal", ""},
+	"com.android.launcher.permission.INSTALL_SHORTCUT":        {"normal", ""},
+
+	// signature, privileged and appop
+	"android.permission.ACCESS_CHECKIN_PROPERTIES":          {"signature|privileged", ""},
+	"android.permission.BATTERY_STATS":                      {"signature|privileged|development", ""},
+	"android.permission.BIND_ACCESSIBILITY_SERVICE":         {"signature", ""},
+	"android.permission.BIND_APPWIDGET":                     {"signature|privileged", ""},
+	"android.permission.BIND_AUTOFILL_SERVICE":              {"signature", ""},
+	"android.permission.BIND_CARRIER_SERVICES":              {"signature|privileged", ""},
+	"android.permission.BIND_CHOOSER_TARGET_SERVICE":        {"signature", ""},
+	"android.permission.BIND_COMPANION_DEVICE_SERVICE":      {"signature", ""},
+	"android.permission.BIND_CONDITION_PROVIDER_SERVICE":    {"signature", ""},
+	"android.permission.BIND_DEVICE_ADMIN":                  {"signature", ""},
+	"android.permission.BIND_DREAM_SERVICE":                 {"signature", ""},
+	"android.permission.BIND_INCALL_SERVICE":                {"signature|privileged", ""},
+	"android.permission.BIND_INPUT_METHOD":                  {"signature", ""},
+	"android.permission.BIND_JOB_SERVICE":                   {"signature", ""},
+	"android.permission.BIND_MIDI_DEVICE_SERVICE":           {"signature", ""},
+	"android.permission.BIND_NFC_SERVICE":                   {"signature", ""},
+	"android.permission.BIND_NOTIFICATION_LISTENER_SERVICE": {"signature", ""},
+	"android.permission.BIND_PRINT_SERVICE":                 {"signature", ""},
+	"android.permission.BIND_QUICK_SETTINGS_TILE":           {"signature", ""},
+	"android.permission.BIND_REMOTEVIEWS":                   {"signature|privileged", ""},
+	"android.permission.BIND_SCREENING_SERVICE":             {"signature|privileged", ""},
+	"android.permission.BIND_TELECOM_CONNECTION_SERVICE":    {"signature|privileged", ""},
+	"android.permission.BIND_TEXT_SERVICE":                  {"signature", ""},
+	"android.permission.BIND_TV_INPUT":                      {"signature|privileged", ""},
+	"android.permission.BIND_VOICE_INTERACTION":             {"signature", ""},
+	"android.permission.BIND_VPN_SERVICE":                   {"signature", ""},
+	"android.permission.BIND_VR_LISTENER_SERVICE":           {"signature", ""},
+	"android.permission.BIND_WALLPAPER":                     {"signature|privileged", ""},
+	"android.permission.BLUETOOTH_PRIVILEGED":               {"signature|privileged", ""},
+	"android.permission.CALL_PRIVILEGED":                    {"signature|privileged", ""},
+	"android.permission.CAPTURE_AUDIO_OUTPUT":               {"signature|privileged", ""},
+	"android.permission.CHANGE_COMPONENT_ENABLED_STATE":     {"signature|privileged", ""},
+	"android.permission.CLEAR_APP_CACHE":                    {"signature|privileged", ""},
+	"android.permission.CONTROL_LOCATION_UPDATES":           {"signature|privileged", ""},
+	"android.permission.DELETE_PACKAGES":                    {"signature|privileged", ""},
+	"android.permission.DUMP":                               {"signature|privileged|development", ""},
+	"android.permission.FACTORY_TEST":                       {"signature", ""},
+	"android.permission.GLOBAL_SEARCH":                      {"signature|privileged", ""},
+	"android.permission.INSTALL_LOCATION_PROVIDER":          {"signature|privileged", ""},
+	"android.permission.INSTALL_PACKAGES":                   {"signature|privileged", ""},
+	"android.permission.LOCATION_HARDWARE":                  {"signature|privileged", ""},
+	"android.permission.MANAGE_DOCUMENTS":                   {"signature", ""},
+	"android.permission.MANAGE_EXTERNAL_STORAGE":            {"signature|appop|preinstalled", ""},
+	"android.permission.MASTER_CLEAR":                       {"signature|privileged", ""},
+	"android.permission.MEDIA_CONTENT_CONTROL":              {"signature|privileged", ""},
+	"android.permission.MODIFY_PHONE_STATE":                 {"signature|privileged", ""},
+	"android.permission.MOUNT_UNMOUNT_FILESYSTEMS":          {"signature|privileged", ""},
+	"android.permission.PACKAGE_USAGE_STATS":                {"signature|privileged|development|appop|retailDemo", ""},
+	"android.permission.READ_LOGS":                          {"signature|privileged|development", ""},
+	"android.permission.READ_PRIVILEGED_PHONE_STATE":        {"signature|privileged", ""},
+	"android.permission.REBOOT":                             {"signature|privileged", ""},
+	"android.permission.REQUEST_INSTALL_PACKAGES":           {"signature|appop", ""},
+	"android.permission.SCHEDULE_EXACT_ALARM":               {"signature|privileged|appop", ""},
+	"android.permission.SEND_RESPOND_VIA_MESSAGE":           {"signature|privileged", ""},
+	"android.permission.SET_TIME":                           {"signature|privileged", ""},
+	"android.permission.STATUS_BAR":                         {"signature|privileged", ""},
+	"android.permission.SYSTEM_ALERT_WINDOW":                {"signature|setup|appop|installer|pre23|development", ""},
+	"android.permission.UPDATE_DEVICE_STATS":                {"signature|privileged", ""},
+	"android.permission.WRITE_SECURE_SETTINGS":              {"signature|privileged|development", ""},
+	"android.permission.WRITE_SETTINGS":                     {"signature|preinstalled|appop|pre23", ""},
+	"com.android.voicemail.permission.READ_VOICEMAIL":       {"signature|privileged|role", ""},
+	"com.android.voicemail.permission.WRITE_VOICEMAIL":      {"signature|privileged|role", ""},
+}
@@ -0,0 +1,109 @@
+package apkparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFormatProtectionLevel(t *testing.T) {
+	for _, tt := range []struct {
+		value, level, class string
+	}{
+		{"", "normal", PermissionNormal},
+		{"0x00000001", "dangerous", PermissionDangerous},
+		{"0x00000012", "signature|privileged", PermissionPrivileged},
+		{"0x00000042", "signature|appop", PermissionAppOp},
+		{"0x00000052", "signature|privileged|appop", PermissionAppOp},
+		{"0x00000003", "signatureOrSystem", PermissionPrivileged},
+		{"0x08000002", "signature|knownSigner", PermissionSignature},
+		{"signature|development", "signature|development", PermissionSignature},
+	} {
+		level := formatProtectionLevel(tt.value)
+		if level != tt.level {
+			t.Errorf("formatProtectionLevel(%q) = %q, want %q", tt.value, level, tt.level)
+		}
+		if class := classifyPermission(level); class != tt.class {
+			t.Errorf("classifyPermission(%q) = %q, want %q", level, class, tt.class)
+		}
+	}
+}
+
+func TestPermissions(t *testing.T) {
+	uses := func(elem, name string, attrs ...xmlAttr) *xmlElement {
+		return &xmlElement{Name: elem, Attrs: append([]xmlAttr{{NS: androidNamespace, Name: "name", Value: name}}, attrs...)}
+	}
+	manifest := testManifest(
+		uses("uses-permission", "android.permission.INTERNET"),
+		uses("uses-permission", "android.permission.BLUETOOTH_SCAN",
+			xmlAttr{NS: androidNamespace, Name: "usesPermissionFlags", Type: TypeIntHex, Data: 0x10000}),
+		uses("uses-permission", "android.permission.WRITE_EXTERNAL_STORAGE",
+			xmlAttr{NS: androidNamespace, Name: "maxSdkVersion", Type: TypeIntDec, Data: 28}),
+		uses("uses-permission", "android.permission.SYSTEM_ALERT_WINDOW"),
+		uses("uses-permission", "android.permission.SCHEDULE_EXACT_ALARM"),
+		uses("uses-permission", "android.permission.PACKAGE_USAGE_STATS"),
+		uses("uses-permission", "com.example.app.SYNC"),
+		uses("uses-permission", "com.other.app.READ"),
+		uses("uses-permission-sdk-23", "android.permission.ACCESS_FINE_LOCATION"),
+		uses("permission", "com.example.app.SYNC",
+			xmlAttr{NS: androidNamespace, Name: "protectionLevel", Type: TypeIntHex, Data: 0x2},
+			xmlAttr{NS: androidNamespace, Name: "permissionGroup", Value: "com.example.app.GROUP"}),
+	)
+	name := writeAPK(t,
+		zipFile{"AndroidManifest.xml", encodeXML(manifest)},
+		zipFile{"resources.arsc", testTable()},
+	)
+	info, err := New(name, Option{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantNames := []string{
+		"android.permission.INTERNET",
+		"android.permission.BLUETOOTH_SCAN",
+		"android.permission.WRITE_EXTERNAL_STORAGE",
+		"android.permission.SYSTEM_ALERT_WINDOW",
+		"android.permission.SCHEDULE_EXACT_ALARM",
+		"android.permission.PACKAGE_USAGE_STATS",
+		"com.example.app.SYNC",
+		"com.other.app.READ",
+		"android.permission.ACCESS_FINE_LOCATION",
+	}
+	if !reflect.DeepEqual(info.Permissions, wantNames) {
+		t.Errorf("Permissions = %q, want %q", info.Permissions, wantNames)
+	}
+	want := []Permission{
+		{Name: "android.permission.INTERNET", ProtectionLevel: "normal", Class: PermissionNormal},
+		{Name: "android.permission.BLUETOOTH_SCAN", NeverForLocation: true, ProtectionLevel: "dangerous",
+			Group: "android.permission-group.NEARBY_DEVICES", Class: PermissionDangerous},
+		{Name: "android.permission.WRITE_EXTERNAL_STORAGE", MaxSdkVersion: 28, ProtectionLevel: "dangerous",
+			Group: "android.permission-group.STORAGE", Class: PermissionDangerous},
+		{Name: "android.permission.SYSTEM_ALERT_WINDOW", ProtectionLevel: "signature|setup|appop|installer|pre23|development",
+			Class: PermissionAppOp},
+		{Name: "android.permission.SCHEDULE_EXACT_ALARM", ProtectionLevel: "signature|privileged|appop",
+			Class: PermissionAppOp},
+		{Name: "android.permission.PACKAGE_USAGE_STATS", ProtectionLevel: "signature|privileged|development|appop|retailDemo",
+			Class: PermissionAppOp},
+		{Name: "com.example.app.SYNC", ProtectionLevel: "signature", Group: "com.example.app.GROUP",
+			Class: PermissionSignature, Custom: true},
+		{Name: "com.other.app.READ", Class: PermissionUnknown},
+		{Name: "android.permission.ACCESS_FINE_LOCATION", SDK23: true, ProtectionLevel: "dangerous",
+			Group: "android.permission-group.LOCATION", Class: PermissionDangerous},
+	}
+	if !reflect.DeepEqual(info.PermissionDetails, want) {
+		t.Errorf("PermissionDetails:\ngot  %+v\nwant %+v", info.PermissionDetails, want)
+	}
+	var sensitive int
+	for i := range info.PermissionDetails {
+		if info.PermissionDetails[i].Sensitive() {
+			sensitive++
+		}
+	}
+	if sensitive != 6 {
+		t.Errorf("%d sensitive permissions, want 6", sensitive)
+	}
+
+	wantCustom := []PermissionDefinition{{"com.example.app.SYNC", "com.example.app.GROUP", "signature"}}
+	if !reflect.DeepEqual(info.CustomPermissions, wantCustom) {
+		t.Errorf("CustomPermissions = %+v, want %+v", info.CustomPermissions, wantCustom)
+	}
+}
@@ -0,0 +1,476 @@
+package apkparser
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Rules of the protection findings.
+const (
+	RuleObfuscation      = "obfuscation"
+	RuleStringEncryption = "string-encryption"
+	RulePacker           = "packer"
+	RuleAntiTamper       = "anti-tamper"
+)
+
+// Thresholds of the protection heuristics.
+const (
+	obfuscatedClassRatio   = 0.25 // of the classes with 1 or 2 letter names
+	minObfuscatedClasses   = 5
+	encryptedStringRatio   = 0.05 // of the candidate strings
+	minEncryptedStrings    = 20
+	encryptedPayloadSize   = 1024 // bytes
+	encryptedPayloadSample = 64 << 10
+	encryptedPayloadBits   = 7.5 // bits of entropy per byte
+	maxEvidenceExamples    = 5
+)
+
+// Scores of the protection findings, out of 100.
+const (
+	scoreObfuscation      = 30
+	scoreStringEncryption = 20
+	scorePacker           = 50
+	scorePackerHeuristic  = 25
+	scoreAntiTamper       = 20
+)
+
+// ProtectionFinding is a protection found in an APK, with the evidence of it.
+type ProtectionFinding struct {
+	Rule     string   `json:"rule"`           // obfuscation, string-encryption, packer or anti-tamper
+	Name     string   `json:"name,omitempty"` // e.g. ProGuard/R8 or Jiagu (360), if known
+	Score    int      `json:"score"`          // contribution to the score of the report
+	Evidence []string `json:"evidence"`
+}
+
+// ProtectionReport describes how an APK is protected against reverse
+// engineering: obfuscated, packed or guarded against tampering.
+type ProtectionReport struct {
+	Score            int                 `json:"score"` // 0 to 100, the higher the more protected
+	Obfuscated       bool                `json:"obfuscated"`
+	StringEncryption bool                `json:"stringEncryption"`
+	Packed           bool                `json:"packed"`
+	Packers          []string            `json:"packers,omitempty"` // known packers
+	AntiTamper       bool                `json:"antiTamper"`
+	Findings         []ProtectionFinding `json:"findings,omitempty"`
+}
+
+// packerSignature identifies a commercial packer by the files and the stub
+// Application class it adds.
+type packerSignature struct {
+	name         string
+	files        []string // patterns of path.Match
+	applications []string
+}
+
+var packerSignatures = []packerSignature{
+	{"Jiagu (360)", []string{"lib/*/libjiagu*.so", "assets/libjiagu*.so", "lib/*/libprotectClass.so", "assets/libprotectClass.so"}, []string{"com.stub.StubApp"}},
+	{"Bangcle", []string{"lib/*/libsecexe.so", "lib/*/libsecmain.so", "lib/*/libSecShell.so", "assets/bangcle_classes.jar", "assets/secData0.jar"}, []string{"com.secneo.apkwrapper.ApplicationWrapper", "com.secneo.apkwrapper.AW"}},
+	{"Legu (Tencent)", []string{"lib/*/libshella-*.so", "lib/*/libshellx-*.so", "lib/*/liblegudb.so"}, []string{"com.tencent.StubShell.TxAppEntry"}},
+	{"Ijiami", []string{"lib/*/libexec.so", "lib/*/libexecmain.so", "assets/ijiami.dat", "assets/ijm_lib/*"}, []string{"com.shell.SuperApplication"}},
+	{"Baidu", []string{"lib/*/libbaiduprotect.so", "assets/baiduprotect*.jar"}, []string{"com.baidu.protect.StubApplication"}},
+	{"Alibaba", []string{"lib/*/libmobisec.so"}, []string{"com.ali.mobisecenginesdk.MobiSecApplication"}},
+	{"NetEase Yidun", []string{"lib/*/libnesec.so"}, []string{"com.netease.nis.wrapper.MyApplication"}},
+	{"Kiwisec", []string{"lib/*/libkwscmm.so", "lib/*/libkwscr.so", "lib/*/libkwslinker.so"}, nil},
+	{"Dingxiang", []string{"lib/*/libx3g.so"}, nil},
+	{"Naga", []string{"lib/*/libddog.so", "lib/*/libfdog.so", "lib/*/libchaosvmp.so"}, nil},
+	{"APKProtect", []string{"lib/*/libAPKProtect.so"}, nil},
+	{"DexProtector", []string{"lib/*/libdexprotector*.so", "assets/dp.arm*.so.dat", "assets/classes.dex.dat"}, nil},
+}
+
+// antiTamperSignature identifies an anti-tamper or integrity checking
+// library by the prefixes of its classes or its native libraries.
+type antiTamperSignature struct {
+	name     string
+	prefixes []string // Java packages or classes
+	files    []string // patterns of path.Match
+}
+
+var antiTamperSignatures = []antiTamperSignature{
+	{"DexGuard", []string{"com.guardsquare.dexguard"}, []string{"lib/*/libdexguard*.so"}},
+	{"Promon SHIELD", []string{"no.promon.shield"}, []string{"lib/*/libshield.so"}},
+	{"Appdome", []string{"com.appdome"}, nil},
+	{"Stringer", []string{"com.licel.stringer"}, nil},
+	{"Verimatrix", []string{"com.verimatrix", "com.insidesecure"}, nil},
+	{"Zimperium", []string{"com.zimperium"}, nil},
+	{"Play Integrity", []string{"com.google.android.play.core.integrity"}, nil},
+	{"SafetyNet Attestation", []string{"com.google.android.gms.safetynet"}, nil},
+	{"RootBeer", []string{"com.scottyab.rootbeer"}, nil},
+}
+
+// protection analyzes how the APK is protected against reverse engineering.
+// The known packers are reported even if the DEX files are invalid.
+func (k *apk) protection() (*ProtectionReport, error) {
+	report := &ProtectionReport{}
+	var names []string
+	for _, f := range k.archive.files {
+		names = append(names, f.Name)
+	}
+	application := k.qualifiedClassName(k.apkManifest.App.Name)
+
+	// known packers, by their files and stub Application classes.
+	for _, sig := range packerSignatures {
+		var evidence []string
+		for _, name := range names {
+			for _, pattern := range sig.files {
+				if ok, _ := path.Match(pattern, name); ok {
+					evidence = append(evidence, "packer file "+name)
+					break
+				}
+			}
+		}
+		if application != "" && containsString(sig.applications, application) {
+			evidence = append(evidence, "stub Application class "+application)
+		}
+		if len(evidence) > 0 {
+			report.Packers = append(report.Packers, sig.name)
+			report.add(ProtectionFinding{Rule: RulePacker, Name: sig.name, Score: scorePacker, Evidence: evidence})
+		}
+	}
+
+	files, dexNames, err := k.dexFiles()
+	if err != nil {
+		return report, err
+	}
+	dex := newDexStats()
+	it := newDexClassIterator(files, dexNames)
+	for it.Next() {
+		dex.addClass(it.Class())
+	}
+	if err := it.Err(); err != nil {
+		return report, err
+	}
+	for _, f := range files {
+		if err := dex.addStrings(f); err != nil {
+			return report, err
+		}
+	}
+
+	// unknown packers, which load the code of the app from encrypted payloads.
+	if len(report.Packers) == 0 {
+		var evidence []string
+		if application != "" && len(dex.classes) > 0 && !dex.classes[application] {
+			evidence = append(evidence, fmt.Sprintf("Application class %s isn't in the DEX files", application))
+		}
+		for _, f := range k.archive.files {
+			if !isPayloadName(f.Name) || f.UncompressedSize64 < encryptedPayloadSize {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				continue
+			}
+			sample, _ := io.ReadAll(io.LimitReader(rc, encryptedPayloadSample))
+			_ = rc.Close()
+			if bits := byteEntropy(sample); bits >= encryptedPayloadBits && !hasKnownMagic(sample) {
+				evidence = append(evidence, fmt.Sprintf("encrypted payload %s (%.2f bits of entropy per byte)", f.Name, bits))
+			}
+		}
+		if len(evidence) > 0 {
+			score := scorePackerHeuristic * len(evidence)
+			if score > scorePacker {
+				score = scorePacker
+			}
+			report.add(ProtectionFinding{Rule: RulePacker, Score: score, Evidence: evidence})
+		}
+	}
+
+	if finding, ok := dex.obfuscation(); ok {
+		report.Obfuscated = true
+		report.add(finding)
+	}
+	if finding, ok := dex.stringEncryption(); ok {
+		report.StringEncryption = true
+		report.add(finding)
+	}
+
+	for _, sig := range antiTamperSignatures {
+		var evidence []string
+		for _, prefix := range sig.prefixes {
+			if n := dex.packages[prefix]; n > 0 {
+				evidence = append(evidence, fmt.Sprintf("package %s (%d classes)", prefix, n))
+			}
+		}
+		for _, name := range names {
+			for _, pattern := range sig.files {
+				if ok, _ := path.Match(pattern, name); ok {
+					evidence = append(evidence, "native library "+name)
+					break
+				}
+			}
+		}
+		if len(evidence) > 0 {
+			report.AntiTamper = true
+			report.add(ProtectionFinding{Rule: RuleAntiTamper, Name: sig.name, Score: scoreAntiTamper, Evidence: evidence})
+		}
+	}
+
+	if report.Score > 100 {
+		report.Score = 100
+	}
+	return report, nil
+}
+
+// add adds a finding to the report.
+func (r *ProtectionReport) add(finding ProtectionFinding) {
+	if finding.Rule == RulePacker {
+		r.Packed = true
+	}
+	r.Score += finding.Score
+	r.Findings = append(r.Findings, finding)
+}
+
+// qualifiedClassName returns the fully qualified name of the class name of
+// the manifest, which may be relative to the package, e.g. .MainActivity.
+func (k *apk) qualifiedClassName(name string) string {
+	if strings.HasPrefix(name, ".") {
+		return k.apkManifest.Package + name
+	}
+	if name != "" && !strings.Contains(name, ".") {
+		return k.apkManifest.Package + "." + name
+	}
+	return name
+}
+
+// dexStats are the statistics of the DEX files the heuristics are based on.
+type dexStats struct {
+	classes  map[string]bool // defined classes
+	packages map[string]int  // number of classes in each package and its parents
+
+	shortClasses  []string // classes with 1 or 2 letter names
+	methods       int
+	shortMethods  int
+	r8SourceFiles int      // classes whose source file is SourceFile or an R8 map id
+	decryptors    []string // static methods which may decrypt strings
+
+	candidates int      // strings which aren't descriptors or names
+	encrypted  []string // candidates which look encrypted
+}
+
+func newDexStats() *dexStats {
+	return &dexStats{classes: make(map[string]bool), packages: make(map[string]int)}
+}
+
+func (s *dexStats) addClass(c *DexClass) {
+	if s.classes[c.Name] {
+		return
+	}
+	s.classes[c.Name] = true
+	for name := c.Name; ; {
+		dot := strings.LastIndexByte(name, '.')
+		if dot < 0 {
+			break
+		}
+		name = name[:dot]
+		s.packages[name]++
+	}
+	s.packages[c.Name]++
+
+	short := isShortName(simpleClassName(c.Name))
+	if short {
+		s.shortClasses = append(s.shortClasses, c.Name)
+	}
+	if c.SourceFile == "SourceFile" || strings.HasPrefix(c.SourceFile, "r8-map-id-") {
+		s.r8SourceFiles++
+	}
+	for _, m := range c.Methods {
+		if strings.HasPrefix(m.Name, "<") {
+			continue
+		}
+		s.methods++
+		if isShortName(m.Name) {
+			s.shortMethods++
+		}
+		// e.g. static String a(String) of an obfuscated class.
+		if short && isShortName(m.Name) && m.AccessFlags&AccStatic != 0 && m.Proto.ReturnType == "Ljava/lang/String;" &&
+			len(m.Proto.Parameters) > 0 && (m.Proto.Parameters[0] == "Ljava/lang/String;" || m.Proto.Parameters[0] == "[B") {
+			s.decryptors = append(s.decryptors, c.Name+"."+m.Name+m.Proto.Descriptor())
+		}
+	}
+}
+
+// addStrings adds the candidate strings of the string table of f, which
+// aren't descriptors or names.
+func (s *dexStats) addStrings(f *DexFile) error {
+	for i := uint32(0); i < f.Header.StringIdsSize; i++ {
+		str, err := f.GetString(i)
+		if err != nil {
+			return err
+		}
+		if len(str) < 16 || strings.ContainsAny(str, " ;.") {
+			continue
+		}
+		s.candidates++
+		if looksEncrypted(str) {
+			s.encrypted = append(s.encrypted, str)
+		}
+	}
+	return nil
+}
+
+func (s *dexStats) obfuscation() (ProtectionFinding, bool) {
+	finding := ProtectionFinding{Rule: RuleObfuscation, Name: "ProGuard/R8"}
+	n := len(s.shortClasses)
+	if n < minObfuscatedClasses || float64(n) < obfuscatedClassRatio*float64(len(s.classes)) {
+		return finding, false
+	}
+	examples := append([]string(nil), s.shortClasses...)
+	sort.Strings(examples)
+	if len(examples) > maxEvidenceExamples {
+		examples = examples[:maxEvidenceExamples]
+	}
+	finding.Evidence = append(finding.Evidence,
+		fmt.Sprintf("%d of %d classes (%.0f%%) have 1 or 2 letter names, e.g. %s", n, len(s.classes),
+			100*float64(n)/float64(len(s.classes)), strings.Join(examples, ", ")))
+	if s.methods > 0 {
+		finding.Evidence = append(finding.Evidence,
+			fmt.Sprintf("%d of %d methods have 1 or 2 letter names", s.shortMethods, s.methods))
+	}
+	if s.r8SourceFiles > 0 {
+		finding.Evidence = append(finding.Evidence,
+			fmt.Sprintf("%d classes have their source file renamed by R8", s.r8SourceFiles))
+	}
+	// scaled by the share of obfuscated classes.
+	finding.Score = int(math.Round(scoreObfuscation * math.Min(1, float64(n)/float64(len(s.classes))*2)))
+	return finding, true
+}
+
+func (s *dexStats) stringEncryption() (ProtectionFinding, bool) {
+	finding := ProtectionFinding{Rule: RuleStringEncryption, Score: scoreStringEncryption}
+	n := len(s.encrypted)
+	if n < minEncryptedStrings || float64(n) < encryptedStringRatio*float64(s.candidates) {
+		return finding, false
+	}
+	examples := s.encrypted
+	if len(examples) > maxEvidenceExamples {
+		examples = examples[:maxEvidenceExamples]
+	}
+	finding.Evidence = append(finding.Evidence,
+		fmt.Sprintf("%d of %d strings look encrypted, e.g. %q", n, s.candidates, examples))
+	if len(s.decryptors) > 0 {
+		examples := s.decryptors
+		if len(examples) > maxEvidenceExamples {
+			examples = examples[:maxEvidenceExamples]
+		}
+		finding.Evidence = append(finding.Evidence,
+			fmt.Sprintf("%d possible decryption methods, e.g. %s", len(s.decryptors), strings.Join(examples, ", ")))
+	}
+	return finding, true
+}
+
+// simpleClassName returns the name of a class without its package and outer
+// classes, e.g. Bar for com.example.Foo$Bar.
+func simpleClassName(name string) string {
+	if i := strings.LastIndexAny(name, ".$"); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
+// isShortName returns whether name looks like it was renamed by ProGuard or
+// R8: 1 or 2 lowercase letters.
+func isShortName(name string) bool {
+	if name == "" || len(name) > 2 {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if name[i] < 'a' || name[i] > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+// looksEncrypted returns whether the string looks like ciphertext: control
+// or private use characters, or random looking base64 or hex.
+func looksEncrypted(s string) bool {
+	var upper, lower, digits, other int
+	for _, r := range s {
+		switch {
+		case r < 0x20 || r >= 0xe000 && r <= 0xf8ff || r == 0xfffd:
+			return true
+		case r >= 'A' && r <= 'Z':
+			upper++
+		case r >= 'a' && r <= 'z':
+			lower++
+		case r >= '0' && r <= '9':
+			digits++
+		case r == '+' || r == '/' || r == '=' || r == '-' || r == '_':
+		default:
+			other++
+		}
+	}
+	if other > 0 {
+		return false
+	}
+	isHex := upper+lower+digits == len(s) && strings.Trim(strings.ToLower(s), "0123456789abcdef") == ""
+	if isHex {
+		return len(s) >= 32
+	}
+	// identifiers mix cases too, but have long runs of lowercase letters
+	// and little entropy.
+	return upper > 0 && lower > 0 && digits > 0 && stringEntropy(s) >= 0.85*math.Log2(math.Min(float64(len(s)), 64))
+}
+
+// stringEntropy returns the Shannon entropy of s, in bits per byte.
+func stringEntropy(s string) float64 {
+	return byteEntropy([]byte(s))
+}
+
+// byteEntropy returns the Shannon entropy of data, in bits per byte.
+func byteEntropy(data []byte) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+	var counts [256]int
+	for _, b := range data {
+		counts[b]++
+	}
+	var entropy float64
+	for _, n := range counts {
+		if n > 0 {
+			p := float64(n) / float64(len(data))
+			entropy -= p * math.Log2(p)
+		}
+	}
+	return entropy
+}
+
+// isPayloadName returns whether the file could be a payload a packer
+// decrypts at runtime.
+func isPayloadName(name string) bool {
+	if !strings.HasPrefix(name, "assets/") {
+		return false
+	}
+	switch path.Ext(name) {
+	case ".dat", ".bin", ".jar", ".dex":
+		return true
+	}
+	return false
+}
+
+// hasKnownMagic returns whether data starts like a file of a compressed
+// format, whose entropy is high without encryption.
+func hasKnownMagic(data []byte) bool {
+	for _, magic := range [][]byte{
+		[]byte("PK\x03\x04"), // zip, jar
+		[]byte("\x1f\x8b"),   // gzip
+		[]byte("7z\xbc\xaf"), // 7z
+		[]byte("\xff\xd8\xff"),
+		[]byte("\x89PNG"),
+		[]byte("RIFF"),
+		[]byte("OggS"),
+		[]byte("ID3"),
+		[]byte("\x28\xb5\x2f\xfd"), // zstd
+		dexMagic,
+	} {
+		if bytes.HasPrefix(data, magic) {
+			return true
+		}
+	}
+	return false
+}
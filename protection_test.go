@@ -0,0 +1,132 @@
+package apkparser
+
+import (
+	"encoding/base64"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestProtection(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	random := func(n int) []byte {
+		b := make([]byte, n)
+		rnd.Read(b)
+		return b
+	}
+	application := func(name string) *xmlElement {
+		return &xmlElement{Name: "application", Attrs: []xmlAttr{{NS: androidNamespace, Name: "name", Value: name}}}
+	}
+
+	// R8 renamed classes, with encrypted strings and their decryption method.
+	var ciphertexts []string
+	for i := 0; i < 30; i++ {
+		ciphertexts = append(ciphertexts, base64.StdEncoding.EncodeToString(random(24)))
+	}
+	obfuscated := []testDexClass{
+		{Descriptor: "Lcom/example/app/App;", Superclass: "Landroid/app/Application;", SourceFile: "SourceFile"},
+		{Descriptor: "La/a;", Superclass: "Ljava/lang/Object;", SourceFile: "SourceFile", Methods: []testDexMember{
+			{"a", "(Ljava/lang/String;)Ljava/lang/String;", AccPublic | AccStatic},
+			{"b", "()V", AccPublic},
+		}},
+		{Descriptor: "Lcom/scottyab/rootbeer/RootBeer;", Superclass: "Ljava/lang/Object;"},
+	}
+	for _, name := range []string{"b", "c", "d", "e", "f", "aa"} {
+		obfuscated = append(obfuscated, testDexClass{Descriptor: "La/" + name + ";", Superclass: "Ljava/lang/Object;"})
+	}
+	readable := []testDexClass{
+		{Descriptor: "Lcom/example/app/App;", Superclass: "Landroid/app/Application;", SourceFile: "App.java"},
+		{Descriptor: "Lcom/example/app/MainActivity;", Superclass: "Landroid/app/Activity;", SourceFile: "MainActivity.java", Methods: []testDexMember{
+			{"onCreate", "(Landroid/os/Bundle;)V", AccProtected},
+		}},
+	}
+	identifiers := []string{"onCreateOptionsMenu", "getSupportFragmentManager", "AppCompatActivity2", "0123456789abcdef0123", "HTTP_CLIENT_TIMEOUT_V2"}
+
+	for _, tt := range []struct {
+		name     string
+		files    []zipFile
+		app      string
+		want     []string // rules and names of the findings
+		packers  []string
+		minScore int
+	}{
+		{
+			name:  "readable",
+			files: []zipFile{{"classes.dex", encodeDexClasses(identifiers, readable...)}},
+			app:   ".App",
+		},
+		{
+			name: "obfuscated and packed",
+			files: []zipFile{
+				{"classes.dex", encodeDexClasses(ciphertexts, obfuscated...)},
+				{"lib/arm64-v8a/libjiagu_a64.so", []byte{0x7f, 'E', 'L', 'F'}},
+			},
+			app:      "com.stub.StubApp",
+			want:     []string{"packer Jiagu (360)", "obfuscation ProGuard/R8", "string-encryption ", "anti-tamper RootBeer"},
+			packers:  []string{"Jiagu (360)"},
+			minScore: 100,
+		},
+		{
+			name: "unknown packer",
+			files: []zipFile{
+				{"classes.dex", encodeDexClasses(nil, testDexClass{Descriptor: "Lp/Stub;", Superclass: "Landroid/app/Application;"})},
+				{"assets/payload.dat", random(8 << 10)},
+				{"assets/data.bin", []byte("PK\x03\x04" + string(random(8<<10)))},
+			},
+			app:      "com.example.app.App",
+			want:     []string{"packer "},
+			minScore: 50,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			files := append([]zipFile{
+				{"AndroidManifest.xml", encodeXML(testManifest(application(tt.app)))},
+				{"resources.arsc", testTable()},
+			}, tt.files...)
+			info, err := New(writeAPK(t, files...), Option{WithDex: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			report := info.Protection
+			if report == nil {
+				t.Fatal("no protection report")
+			}
+			var got []string
+			for _, f := range report.Findings {
+				got = append(got, f.Rule+" "+f.Name)
+				if len(f.Evidence) == 0 {
+					t.Errorf("%s finding without evidence", f.Rule)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) || !reflect.DeepEqual(report.Packers, tt.packers) {
+				t.Errorf("findings = %q, packers = %q; want %q, %q", got, report.Packers, tt.want, tt.packers)
+			}
+			if report.Score < tt.minScore || report.Score > 100 || tt.want == nil && report.Score != 0 {
+				t.Errorf("score = %d, want at least %d", report.Score, tt.minScore)
+			}
+			if report.Packed != (tt.minScore >= 50) {
+				t.Errorf("packed = %v", report.Packed)
+			}
+		})
+	}
+}
+
+func TestLooksEncrypted(t *testing.T) {
+	for _, tt := range []struct {
+		s    string
+		want bool
+	}{
+		{"onCreateOptionsMenu", false},
+		{"getSupportFragmentManager", false},
+		{"HTTP_CLIENT_TIMEOUT_V2", false},
+		{"AppCompatActivity2", false},
+		{"0123456789abcdef0123", false},
+		{"9f86d081884c7d659a2feaa0c55ad015", true},
+		{"q8Zr2LmX0vTb7NcKy4Hs1WdP", true},
+		{"\u0003éabc", true},
+	} {
+		if got := looksEncrypted(tt.s); got != tt.want {
+			t.Errorf("looksEncrypted(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,347 @@
+package apkparser
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// Resource is an entry of a resource table, with its value under every
+// configuration that defines it.
+type Resource struct {
+	ID      ResID           `json:"id"`
+	Package string          `json:"package"`
+	Type    string          `json:"type"`
+	Name    string          `json:"name"`
+	Values  []ResourceValue `json:"values"`
+}
+
+// ResourceValue is the value of a resource under one configuration.
+// Simple resources have a Value, complex resources, e.g. styles and arrays,
+// have Items instead.
+type ResourceValue struct {
+	Config     ResTableConfig `json:"-"`
+	Qualifiers string         `json:"qualifiers"`
+	Value      string         `json:"value,omitempty"`
+	Parent     string         `json:"parent,omitempty"`
+	Items      []ResourceItem `json:"items,omitempty"`
+}
+
+// ResourceItem is a name/value pair of a complex resource.
+type ResourceItem struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Name returns the name of the package, e.g. com.example.app.
+func (p *TablePackage) Name() string {
+	name := p.Header.Name[:]
+	for i, c := range name {
+		if c == 0 {
+			name = name[:i]
+			break
+		}
+	}
+	return string(utf16.Decode(name))
+}
+
+// typeName returns the name of the type id, e.g. "string".
+func (p *TablePackage) typeName(id uint8) string {
+	if id == 0 || p.TypeStrings == nil || int(id) > len(p.TypeStrings.Strings) {
+		return fmt.Sprintf("type%02x", id)
+	}
+	return p.TypeStrings.GetString(ResStringPoolRef(id - 1))
+}
+
+// keyName returns the name of the entry key ref, e.g. "app_name".
+func (p *TablePackage) keyName(ref ResStringPoolRef) string {
+	if p.KeyStrings == nil || int(ref) >= len(p.KeyStrings.Strings) {
+		return fmt.Sprintf("entry%04x", uint32(ref))
+	}
+	return p.KeyStrings.GetString(ref)
+}
+
+// Packages returns the packages of the table, ordered by ID.
+func (f *TableFile) Packages() []*TablePackage {
+	packages := make([]*TablePackage, 0, len(f.tablePackages))
+	for _, p := range f.tablePackages {
+		packages = append(packages, p)
+	}
+	sort.Slice(packages, func(i, j int) bool {
+		return packages[i].Header.ID < packages[j].Header.ID
+	})
+	return packages
+}
+
+// Resources returns every resource of the table, ordered by ID.
+func (f *TableFile) Resources() []Resource {
+	var resources []Resource
+	for _, p := range f.Packages() {
+		byID := make(map[ResID]int)
+		for _, t := range p.TableTypes {
+			typeName := p.typeName(t.Header.ID)
+			for i, e := range t.Entries {
+				if e.Key == nil {
+					continue
+				}
+				id := ResID(p.Header.ID<<24 | uint32(t.Header.ID)<<16 | uint32(i))
+				n, ok := byID[id]
+				if !ok {
+					n = len(resources)
+					byID[id] = n
+					resources = append(resources, Resource{
+						ID:      id,
+						Package: p.Name(),
+						Type:    typeName,
+						Name:    p.keyName(e.Key.Key),
+					})
+				}
+				resources[n].Values = append(resources[n].Values, f.resourceValue(&t.Header.Config, e))
+			}
+		}
+	}
+	sort.SliceStable(resources, func(i, j int) bool {
+		return resources[i].ID < resources[j].ID
+	})
+	return resources
+}
+
+func (f *TableFile) resourceValue(config *ResTableConfig, e TableEntry) ResourceValue {
+	v := ResourceValue{
+		Config:     *config,
+		Qualifiers: config.Qualifiers(),
+	}
+	if e.Value != nil {
+		v.Value = f.formatValue(e.Value)
+		return v
+	}
+	if e.Parent != 0 {
+		v.Parent = f.referenceName("@", e.Parent)
+	}
+	for _, m := range e.Map {
+		v.Items = append(v.Items, ResourceItem{
+			Name:  f.referenceName("", m.Name),
+			Value: f.formatValue(&m.Value),
+		})
+	}
+	return v
+}
+
+// formatValue formats v the way aapt2 dumps values, naming the resources
+// it references.
+func (f *TableFile) formatValue(v *ResValue) string {
+	switch v.DataType {
+	case TypeNull:
+		if v.Data == 0 {
+			return "@null"
+		}
+	case TypeReference:
+		return f.referenceName("@", ResID(v.Data))
+	case TypeAttribute:
+		return f.referenceName("?", ResID(v.Data))
+	case TypeString:
+		if s, err := f.getString(ResStringPoolRef(v.Data)); err == nil {
+			return s
+		}
+	}
+	return formatResValue(v)
+}
+
+// formatResValue formats v the way aapt2 dumps values. References are
+// written as IDs, and strings, which live in a string pool, as indexes.
+func formatResValue(v *ResValue) string {
+	switch v.DataType {
+	case TypeNull:
+		if v.Data == 1 {
+			return "@empty"
+		}
+		return ""
+	case TypeReference:
+		return ResID(v.Data).String()
+	case TypeAttribute:
+		return "?" + ResID(v.Data).String()[1:]
+	case TypeString:
+		return fmt.Sprintf("(string) %d", v.Data)
+	case TypeFloat:
+		return strconv.FormatFloat(float64(math.Float32frombits(v.Data)), 'g', -1, 32)
+	case TypeDemention:
+		units := []string{"px", "dp", "sp", "pt", "in", "mm"}
+		unit := ""
+		if u := int(v.Data & 0xf); u < len(units) {
+			unit = units[u]
+		}
+		return strconv.FormatFloat(complexToFloat(v.Data), 'g', -1, 32) + unit
+	case TypeFraction:
+		unit := "%"
+		if v.Data&0xf == 1 {
+			unit = "%p"
+		}
+		return strconv.FormatFloat(complexToFloat(v.Data)*100, 'g', -1, 32) + unit
+	case TypeIntDec:
+		return strconv.Itoa(int(int32(v.Data)))
+	case TypeIntHex:
+		return fmt.Sprintf("0x%08x", v.Data)
+	case TypeIntBoolean:
+		return strconv.FormatBool(v.Data != 0)
+	case TypeIntColorRGB8, TypeIntColorRGB4:
+		return fmt.Sprintf("#%06x", v.Data&0xffffff)
+	case TypeIntColorARGB8, TypeIntColorARGB4:
+		return fmt.Sprintf("#%08x", v.Data)
+	}
+	return fmt.Sprintf("(type 0x%02x) 0x%08x", uint8(v.DataType), v.Data)
+}
+
+// complexToFloat converts a complex data value, used by dimensions and
+// fractions, to a float.
+func complexToFloat(data uint32) float64 {
+	radix := []float64{1 << 8, 1 << 15, 1 << 23, 1 << 31}
+	return float64(int32(data&0xffffff00)) / radix[(data>>4)&0x3]
+}
+
+// densities are the names of the well known densities.
+var densities = map[uint16]string{
+	120:    "ldpi",
+	160:    "mdpi",
+	213:    "tvdpi",
+	240:    "hdpi",
+	320:    "xhdpi",
+	480:    "xxhdpi",
+	640:    "xxxhdpi",
+	0xfffe: "anydpi",
+	0xffff: "nodpi",
+}
+
+// Qualifiers returns the configuration qualifiers of c the way they appear
+// in resource directory names, e.g. "fr-rCA-land-xhdpi-v21". The default
+// configuration has no qualifiers.
+func (c *ResTableConfig) Qualifiers() string {
+	var q []string
+	add := func(values []string, i int) {
+		if i > 0 && i < len(values) && values[i] != "" {
+			q = append(q, values[i])
+		}
+	}
+
+	if c.Mcc != 0 {
+		q = append(q, fmt.Sprintf("mcc%d", c.Mcc))
+		if c.Mnc != 0 {
+			q = append(q, fmt.Sprintf("mnc%d", c.Mnc))
+		}
+	}
+	if c.Language[0] != 0 {
+		q = append(q, string(c.Language[:]))
+		if c.Country[0] != 0 {
+			q = append(q, "r"+string(c.Country[:]))
+		}
+	}
+	add([]string{"", "ldltr", "ldrtl"}, int(c.ScreenLayout&MaskLayoutDir)>>ShiftLayoutDir)
+	if c.SmallestScreenWidthDp != 0 {
+		q = append(q, fmt.Sprintf("sw%ddp", c.SmallestScreenWidthDp))
+	}
+	if c.ScreenWidthDp != 0 {
+		q = append(q, fmt.Sprintf("w%ddp", c.ScreenWidthDp))
+	}
+	if c.ScreenHeightDp != 0 {
+		q = append(q, fmt.Sprintf("h%ddp", c.ScreenHeightDp))
+	}
+	add([]string{"", "small", "normal", "large", "xlarge"}, int(c.ScreenLayout&MaskScreenSize))
+	add([]string{"", "notlong", "long"}, int(c.ScreenLayout&MaskScreenLong)>>ShiftScreenLong)
+	add([]string{"", "port", "land", "square"}, int(c.Orientation))
+	add([]string{"", "", "desk", "car", "television", "appliance", "watch", "vrheadset"}, int(c.UIMode&MaskUIModeType))
+	add([]string{"", "notnight", "night"}, int(c.UIMode&MaskUIModeNight)>>ShiftUIModeNight)
+	if c.Density != 0 {
+		if name, ok := densities[c.Density]; ok {
+			q = append(q, name)
+		} else {
+			q = append(q, fmt.Sprintf("%ddpi", c.Density))
+		}
+	}
+	add([]string{"", "notouch", "stylus", "finger"}, int(c.Touchscreen))
+	add([]string{"", "keysexposed", "keyshidden", "keyssoft"}, int(c.InputFlags&MaskKeysHidden))
+	add([]string{"", "nokeys", "qwerty", "12key"}, int(c.Keyboard))
+	add([]string{"", "navexposed", "navhidden"}, int(c.InputFlags&MaskNavHidden)>>2)
+	add([]string{"", "nonav", "dpad", "trackball", "wheel"}, int(c.Navigation))
+	if c.ScreenWidth != 0 || c.ScreenHeight != 0 {
+		q = append(q, fmt.Sprintf("%dx%d", c.ScreenWidth, c.ScreenHeight))
+	}
+	if c.SDKVersion != 0 {
+		q = append(q, fmt.Sprintf("v%d", c.SDKVersion))
+	}
+	return strings.Join(q, "-")
+}
+
+// buildIndex indexes the names of the resources of the table.
+func (f *TableFile) buildIndex() {
+	f.names = make(map[ResID]string)
+	f.ids = make(map[string]ResID)
+	for _, p := range f.Packages() {
+		pkg := p.Name()
+		for _, t := range p.TableTypes {
+			typeName := p.typeName(t.Header.ID)
+			for i, e := range t.Entries {
+				if e.Key == nil {
+					continue
+				}
+				id := ResID(p.Header.ID<<24 | uint32(t.Header.ID)<<16 | uint32(i))
+				if _, ok := f.names[id]; ok {
+					continue
+				}
+				name := typeName + "/" + p.keyName(e.Key.Key)
+				f.names[id] = pkg + ":" + name
+				f.ids[pkg+":"+name] = id
+				if _, ok := f.ids[name]; !ok {
+					f.ids[name] = id
+				}
+			}
+		}
+	}
+}
+
+// LookupName returns the name of the resource id, e.g.
+// "com.example.app:string/app_name".
+func (f *TableFile) LookupName(id ResID) (string, error) {
+	f.indexOnce.Do(f.buildIndex)
+	name, ok := f.names[id]
+	if !ok {
+		return "", fmt.Errorf("apkparser: resource %s not found", id)
+	}
+	return name, nil
+}
+
+// LookupID returns the ID of the resource name. The name is either
+// qualified with its package, e.g. "com.example.app:string/app_name", or
+// not, e.g. "string/app_name", and may start with "@" as in XML files.
+func (f *TableFile) LookupID(name string) (ResID, error) {
+	f.indexOnce.Do(f.buildIndex)
+	id, ok := f.ids[strings.TrimPrefix(name, "@")]
+	if !ok {
+		return 0, fmt.Errorf("apkparser: resource %s not found", name)
+	}
+	return id, nil
+}
+
+// referenceName returns prefix followed by the name of the resource id
+// without its package, e.g. "@string/app_name", or by its ID if the table
+// doesn't define it, e.g. "@0x01010000".
+func (f *TableFile) referenceName(prefix string, id ResID) string {
+	name, err := f.LookupName(id)
+	if err != nil {
+		return prefix + id.String()[1:]
+	}
+	if i := strings.IndexByte(name, ':'); i >= 0 {
+		name = name[i+1:]
+	}
+	return prefix + name
+}
+
+// describeEntry describes the entry of id for error messages, naming the
+// resource when the table knows it.
+func (f *TableFile) describeEntry(id ResID) string {
+	if name, err := f.LookupName(id); err == nil {
+		return fmt.Sprintf("0x%04X (%s)", id.Entry(), name)
+	}
+	return fmt.Sprintf("0x%04X", id.Entry())
+}
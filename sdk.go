@@ -0,0 +1,165 @@
+package apkparser
+
+import (
+	_ "embed" // embed the default SDK rules
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+//go:embed sdk_rules.json
+var defaultSDKRulesJSON []byte
+
+var (
+	defaultSDKRulesOnce sync.Once
+	defaultSDKRules     []SDKRule
+)
+
+// SDKRule identifies a third-party SDK by the packages of its classes.
+type SDKRule struct {
+	Name     string   `json:"name"`
+	Category string   `json:"category"` // e.g. analytics, ads, crash-reporting or payments
+	Prefixes []string `json:"prefixes"` // Java packages or classes, e.g. com.google.firebase.analytics
+}
+
+// SDK is a third-party SDK embedded in an APK.
+type SDK struct {
+	Name     string `json:"name"`
+	Category string `json:"category"`
+	Classes  int    `json:"classes"` // number of matching classes
+}
+
+// SDKDatabase maps class names to the SDKs they belong to.
+type SDKDatabase struct {
+	rules    []SDKRule
+	prefixes map[string]int // index of the rule of each prefix
+}
+
+// NewSDKDatabase returns a database of rules. Rules added later take
+// precedence over earlier rules with the same prefix.
+func NewSDKDatabase(rules ...SDKRule) *SDKDatabase {
+	db := &SDKDatabase{prefixes: make(map[string]int)}
+	db.Add(rules...)
+	return db
+}
+
+// DefaultSDKDatabase returns a new database of the rules shipped with the
+// package, which callers may extend with Add.
+func DefaultSDKDatabase() *SDKDatabase {
+	defaultSDKRulesOnce.Do(func() {
+		if err := json.Unmarshal(defaultSDKRulesJSON, &defaultSDKRules); err != nil {
+			panic(fmt.Sprintf("apkparser: invalid embedded SDK rules: %v", err))
+		}
+	})
+	return NewSDKDatabase(defaultSDKRules...)
+}
+
+// ReadSDKRules reads rules in the JSON format of the embedded rules, an
+// array of objects with name, category and prefixes.
+func ReadSDKRules(r io.Reader) ([]SDKRule, error) {
+	var rules []SDKRule
+	if err := json.NewDecoder(r).Decode(&rules); err != nil {
+		return nil, fmt.Errorf("apkparser: read SDK rules: %w", err)
+	}
+	return rules, nil
+}
+
+// Add adds rules to the database.
+func (db *SDKDatabase) Add(rules ...SDKRule) {
+	for _, rule := range rules {
+		db.rules = append(db.rules, rule)
+		for _, prefix := range rule.Prefixes {
+			db.prefixes[prefix] = len(db.rules) - 1
+		}
+	}
+}
+
+// Rules returns the rules of the database.
+func (db *SDKDatabase) Rules() []SDKRule {
+	return append([]SDKRule(nil), db.rules...)
+}
+
+// lookup returns the index of the rule of the longest prefix matching the
+// class name, at a package boundary, or -1 if none does.
+func (db *SDKDatabase) lookup(className string) int {
+	for name := className; name != ""; {
+		if i, ok := db.prefixes[name]; ok {
+			return i
+		}
+		dot := strings.LastIndexByte(name, '.')
+		if dot < 0 {
+			break
+		}
+		name = name[:dot]
+	}
+	return -1
+}
+
+// Match returns the SDKs the classes belong to, sorted by name.
+func (db *SDKDatabase) Match(classNames []string) []SDK {
+	counts := make(map[int]int)
+	for _, name := range classNames {
+		if i := db.lookup(name); i >= 0 {
+			counts[i]++
+		}
+	}
+	// rules may share names, e.g. when callers override the prefixes of
+	// a default rule.
+	byName := make(map[string]*SDK)
+	for i, n := range counts {
+		rule := db.rules[i]
+		if sdk, ok := byName[rule.Name]; ok {
+			sdk.Classes += n
+			continue
+		}
+		byName[rule.Name] = &SDK{Name: rule.Name, Category: rule.Category, Classes: n}
+	}
+	sdks := make([]SDK, 0, len(byName))
+	for _, sdk := range byName {
+		sdks = append(sdks, *sdk)
+	}
+	sort.Slice(sdks, func(i, j int) bool {
+		return sdks[i].Name < sdks[j].Name
+	})
+	return sdks
+}
+
+// classNames returns the names of the classes the DEX files of the APK
+// define or reference.
+func (k *apk) classNames() ([]string, error) {
+	files, _, err := k.dexFiles()
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var names []string
+	for _, f := range files {
+		types, err := f.TypeDescriptors()
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range types {
+			if !strings.HasPrefix(t, "L") || seen[t] {
+				continue
+			}
+			seen[t] = true
+			names = append(names, ClassName(t))
+		}
+	}
+	return names, nil
+}
+
+// sdks returns the third-party SDKs of db the APK embeds.
+func (k *apk) sdks(db *SDKDatabase) ([]SDK, error) {
+	names, err := k.classNames()
+	if err != nil {
+		return nil, err
+	}
+	if db == nil {
+		db = DefaultSDKDatabase()
+	}
+	return db.Match(names), nil
+}
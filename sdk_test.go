@@ -0,0 +1,63 @@
+package apkparser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSDKs(t *testing.T) {
+	name := writeAPK(t,
+		zipFile{"AndroidManifest.xml", encodeXML(testManifest())},
+		zipFile{"resources.arsc", testTable()},
+		zipFile{"classes.dex", encodeDex(
+			"Lcom/example/app/Main;",
+			"Lcom/google/firebase/crashlytics/FirebaseCrashlytics;",
+			"Lcom/google/firebase/crashlytics/internal/Logger;",
+			"Lcom/google/android/gms/measurement/AppMeasurement;",
+			"Ljava/lang/Object;",
+		)},
+		zipFile{"classes2.dex", encodeDex(
+			"Lcom/applovin/sdk/AppLovinSdk;",
+			"Lcom/acme/billing/Checkout;",
+			// classes referenced by several DEX files count once.
+			"Lcom/google/firebase/crashlytics/FirebaseCrashlytics;",
+		)},
+		zipFile{"classes10.dex.bak", []byte("not a dex file")},
+	)
+
+	info, err := New(name, Option{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.SDKs != nil {
+		t.Errorf("SDKs without WithDex = %+v", info.SDKs)
+	}
+
+	rules, err := ReadSDKRules(strings.NewReader(`[{"name": "Acme Billing", "category": "payments", "prefixes": ["com.acme.billing"]}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := DefaultSDKDatabase()
+	db.Add(rules...)
+	info, err = New(name, Option{WithDex: true, SDKDatabase: db})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SDK{
+		{Name: "Acme Billing", Category: "payments", Classes: 1},
+		{Name: "AppLovin", Category: "ads", Classes: 1},
+		{Name: "Firebase Analytics", Category: "analytics", Classes: 1},
+		{Name: "Firebase Crashlytics", Category: "crash-reporting", Classes: 2},
+	}
+	if !reflect.DeepEqual(info.SDKs, want) {
+		t.Errorf("SDKs:\ngot  %+v\nwant %+v", info.SDKs, want)
+	}
+
+	// the default database isn't changed by callers.
+	for _, rule := range DefaultSDKDatabase().Rules() {
+		if rule.Name == "Acme Billing" {
+			t.Error("default database has a caller rule")
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package apkparser
+
+import (
+	"archive/zip"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Categories of the zip entries of an APK.
+const (
+	CategoryDex       = "dex"       // classes.dex, classesN.dex
+	CategoryNative    = "native"    // lib/
+	CategoryResources = "resources" // resources.arsc
+	CategoryAssets    = "assets"    // assets/
+	CategoryRes       = "res"       // res/, or r/ when shortened by R8
+	CategoryMetaInf   = "META-INF"  // META-INF/, signatures and library metadata
+	CategoryOther     = "other"
+)
+
+// ZipEntry is a file of an APK.
+type ZipEntry struct {
+	Name             string `json:"name"`
+	CompressedSize   uint64 `json:"compressedSize"`
+	UncompressedSize uint64 `json:"uncompressedSize"`
+	Method           string `json:"method"` // stored, deflated, or the number of other methods
+	CRC32            uint32 `json:"crc32"`
+	Category         string `json:"category"`
+}
+
+// SizeGroup are the total sizes of a group of zip entries.
+type SizeGroup struct {
+	Name             string `json:"name"`
+	Files            int    `json:"files"`
+	CompressedSize   uint64 `json:"compressedSize"`
+	UncompressedSize uint64 `json:"uncompressedSize"`
+}
+
+// SizeReport breaks the size of an APK down by zip entry, like the APK
+// Analyzer of Android Studio.
+type SizeReport struct {
+	Size        int64       `json:"size"`        // of the APK file, in bytes
+	Total       SizeGroup   `json:"total"`       // of all the entries
+	Categories  []SizeGroup `json:"categories"`  // by category, the largest first
+	Directories []SizeGroup `json:"directories"` // by top-level directory, / for the files at the root
+	Entries     []ZipEntry  `json:"entries"`     // in zip order
+}
+
+// add adds an entry to the group.
+func (g *SizeGroup) add(e *ZipEntry) {
+	g.Files++
+	g.CompressedSize += e.CompressedSize
+	g.UncompressedSize += e.UncompressedSize
+}
+
+// entryCategory returns the category of the zip entry name.
+func entryCategory(name string) string {
+	switch {
+	case isDexName(name):
+		return CategoryDex
+	case name == "resources.arsc":
+		return CategoryResources
+	case strings.HasPrefix(name, "lib/"):
+		return CategoryNative
+	case strings.HasPrefix(name, "assets/"):
+		return CategoryAssets
+	case strings.HasPrefix(name, "res/"), strings.HasPrefix(name, "r/"):
+		return CategoryRes
+	case strings.HasPrefix(name, "META-INF/"):
+		return CategoryMetaInf
+	}
+	return CategoryOther
+}
+
+// compressionMethod returns the name of the compression method of a zip
+// entry.
+func compressionMethod(method uint16) string {
+	switch method {
+	case zip.Store:
+		return "stored"
+	case zip.Deflate:
+		return "deflated"
+	}
+	return fmt.Sprintf("method %d", method)
+}
+
+// sizeReport returns the sizes of the zip entries of the APK.
+func (k *apk) sizeReport() *SizeReport {
+	report := &SizeReport{Size: k.size, Total: SizeGroup{Name: "total"}}
+	categories := make(map[string]*SizeGroup)
+	directories := make(map[string]*SizeGroup)
+	group := func(groups map[string]*SizeGroup, name string) *SizeGroup {
+		g, ok := groups[name]
+		if !ok {
+			g = &SizeGroup{Name: name}
+			groups[name] = g
+		}
+		return g
+	}
+	for _, f := range k.archive.files {
+		if strings.HasSuffix(f.Name, "/") {
+			continue
+		}
+		e := ZipEntry{
+			Name:             f.Name,
+			CompressedSize:   f.CompressedSize64,
+			UncompressedSize: f.UncompressedSize64,
+			Method:           compressionMethod(f.Method),
+			CRC32:            f.CRC32,
+			Category:         entryCategory(f.Name),
+		}
+		report.Entries = append(report.Entries, e)
+		report.Total.add(&e)
+		group(categories, e.Category).add(&e)
+		dir := "/"
+		if i := strings.IndexByte(f.Name, '/'); i >= 0 {
+			dir = f.Name[:i+1]
+		}
+		group(directories, dir).add(&e)
+	}
+	report.Categories = sortedGroups(categories)
+	report.Directories = sortedGroups(directories)
+	return report
+}
+
+// sortedGroups returns the groups, the largest first.
+func sortedGroups(groups map[string]*SizeGroup) []SizeGroup {
+	sorted := make([]SizeGroup, 0, len(groups))
+	for _, g := range groups {
+		sorted = append(sorted, *g)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].CompressedSize != sorted[j].CompressedSize {
+			return sorted[i].CompressedSize > sorted[j].CompressedSize
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
@@ -0,0 +1,81 @@
+package apkparser
+
+import (
+	"hash/crc32"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestSizeReport(t *testing.T) {
+	so := make([]byte, 4096) // compresses well
+	table := testTable()
+	name := writeAPK(t,
+		zipFile{"AndroidManifest.xml", encodeXML(testManifest())},
+		zipFile{"resources.arsc", table},
+		zipFile{"classes.dex", encodeDex("Ljava/lang/Object;")},
+		zipFile{"classes2.dex", encodeDex("Ljava/lang/String;")},
+		zipFile{"lib/arm64-v8a/libnative.so", so},
+		zipFile{"lib/armeabi-v7a/libnative.so", so},
+		zipFile{"assets/data.json", []byte(`{"key": "value"}`)},
+		zipFile{"res/drawable/icon.png", []byte("\x89PNG")},
+		zipFile{"r/a.xml", []byte("xml")},
+		zipFile{"META-INF/MANIFEST.MF", []byte("Manifest-Version: 1.0\r\n")},
+		zipFile{"kotlin/collections/collections.kotlin_builtins", []byte("builtins")},
+	)
+	report, err := ReadSizeReport(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Size != fi.Size() || report.Total.Files != 11 || len(report.Entries) != 11 {
+		t.Errorf("size = %d, total = %+v", report.Size, report.Total)
+	}
+	arsc := report.Entries[1]
+	if arsc.Category != CategoryResources || arsc.Method != "stored" || arsc.CompressedSize != uint64(len(table)) ||
+		arsc.UncompressedSize != uint64(len(table)) || arsc.CRC32 != crc32.ChecksumIEEE(table) {
+		t.Errorf("resources.arsc = %+v", arsc)
+	}
+	lib := report.Entries[4]
+	if lib.Category != CategoryNative || lib.Method != "deflated" || lib.UncompressedSize != 4096 || lib.CompressedSize >= 4096 {
+		t.Errorf("libnative.so = %+v", lib)
+	}
+
+	var categories []string
+	var compressed uint64
+	for _, g := range report.Categories {
+		categories = append(categories, g.Name)
+		compressed += g.CompressedSize
+	}
+	if compressed != report.Total.CompressedSize {
+		t.Errorf("categories add up to %d, total %d", compressed, report.Total.CompressedSize)
+	}
+	// sorted by size, and resources.arsc is stored.
+	if categories[0] != CategoryResources {
+		t.Errorf("categories = %q", categories)
+	}
+	files := make(map[string]int)
+	for _, g := range report.Categories {
+		files[g.Name] = g.Files
+	}
+	want := map[string]int{
+		CategoryDex: 2, CategoryResources: 1, CategoryNative: 2, CategoryAssets: 1,
+		CategoryRes: 2, CategoryMetaInf: 1, CategoryOther: 2,
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("files by category = %v, want %v", files, want)
+	}
+
+	dirs := make(map[string]int)
+	for _, g := range report.Directories {
+		dirs[g.Name] = g.Files
+	}
+	wantDirs := map[string]int{"/": 4, "lib/": 2, "assets/": 1, "res/": 1, "r/": 1, "META-INF/": 1, "kotlin/": 1}
+	if !reflect.DeepEqual(dirs, wantDirs) {
+		t.Errorf("files by directory = %v, want %v", dirs, wantDirs)
+	}
+}
@@ -6,16 +6,26 @@ import (
 	"io"
 	"strconv"
 	"strings"
+	"sync"
 	"unsafe"
 )
 
 // ResID is ID for resources.
 type ResID uint32
 
+// maxReferenceDepth limits how many resource references are followed
+// before giving up, so that reference cycles cannot loop forever.
+const maxReferenceDepth = 16
+
 // TableFile is a resource table file.
 type TableFile struct {
 	stringPool    *ResStringPool
 	tablePackages map[uint32]*TablePackage
+
+	// index of resource names, built on first lookup.
+	indexOnce sync.Once
+	names     map[ResID]string
+	ids       map[string]ResID
 }
 
 // ResTableHeader is a header of TableFile.
@@ -43,6 +53,15 @@ type TablePackage struct {
 	TableTypes  []*TableType
 }
 
+// Flags of ResTableType, stored in Res0.
+const (
+	// TypeFlagSparse marks a table type whose entries are encoded as
+	// pairs of entry index and offset.
+	TypeFlagSparse = 0x01
+	// TypeFlagOffset16 marks a table type whose entry offsets are 16 bits.
+	TypeFlagOffset16 = 0x02
+)
+
 // ResTableType is a type of a table.
 type ResTableType struct {
 	Header       ResChunkHeader
@@ -167,11 +186,32 @@ type ResTableEntry struct {
 	Key   ResStringPoolRef
 }
 
+// Flags of ResTableEntry.
+const (
+	// EntryFlagComplex marks a bag of ResTableMap, e.g. a style or an array.
+	EntryFlagComplex = 0x0001
+	// EntryFlagPublic marks an entry made public with <public>.
+	EntryFlagPublic = 0x0002
+	// EntryFlagWeak marks an entry that may be overridden by strong ones.
+	EntryFlagWeak = 0x0004
+	// EntryFlagCompact marks an 8 byte entry holding its value inline.
+	EntryFlagCompact = 0x0008
+)
+
 // TableEntry is a entry in a recource table.
+// Simple entries have a Value, complex entries have a Map instead.
 type TableEntry struct {
-	Key   *ResTableEntry
-	Value *ResValue
-	Flags uint32
+	Key    *ResTableEntry
+	Value  *ResValue
+	Flags  uint32
+	Parent ResID
+	Map    []ResTableMap
+}
+
+// ResTableMap is a name/value pair of a complex entry.
+type ResTableMap struct {
+	Name  ResID
+	Value ResValue
 }
 
 // ResTableTypeSpec is specification of the resources defined by a particular type.
@@ -204,6 +244,21 @@ func (id ResID) String() string {
 	return fmt.Sprintf("@0x%08X", uint32(id))
 }
 
+// MarshalText implements encoding.TextMarshaler.
+func (id ResID) MarshalText() ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (id *ResID) UnmarshalText(text []byte) error {
+	v, err := ParseResID(string(text))
+	if err != nil {
+		return err
+	}
+	*id = v
+	return nil
+}
+
 // Package returns the package index of id.
 func (id ResID) Package() uint32 {
 	return uint32(id) >> 24
@@ -219,18 +274,27 @@ func (id ResID) Entry() int {
 	return int(id) & 0xFFFF
 }
 
-// NewTableFile returns new TableFile.
+// NewTableFile returns new TableFile, within DefaultLimits.
 func NewTableFile(r io.ReaderAt) (*TableFile, error) {
+	return newTableFile(r, DefaultLimits)
+}
+
+// newTableFile returns new TableFile, whose string pools have at most
+// limits.MaxStringCount strings.
+func newTableFile(r io.ReaderAt, limits Limits) (*TableFile, error) {
 	f := new(TableFile)
-	sr := io.NewSectionReader(r, 0, 1<<63-1)
+	size := readerSize(r)
+	sr := io.NewSectionReader(r, 0, size)
 
 	header := new(ResTableHeader)
-	binary.Read(sr, binary.LittleEndian, header)
+	if err := binary.Read(sr, binary.LittleEndian, header); err != nil {
+		return nil, &ChunkError{Type: header.Header.Type, Err: err}
+	}
 	f.tablePackages = make(map[uint32]*TablePackage)
 
 	offset := int64(header.Header.HeaderSize)
 	for offset < int64(header.Header.Size) {
-		chunkHeader, err := f.readChunk(sr, offset)
+		chunkHeader, err := f.readChunk(sr, offset, size, limits.MaxStringCount)
 		if err != nil {
 			return nil, err
 		}
@@ -256,7 +320,7 @@ func (p *TablePackage) findEntry(typeIndex, entryIndex int, config *ResTableConf
 			// nothing to do
 		case entryIndex >= len(t.Entries):
 			// nothing to do
-		case t.Entries[entryIndex].Value == nil:
+		case t.Entries[entryIndex].Key == nil:
 			// nothing to do
 		case best == nil || t.Header.Config.IsBetterThan(&best.Header.Config, config):
 			best = t
@@ -277,13 +341,13 @@ func (f *TableFile) GetResource(id ResID, config *ResTableConfig) (interface{},
 	e := p.findEntry(id.Type(), id.Entry(), config)
 	v := e.Value
 	if v == nil {
-		return nil, fmt.Errorf("apkparser: entry 0x%04X not found", id.Entry())
+		return nil, fmt.Errorf("apkparser: entry %s not found", f.describeEntry(id))
 	}
 	switch v.DataType {
 	case TypeNull:
 		return nil, nil
 	case TypeString:
-		return f.GetString(ResStringPoolRef(v.Data)), nil
+		return f.getString(ResStringPoolRef(v.Data))
 	case TypeIntDec:
 		return v.Data, nil
 	case TypeIntHex:
@@ -294,75 +358,91 @@ func (f *TableFile) GetResource(id ResID, config *ResTableConfig) (interface{},
 	return v.Data, nil
 }
 
+// getValue returns the raw value of the entry referenced by id,
+// following references to other resources.
+func (f *TableFile) getValue(id ResID, config *ResTableConfig) (*ResValue, error) {
+	for i := 0; i < maxReferenceDepth; i++ {
+		p := f.findPackage(id.Package())
+		if p == nil {
+			return nil, fmt.Errorf("apkparser: package 0x%02X not found", id.Package())
+		}
+		v := p.findEntry(id.Type(), id.Entry(), config).Value
+		if v == nil {
+			return nil, fmt.Errorf("apkparser: entry %s not found", f.describeEntry(id))
+		}
+		if v.DataType != TypeReference {
+			return v, nil
+		}
+		id = ResID(v.Data)
+	}
+	return nil, fmt.Errorf("apkparser: too many references resolving %s", id)
+}
+
 // GetString returns a string referenced by ref.
 func (f *TableFile) GetString(ref ResStringPoolRef) string {
 	return f.stringPool.GetString(ref)
 }
 
-func (f *TableFile) readChunk(r io.ReaderAt, offset int64) (*ResChunkHeader, error) {
-	sr := io.NewSectionReader(r, offset, 1<<63-1-offset)
-	chunkHeader := &ResChunkHeader{}
-	if _, err := sr.Seek(0, seekStart); err != nil {
-		return nil, err
-	}
-	if err := binary.Read(sr, binary.LittleEndian, chunkHeader); err != nil {
-		return nil, err
-	}
+// getString returns a string referenced by ref, or an error if ref is out
+// of range.
+func (f *TableFile) getString(ref ResStringPoolRef) (string, error) {
+	return f.stringPool.getString(ref)
+}
 
-	var err error
-	if _, err := sr.Seek(0, seekStart); err != nil {
+func (f *TableFile) readChunk(r io.ReaderAt, offset, size int64, maxStrings int) (*ResChunkHeader, error) {
+	chunkHeader, sr, err := readChunkHeader(r, offset, size)
+	if err != nil {
 		return nil, err
 	}
+
 	switch chunkHeader.Type {
 	case ResStringPoolChunkType:
-		f.stringPool, err = readStringPool(sr)
+		f.stringPool, err = readStringPool(sr, maxStrings)
 	case ResTablePackageType:
 		var tablePackage *TablePackage
-		tablePackage, err = readTablePackage(sr)
-		f.tablePackages[tablePackage.Header.ID] = tablePackage
+		if tablePackage, err = readTablePackage(sr, maxStrings); err == nil {
+			f.tablePackages[tablePackage.Header.ID] = tablePackage
+		}
 	}
 	if err != nil {
-		return nil, err
+		return nil, &ChunkError{Type: chunkHeader.Type, Offset: offset, Err: err}
 	}
 
 	return chunkHeader, nil
 }
 
-func readTablePackage(sr *io.SectionReader) (*TablePackage, error) {
+// readTablePackage reads the package chunk sr, whose string pools have at
+// most maxStrings strings.
+func readTablePackage(sr *io.SectionReader, maxStrings int) (*TablePackage, error) {
 	tablePackage := new(TablePackage)
 	header := new(ResTablePackage)
 	if err := binary.Read(sr, binary.LittleEndian, header); err != nil {
 		return nil, err
 	}
 	tablePackage.Header = *header
+	if int64(header.TypeStrings) > sr.Size() || int64(header.KeyStrings) > sr.Size() {
+		return nil, fmt.Errorf("apkparser: string pools at %d and %d exceed the package of %d bytes",
+			header.TypeStrings, header.KeyStrings, sr.Size())
+	}
 
-	srTypes := io.NewSectionReader(sr, int64(header.TypeStrings), int64(header.Header.Size-header.TypeStrings))
-	if typeStrings, err := readStringPool(srTypes); err == nil {
+	srTypes := io.NewSectionReader(sr, int64(header.TypeStrings), sr.Size()-int64(header.TypeStrings))
+	if typeStrings, err := readStringPool(srTypes, maxStrings); err == nil {
 		tablePackage.TypeStrings = typeStrings
 	} else {
 		return nil, err
 	}
 
-	srKeys := io.NewSectionReader(sr, int64(header.KeyStrings), int64(header.Header.Size-header.KeyStrings))
-	if keyStrings, err := readStringPool(srKeys); err == nil {
+	srKeys := io.NewSectionReader(sr, int64(header.KeyStrings), sr.Size()-int64(header.KeyStrings))
+	if keyStrings, err := readStringPool(srKeys, maxStrings); err == nil {
 		tablePackage.KeyStrings = keyStrings
 	} else {
 		return nil, err
 	}
 
 	offset := int64(header.Header.HeaderSize)
-	for offset < int64(header.Header.Size) {
-		chunkHeader := &ResChunkHeader{}
-		if _, err := sr.Seek(offset, seekStart); err != nil {
-			return nil, err
-		}
-		if err := binary.Read(sr, binary.LittleEndian, chunkHeader); err != nil {
-			return nil, err
-		}
-
-		var err error
-		chunkReader := io.NewSectionReader(sr, offset, int64(chunkHeader.Size))
-		if _, err := sr.Seek(offset, seekStart); err != nil {
+	for offset < sr.Size() {
+		chunkHeader, chunkReader, err := readChunkHeader(sr, offset, sr.Size())
+		if err != nil {
 			return nil, err
 		}
 		switch chunkHeader.Type {
@@ -374,7 +454,7 @@ func readTablePackage(sr *io.SectionReader) (*TablePackage, error) {
 			_, err = readTableTypeSpec(chunkReader)
 		}
 		if err != nil {
-			return nil, err
+			return nil, &ChunkError{Type: chunkHeader.Type, Offset: offset, Err: err}
 		}
 		offset += int64(chunkHeader.Size)
 	}
@@ -396,29 +476,33 @@ func readTableType(chunkHeader *ResChunkHeader, sr *io.SectionReader) (*TableTyp
 		return nil, err
 	}
 
-	entryIndexes := make([]uint32, header.EntryCount)
 	if _, err := sr.Seek(int64(header.Header.HeaderSize), seekStart); err != nil {
 		return nil, err
 	}
-	if err := binary.Read(sr, binary.LittleEndian, entryIndexes); err != nil {
+	entryIndexes, err := readEntryIndexes(sr, header)
+	if err != nil {
 		return nil, err
 	}
 
-	entries := make([]TableEntry, header.EntryCount)
+	entries := make([]TableEntry, len(entryIndexes))
+	// entries at the same offset are read once, so that a hostile table
+	// can't make every index read the same large entry.
+	read := make(map[uint32]int)
 	for i, index := range entryIndexes {
 		if index == 0xFFFFFFFF {
 			continue
 		}
-		if _, err := sr.Seek(int64(header.EntriesStart+index), seekStart); err != nil {
+		if j, ok := read[index]; ok {
+			entries[i] = entries[j]
+			continue
+		}
+		if _, err := sr.Seek(int64(header.EntriesStart)+int64(index), seekStart); err != nil {
 			return nil, err
 		}
-		var key ResTableEntry
-		binary.Read(sr, binary.LittleEndian, &key)
-		entries[i].Key = &key
-
-		var val ResValue
-		binary.Read(sr, binary.LittleEndian, &val)
-		entries[i].Value = &val
+		if err := readTableEntry(sr, &entries[i]); err != nil {
+			return nil, err
+		}
+		read[index] = i
 	}
 	return &TableType{
 		header,
@@ -426,16 +510,124 @@ func readTableType(chunkHeader *ResChunkHeader, sr *io.SectionReader) (*TableTyp
 	}, nil
 }
 
+// readEntryIndexes reads the offsets of the entries of a table type,
+// indexed by entry index. Missing entries have the offset 0xFFFFFFFF.
+func readEntryIndexes(sr *io.SectionReader, header *ResTableType) ([]uint32, error) {
+	// the indexes follow the header: 4 bytes per entry, or 2 for 16-bit
+	// offsets.
+	size := 4 * int64(header.EntryCount)
+	if header.Res0&TypeFlagSparse == 0 && header.Res0&TypeFlagOffset16 != 0 {
+		size = 2 * int64(header.EntryCount)
+	}
+	if err := checkRemaining(sr, size); err != nil {
+		return nil, err
+	}
+	switch {
+	case header.Res0&TypeFlagSparse != 0:
+		// pairs of entry index and offset divided by 4, sorted by entry index.
+		sparse := make([]uint16, 2*header.EntryCount)
+		if err := binary.Read(sr, binary.LittleEndian, sparse); err != nil {
+			return nil, err
+		}
+		var entryIndexes []uint32
+		for i := 0; i < len(sparse); i += 2 {
+			for len(entryIndexes) < int(sparse[i]) {
+				entryIndexes = append(entryIndexes, 0xFFFFFFFF)
+			}
+			entryIndexes = append(entryIndexes[:sparse[i]], uint32(sparse[i+1])*4)
+		}
+		return entryIndexes, nil
+	case header.Res0&TypeFlagOffset16 != 0:
+		// offsets divided by 4, 0xFFFF for missing entries.
+		offsets := make([]uint16, header.EntryCount)
+		if err := binary.Read(sr, binary.LittleEndian, offsets); err != nil {
+			return nil, err
+		}
+		entryIndexes := make([]uint32, len(offsets))
+		for i, offset := range offsets {
+			if offset == 0xFFFF {
+				entryIndexes[i] = 0xFFFFFFFF
+			} else {
+				entryIndexes[i] = uint32(offset) * 4
+			}
+		}
+		return entryIndexes, nil
+	}
+	entryIndexes := make([]uint32, header.EntryCount)
+	if err := binary.Read(sr, binary.LittleEndian, entryIndexes); err != nil {
+		return nil, err
+	}
+	return entryIndexes, nil
+}
+
+// readTableEntry reads a simple, complex or compact entry.
+func readTableEntry(sr *io.SectionReader, entry *TableEntry) error {
+	var key ResTableEntry
+	if err := binary.Read(sr, binary.LittleEndian, &key); err != nil {
+		return err
+	}
+
+	switch {
+	case key.Flags&EntryFlagCompact != 0:
+		// the key index is stored in place of the size, the data type in the
+		// high byte of the flags and the data in place of the key.
+		entry.Key = &ResTableEntry{
+			Size:  8,
+			Flags: key.Flags,
+			Key:   ResStringPoolRef(key.Size),
+		}
+		entry.Value = &ResValue{
+			Size:     8,
+			DataType: DataType(key.Flags >> 8),
+			Data:     uint32(key.Key),
+		}
+	case key.Flags&EntryFlagComplex != 0:
+		var ext struct {
+			Parent ResID
+			Count  uint32
+		}
+		if err := binary.Read(sr, binary.LittleEndian, &ext); err != nil {
+			return err
+		}
+		// skip the rest of the header, if any.
+		if key.Size > 16 {
+			if _, err := sr.Seek(int64(key.Size)-16, io.SeekCurrent); err != nil {
+				return err
+			}
+		}
+		entry.Key = &key
+		entry.Parent = ext.Parent
+		if err := checkRemaining(sr, int64(ext.Count)*int64(unsafe.Sizeof(ResTableMap{}))); err != nil {
+			return err
+		}
+		entry.Map = make([]ResTableMap, ext.Count)
+		if err := binary.Read(sr, binary.LittleEndian, entry.Map); err != nil {
+			return err
+		}
+	default:
+		var val ResValue
+		if err := binary.Read(sr, binary.LittleEndian, &val); err != nil {
+			return err
+		}
+		entry.Key = &key
+		entry.Value = &val
+	}
+	return nil
+}
+
 func readTableTypeSpec(sr *io.SectionReader) ([]uint32, error) {
 	header := new(ResTableTypeSpec)
 	if err := binary.Read(sr, binary.LittleEndian, header); err != nil {
 		return nil, err
 	}
 
-	flags := make([]uint32, header.EntryCount)
 	if _, err := sr.Seek(int64(header.Header.HeaderSize), seekStart); err != nil {
 		return nil, err
 	}
+	if err := checkRemaining(sr, 4*int64(header.EntryCount)); err != nil {
+		return nil, err
+	}
+	flags := make([]uint32, header.EntryCount)
 	if err := binary.Read(sr, binary.LittleEndian, flags); err != nil {
 		return nil, err
 	}
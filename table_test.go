@@ -0,0 +1,108 @@
+package apkparser
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTableResources(t *testing.T) {
+	table, err := NewTableFile(bytes.NewReader(testTable()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resources := table.Resources()
+	if len(resources) != 4 {
+		t.Fatalf("got %d resources, want 4", len(resources))
+	}
+	appName := resources[0]
+	if appName.ID != 0x7f010000 || appName.Package != "com.example.app" ||
+		appName.Type != "string" || appName.Name != "app_name" {
+		t.Errorf("unexpected resource %+v", appName)
+	}
+	if len(appName.Values) != 2 {
+		t.Fatalf("got %d values, want 2", len(appName.Values))
+	}
+	if v := appName.Values[1]; v.Qualifiers != "fr" || v.Value != "Exemple" {
+		t.Errorf("unexpected value %+v", v)
+	}
+	if v := resources[3].Values[0]; v.Value != "#ff336699" {
+		t.Errorf("color is %q, want #ff336699", v.Value)
+	}
+}
+
+func TestResTableConfigQualifiers(t *testing.T) {
+	tests := []struct {
+		config ResTableConfig
+		want   string
+	}{
+		{ResTableConfig{}, ""},
+		{ResTableConfig{Language: [2]uint8{'f', 'r'}, Country: [2]uint8{'C', 'A'}}, "fr-rCA"},
+		{ResTableConfig{Orientation: 2, Density: 320, SDKVersion: 21}, "land-xhdpi-v21"},
+		{ResTableConfig{SmallestScreenWidthDp: 600, UIMode: 0x24, Density: 0xfffe}, "sw600dp-television-night-anydpi"},
+	}
+	for _, tt := range tests {
+		if got := tt.config.Qualifiers(); got != tt.want {
+			t.Errorf("Qualifiers() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestTableLookup(t *testing.T) {
+	table, err := NewTableFile(bytes.NewReader(testTable()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name, err := table.LookupName(0x7f010001)
+	if err != nil || name != "com.example.app:string/untranslated" {
+		t.Errorf("LookupName() = %q, %v", name, err)
+	}
+	for _, s := range []string{"string/app_name", "@string/app_name", "com.example.app:string/app_name"} {
+		if id, err := table.LookupID(s); err != nil || id != 0x7f010000 {
+			t.Errorf("LookupID(%q) = %s, %v", s, id, err)
+		}
+	}
+	if _, err := table.LookupID("string/missing"); err == nil {
+		t.Error("LookupID() of a missing resource succeeded")
+	}
+
+	_, err = table.GetResource(0x7f010001, &ResTableConfig{Language: [2]uint8{'f', 'r'}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = table.GetResource(0x7f010002, &ResTableConfig{Language: [2]uint8{'d', 'e'}})
+	if want := "apkparser: entry 0x0002 (com.example.app:string/french_only) not found"; err == nil || err.Error() != want {
+		t.Errorf("GetResource() error = %v, want %q", err, want)
+	}
+
+	// strings out of range of a corrupted pool aren't silently empty.
+	table.stringPool.Strings = nil
+	if v, err := table.GetResource(0x7f010000, &ResTableConfig{}); err == nil {
+		t.Errorf("GetResource() of a string out of range = %q", v)
+	}
+}
+
+func FuzzTableFile(f *testing.F) {
+	f.Add(testTable())
+	f.Add(encodeTable(0x7f, "com.example.app", []tableType{
+		{ID: 1, Name: "drawable", Entries: []*tableValue{
+			{Name: "icon", Type: TypeString, String: "res/drawable/icon.png"},
+			nil,
+			{Name: "alias", Type: TypeReference, Data: 0x7f010000},
+		}},
+	}))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		table, err := NewTableFile(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		for _, res := range table.Resources() {
+			_, _ = table.GetResource(res.ID, &ResTableConfig{})
+			_, _ = table.LookupName(res.ID)
+			_, _ = table.LookupID(res.Type + "/" + res.Name)
+		}
+		_, _ = table.GetResource(0x7f010000, nil)
+	})
+}
@@ -3,10 +3,10 @@ package apkparser
 import (
 	"bytes"
 	"encoding/binary"
-	"encoding/xml"
 	"fmt"
 	"io"
 	"os"
+	"unsafe"
 )
 
 const seekStart = os.SEEK_SET
@@ -15,11 +15,52 @@ const seekStart = os.SEEK_SET
 type XMLFile struct {
 	stringPool     *ResStringPool
 	resourceMap    []uint32
-	notPrecessedNS map[ResStringPoolRef]ResStringPoolRef
-	namespaces     map[ResStringPoolRef]ResStringPoolRef
-	xmlBuffer      bytes.Buffer
+	notPrecessedNS []XMLNamespace
+	root           *XMLElement
+	stack          []*XMLElement
+	limits         Limits
+
+	// table resolves references when the file was read from an APK.
+	table *TableFile
+}
+
+// XMLNamespace is a namespace declaration.
+type XMLNamespace struct {
+	Prefix string `json:"prefix"`
+	URI    string `json:"uri"`
+}
+
+// XMLNode is a child node of an XMLElement, either *XMLElement or XMLCharData.
+type XMLNode interface {
+	isXMLNode()
+}
+
+// XMLElement is an element of an XML file.
+type XMLElement struct {
+	NS         string         `json:"ns,omitempty"` // namespace URI, empty if none
+	Name       string         `json:"name"`
+	Namespaces []XMLNamespace `json:"namespaces,omitempty"` // namespaces declared on the element
+	Attrs      []XMLAttr      `json:"attrs,omitempty"`
+	Children   []XMLNode      `json:"children,omitempty"`
+	Line       uint32         `json:"line"`
 }
 
+// XMLAttr is an attribute of an element.
+type XMLAttr struct {
+	NS   string `json:"ns,omitempty"` // namespace URI, empty if none
+	Name string `json:"name"`
+	// Value is the text of the attribute. References to resources are
+	// written as @0x7F010000.
+	Value      string   `json:"value"`
+	TypedValue ResValue `json:"-"`
+}
+
+// XMLCharData is the text of an element.
+type XMLCharData string
+
+func (*XMLElement) isXMLNode() {}
+func (XMLCharData) isXMLNode() {}
+
 // ResXMLTreeNode is basic XML tree node.
 type ResXMLTreeNode struct {
 	Header     ResChunkHeader
@@ -53,24 +94,38 @@ type ResXMLTreeAttribute struct {
 	TypedValue ResValue
 }
 
+// ResXMLTreeCdataExt is extended XML tree node for CDATA.
+type ResXMLTreeCdataExt struct {
+	Data      ResStringPoolRef
+	TypedData ResValue
+}
+
 // ResXMLTreeEndElementExt is extended XML tree node for element start/end nodes.
 type ResXMLTreeEndElementExt struct {
 	NS   ResStringPoolRef
 	Name ResStringPoolRef
 }
 
-// NewXMLFile returns a new XMLFile.
+// NewXMLFile returns a new XMLFile, within DefaultLimits.
 func NewXMLFile(r io.ReaderAt) (*XMLFile, error) {
-	f := new(XMLFile)
-	sr := io.NewSectionReader(r, 0, 1<<63-1)
+	return newXMLFile(r, DefaultLimits)
+}
+
+// newXMLFile returns a new XMLFile, whose string pool has at most
+// limits.MaxStringCount strings and whose elements are nested at most
+// limits.MaxXMLDepth deep.
+func newXMLFile(r io.ReaderAt, limits Limits) (*XMLFile, error) {
+	f := &XMLFile{limits: limits}
+	size := readerSize(r)
+	sr := io.NewSectionReader(r, 0, size)
 
 	header := new(ResChunkHeader)
 	if err := binary.Read(sr, binary.LittleEndian, header); err != nil {
-		return nil, err
+		return nil, &ChunkError{Type: header.Type, Err: err}
 	}
 	offset := int64(header.HeaderSize)
 	for offset < int64(header.Size) {
-		chunkHeader, err := f.readChunk(r, offset)
+		chunkHeader, err := f.readChunk(r, offset, size)
 		if err != nil {
 			return nil, err
 		}
@@ -81,26 +136,49 @@ func NewXMLFile(r io.ReaderAt) (*XMLFile, error) {
 
 // Reader returns a reader of XML file expressed in text format.
 func (f *XMLFile) Reader() *bytes.Reader {
-	return bytes.NewReader(f.xmlBuffer.Bytes())
+	var buf bytes.Buffer
+	_ = f.encode(&buf, &XMLEncodeOption{})
+	return bytes.NewReader(buf.Bytes())
 }
 
-func (f *XMLFile) readChunk(r io.ReaderAt, offset int64) (*ResChunkHeader, error) {
-	sr := io.NewSectionReader(r, offset, 1<<63-1-offset)
-	chunkHeader := &ResChunkHeader{}
-	if _, err := sr.Seek(0, seekStart); err != nil {
-		return nil, err
+// Root returns the root element of the XML file, or nil if it is empty.
+func (f *XMLFile) Root() *XMLElement {
+	return f.root
+}
+
+// Attr returns the attribute of e with the given namespace URI and name.
+func (e *XMLElement) Attr(ns, name string) (XMLAttr, bool) {
+	for _, attr := range e.Attrs {
+		if attr.NS == ns && attr.Name == name {
+			return attr, true
+		}
 	}
-	if err := binary.Read(sr, binary.LittleEndian, chunkHeader); err != nil {
-		return nil, err
+	return XMLAttr{}, false
+}
+
+// Elements returns the child elements of e with the given name, or all
+// child elements if name is empty.
+func (e *XMLElement) Elements(name string) []*XMLElement {
+	var elems []*XMLElement
+	for _, c := range e.Children {
+		if c, ok := c.(*XMLElement); ok && (name == "" || c.Name == name) {
+			elems = append(elems, c)
+		}
 	}
+	return elems
+}
 
-	var err error
-	if _, err := sr.Seek(0, seekStart); err != nil {
+func (f *XMLFile) readChunk(r io.ReaderAt, offset, size int64) (*ResChunkHeader, error) {
+	chunkHeader, sr, err := readChunkHeader(r, offset, size)
+	if err != nil {
 		return nil, err
 	}
+
 	switch chunkHeader.Type {
 	case ResStringPoolChunkType:
-		f.stringPool, err = readStringPool(sr)
+		f.stringPool, err = readStringPool(sr, f.limits.MaxStringCount)
+	case ResXMLResourceMapType:
+		err = f.readResourceMap(sr)
 	case ResXMLStartNamespaceType:
 		err = f.readStartNamespace(sr)
 	case ResXMLEndNamespaceType:
@@ -109,9 +187,11 @@ func (f *XMLFile) readChunk(r io.ReaderAt, offset int64) (*ResChunkHeader, error
 		err = f.readStartElement(sr)
 	case ResXMLEndElementType:
 		err = f.readEndElement(sr)
+	case ResXMLCDataType:
+		err = f.readCData(sr)
 	}
 	if err != nil {
-		return nil, err
+		return nil, &ChunkError{Type: chunkHeader.Type, Offset: offset, Err: err}
 	}
 
 	return chunkHeader, nil
@@ -122,6 +202,36 @@ func (f *XMLFile) GetString(ref ResStringPoolRef) string {
 	return f.stringPool.GetString(ref)
 }
 
+// getString returns a string referenced by ref, or an error if ref is out
+// of range.
+func (f *XMLFile) getString(ref ResStringPoolRef) (string, error) {
+	return f.stringPool.getString(ref)
+}
+
+// getOptionalString returns a string referenced by ref, or an empty string
+// if ref is NilResStringPoolRef.
+func (f *XMLFile) getOptionalString(ref ResStringPoolRef) (string, error) {
+	if ref == NilResStringPoolRef {
+		return "", nil
+	}
+	return f.getString(ref)
+}
+
+func (f *XMLFile) readResourceMap(sr *io.SectionReader) error {
+	header := new(ResChunkHeader)
+	if err := binary.Read(sr, binary.LittleEndian, header); err != nil {
+		return err
+	}
+	if _, err := sr.Seek(int64(header.HeaderSize), seekStart); err != nil {
+		return err
+	}
+	if header.Size < uint32(header.HeaderSize) {
+		return fmt.Errorf("apkparser: invalid resource map size %d", header.Size)
+	}
+	f.resourceMap = make([]uint32, (header.Size-uint32(header.HeaderSize))/4)
+	return binary.Read(sr, binary.LittleEndian, f.resourceMap)
+}
+
 func (f *XMLFile) readStartNamespace(sr *io.SectionReader) error {
 	header := new(ResXMLTreeNode)
 	if err := binary.Read(sr, binary.LittleEndian, header); err != nil {
@@ -136,16 +246,18 @@ func (f *XMLFile) readStartNamespace(sr *io.SectionReader) error {
 		return err
 	}
 
-	if f.notPrecessedNS == nil {
-		f.notPrecessedNS = make(map[ResStringPoolRef]ResStringPoolRef)
+	prefix, err := f.getOptionalString(namespace.Prefix)
+	if err != nil {
+		return err
 	}
-	f.notPrecessedNS[namespace.URI] = namespace.Prefix
-
-	if f.namespaces == nil {
-		f.namespaces = make(map[ResStringPoolRef]ResStringPoolRef)
+	uri, err := f.getString(namespace.URI)
+	if err != nil {
+		return err
 	}
-	f.namespaces[namespace.URI] = namespace.Prefix
-
+	f.notPrecessedNS = append(f.notPrecessedNS, XMLNamespace{
+		Prefix: prefix,
+		URI:    uri,
+	})
 	return nil
 }
 
@@ -159,19 +271,7 @@ func (f *XMLFile) readEndNamespace(sr *io.SectionReader) error {
 		return err
 	}
 	namespace := new(ResXMLTreeNamespaceExt)
-	if err := binary.Read(sr, binary.LittleEndian, namespace); err != nil {
-		return err
-	}
-	delete(f.namespaces, namespace.URI)
-	return nil
-}
-
-func (f *XMLFile) addNamespacePrefix(ns, name ResStringPoolRef) string {
-	if ns != NilResStringPoolRef {
-		prefix := f.GetString(f.namespaces[ns])
-		return fmt.Sprintf("%s:%s", prefix, f.GetString(name))
-	}
-	return f.GetString(name)
+	return binary.Read(sr, binary.LittleEndian, namespace)
 }
 
 func (f *XMLFile) readStartElement(sr *io.SectionReader) error {
@@ -185,61 +285,77 @@ func (f *XMLFile) readStartElement(sr *io.SectionReader) error {
 	}
 	ext := new(ResXMLTreeAttrExt)
 	if err := binary.Read(sr, binary.LittleEndian, ext); err != nil {
-		return nil
+		return err
 	}
 
-	fmt.Fprintf(&f.xmlBuffer, "<%s", f.addNamespacePrefix(ext.NS, ext.Name))
-
-	// output XML namespaces
-	if f.notPrecessedNS != nil {
-		for uri, prefix := range f.notPrecessedNS {
-			fmt.Fprintf(&f.xmlBuffer, " xmlns:%s=\"", f.GetString(prefix))
-			xml.Escape(&f.xmlBuffer, []byte(f.GetString(uri)))
-			fmt.Fprint(&f.xmlBuffer, "\"")
-		}
-		f.notPrecessedNS = nil
+	if exceeds(int64(len(f.stack)+1), int64(f.limits.MaxXMLDepth)) {
+		return &LimitError{Limit: "MaxXMLDepth", Max: int64(f.limits.MaxXMLDepth)}
+	}
+	ns, err := f.getOptionalString(ext.NS)
+	if err != nil {
+		return err
 	}
+	name, err := f.getString(ext.Name)
+	if err != nil {
+		return err
+	}
+	elem := &XMLElement{
+		NS:         ns,
+		Name:       name,
+		Namespaces: f.notPrecessedNS,
+		Line:       header.LineNumber,
+	}
+	f.notPrecessedNS = nil
 
-	// process attributes
-	offset := int64(ext.AttributeStart + header.Header.HeaderSize)
+	// process attributes, which must fit in the chunk like Android checks.
+	offset := int64(ext.AttributeStart) + int64(header.Header.HeaderSize)
+	if ext.AttributeCount > 0 && (ext.AttributeSize < uint16(unsafe.Sizeof(ResXMLTreeAttribute{})) ||
+		offset+int64(ext.AttributeSize)*int64(ext.AttributeCount) > sr.Size()) {
+		return fmt.Errorf("apkparser: %d attributes of %d bytes at %d exceed the element of %d bytes",
+			ext.AttributeCount, ext.AttributeSize, offset, sr.Size())
+	}
 	for i := 0; i < int(ext.AttributeCount); i++ {
 		if _, err := sr.Seek(offset, seekStart); err != nil {
 			return err
 		}
 		attr := new(ResXMLTreeAttribute)
-		binary.Read(sr, binary.LittleEndian, attr)
+		if err := binary.Read(sr, binary.LittleEndian, attr); err != nil {
+			return err
+		}
 
 		var value string
 		if attr.RawValue != NilResStringPoolRef {
-			value = f.GetString(attr.RawValue)
-		} else {
-			data := attr.TypedValue.Data
-			switch attr.TypedValue.DataType {
-			case TypeNull:
-				value = ""
-			case TypeReference:
-				value = fmt.Sprintf("@0x%08X", data)
-			case TypeIntDec:
-				value = fmt.Sprintf("%d", data)
-			case TypeIntHex:
-				value = fmt.Sprintf("0x%08X", data)
-			case TypeIntBoolean:
-				if data != 0 {
-					value = "true"
-				} else {
-					value = "false"
-				}
-			default:
-				value = fmt.Sprintf("@0x%08X", data)
+			if value, err = f.getString(attr.RawValue); err != nil {
+				return err
 			}
+		} else {
+			value = formatResValue(&attr.TypedValue)
+		}
+		ns, err := f.getOptionalString(attr.NS)
+		if err != nil {
+			return err
+		}
+		name, err := f.getString(attr.Name)
+		if err != nil {
+			return err
 		}
 
-		fmt.Fprintf(&f.xmlBuffer, " %s=\"", f.addNamespacePrefix(attr.NS, attr.Name))
-		xml.Escape(&f.xmlBuffer, []byte(value))
-		fmt.Fprint(&f.xmlBuffer, "\"")
+		elem.Attrs = append(elem.Attrs, XMLAttr{
+			NS:         ns,
+			Name:       name,
+			Value:      value,
+			TypedValue: attr.TypedValue,
+		})
 		offset += int64(ext.AttributeSize)
 	}
-	fmt.Fprint(&f.xmlBuffer, ">")
+
+	if len(f.stack) > 0 {
+		parent := f.stack[len(f.stack)-1]
+		parent.Children = append(parent.Children, elem)
+	} else if f.root == nil {
+		f.root = elem
+	}
+	f.stack = append(f.stack, elem)
 	return nil
 }
 
@@ -255,6 +371,34 @@ func (f *XMLFile) readEndElement(sr *io.SectionReader) error {
 	if err := binary.Read(sr, binary.LittleEndian, ext); err != nil {
 		return err
 	}
-	fmt.Fprintf(&f.xmlBuffer, "</%s>", f.addNamespacePrefix(ext.NS, ext.Name))
+	if len(f.stack) == 0 {
+		return fmt.Errorf("apkparser: unexpected end of element %s", f.GetString(ext.Name))
+	}
+	f.stack = f.stack[:len(f.stack)-1]
+	return nil
+}
+
+func (f *XMLFile) readCData(sr *io.SectionReader) error {
+	header := new(ResXMLTreeNode)
+	if err := binary.Read(sr, binary.LittleEndian, header); err != nil {
+		return err
+	}
+	if _, err := sr.Seek(int64(header.Header.HeaderSize), seekStart); err != nil {
+		return err
+	}
+	ext := new(ResXMLTreeCdataExt)
+	if err := binary.Read(sr, binary.LittleEndian, ext); err != nil {
+		return err
+	}
+	if len(f.stack) == 0 {
+		// text outside of the root element
+		return nil
+	}
+	data, err := f.getString(ext.Data)
+	if err != nil {
+		return err
+	}
+	parent := f.stack[len(f.stack)-1]
+	parent.Children = append(parent.Children, XMLCharData(data))
 	return nil
 }
@@ -0,0 +1,225 @@
+package apkparser
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// androidNamespace is the namespace of the attributes of the Android framework.
+const androidNamespace = "http://schemas.android.com/apk/res/android"
+
+// ReferenceMode selects how XMLFile.Encode writes references to resources.
+type ReferenceMode int
+
+// The values of ReferenceMode.
+const (
+	// ReferenceID writes references as resource IDs, e.g. @0x7F010000.
+	ReferenceID ReferenceMode = iota
+	// ReferenceName writes references as resource names, e.g. @string/app_name.
+	ReferenceName
+	// ReferenceValue writes the values of the referenced resources, e.g.
+	// the text of a string, and falls back to names for the others.
+	ReferenceValue
+)
+
+// XMLEncodeOption configures XMLFile.Encode.
+type XMLEncodeOption struct {
+	Indent     string          // indentation of nested elements, none if empty
+	References ReferenceMode   // how to write references to resources
+	Table      *TableFile      // resolves references, defaults to the table of the APK the file was read from
+	Config     *ResTableConfig // configuration ReferenceValue resolves values for
+}
+
+// Encode writes the XML file in text format to w.
+func (f *XMLFile) Encode(w io.Writer, option XMLEncodeOption) error {
+	if option.Table == nil {
+		option.Table = f.table
+	}
+	bw := bufio.NewWriter(w)
+	fmt.Fprint(bw, xml.Header)
+	if err := f.encode(bw, &option); err != nil {
+		return err
+	}
+	fmt.Fprintln(bw)
+	return bw.Flush()
+}
+
+// String returns the XML file in text format, with nested elements indented.
+func (f *XMLFile) String() string {
+	var buf strings.Builder
+	_ = f.Encode(&buf, XMLEncodeOption{Indent: "    "})
+	return buf.String()
+}
+
+func (f *XMLFile) encode(w io.Writer, option *XMLEncodeOption) error {
+	if f.root == nil {
+		return nil
+	}
+	e := &xmlEncoder{
+		w:      w,
+		option: option,
+	}
+	e.encodeElement(f.root, 0)
+	return e.err
+}
+
+// xmlEncoder writes an XML tree as text.
+type xmlEncoder struct {
+	w      io.Writer
+	option *XMLEncodeOption
+	err    error
+	// scopes holds the namespace prefixes in scope, by URI, for every
+	// open element.
+	scopes []map[string]string
+}
+
+func (e *xmlEncoder) printf(format string, args ...interface{}) {
+	if e.err == nil {
+		_, e.err = fmt.Fprintf(e.w, format, args...)
+	}
+}
+
+func (e *xmlEncoder) escape(s string) {
+	if e.err == nil {
+		e.err = xml.EscapeText(e.w, []byte(s))
+	}
+}
+
+func (e *xmlEncoder) newline(depth int) {
+	if e.option.Indent != "" {
+		e.printf("\n%s", strings.Repeat(e.option.Indent, depth))
+	}
+}
+
+// prefix returns the prefix of the namespace uri, declaring it on the
+// current element if no prefix is in scope.
+func (e *xmlEncoder) prefix(uri string, declared *[]XMLNamespace) string {
+	if uri == "" {
+		return ""
+	}
+	scope := e.scopes[len(e.scopes)-1]
+	if prefix, ok := scope[uri]; ok {
+		return prefix
+	}
+
+	// the namespace declarations have been stripped; make a prefix up.
+	used := make(map[string]bool)
+	for _, p := range scope {
+		used[p] = true
+	}
+	prefix := "android"
+	if uri != androidNamespace || used[prefix] {
+		for i := 0; ; i++ {
+			prefix = fmt.Sprintf("ns%d", i)
+			if !used[prefix] {
+				break
+			}
+		}
+	}
+	scope[uri] = prefix
+	*declared = append(*declared, XMLNamespace{Prefix: prefix, URI: uri})
+	return prefix
+}
+
+func qualifiedName(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + ":" + name
+}
+
+func (e *xmlEncoder) encodeElement(elem *XMLElement, depth int) {
+	scope := make(map[string]string)
+	if len(e.scopes) > 0 {
+		for uri, prefix := range e.scopes[len(e.scopes)-1] {
+			scope[uri] = prefix
+		}
+	}
+	declared := append([]XMLNamespace{}, elem.Namespaces...)
+	for _, ns := range declared {
+		scope[ns.URI] = ns.Prefix
+	}
+	e.scopes = append(e.scopes, scope)
+	defer func() {
+		e.scopes = e.scopes[:len(e.scopes)-1]
+	}()
+
+	name := qualifiedName(e.prefix(elem.NS, &declared), elem.Name)
+	attrs := make([]string, len(elem.Attrs))
+	for i := range elem.Attrs {
+		attrs[i] = qualifiedName(e.prefix(elem.Attrs[i].NS, &declared), elem.Attrs[i].Name)
+	}
+
+	e.printf("<%s", name)
+	for _, ns := range declared {
+		if ns.Prefix == "" {
+			e.printf(" xmlns=\"")
+		} else {
+			e.printf(" xmlns:%s=\"", ns.Prefix)
+		}
+		e.escape(ns.URI)
+		e.printf("\"")
+	}
+	for i := range elem.Attrs {
+		e.printf(" %s=\"", attrs[i])
+		e.escape(e.option.attrValue(&elem.Attrs[i]))
+		e.printf("\"")
+	}
+	if len(elem.Children) == 0 {
+		e.printf("/>")
+		return
+	}
+	e.printf(">")
+
+	// only indent children that are all elements, so that text is kept as is.
+	indent := true
+	for _, c := range elem.Children {
+		if _, ok := c.(XMLCharData); ok {
+			indent = false
+		}
+	}
+	for _, c := range elem.Children {
+		switch c := c.(type) {
+		case *XMLElement:
+			if indent {
+				e.newline(depth + 1)
+			}
+			e.encodeElement(c, depth+1)
+		case XMLCharData:
+			e.escape(string(c))
+		}
+	}
+	if indent {
+		e.newline(depth)
+	}
+	e.printf("</%s>", name)
+}
+
+// AttrValue returns the value of attr, substituting references to resources
+// the way Encode does.
+func (f *XMLFile) AttrValue(attr XMLAttr, option XMLEncodeOption) string {
+	if option.Table == nil {
+		option.Table = f.table
+	}
+	return option.attrValue(&attr)
+}
+
+// attrValue returns the value of attr, substituting references to resources
+// according to the options.
+func (option *XMLEncodeOption) attrValue(attr *XMLAttr) string {
+	table := option.Table
+	v := &attr.TypedValue
+	if table == nil || option.References == ReferenceID ||
+		(v.DataType != TypeReference && v.DataType != TypeAttribute) {
+		return attr.Value
+	}
+	if option.References == ReferenceValue && v.DataType == TypeReference {
+		if resolved, err := table.getValue(ResID(v.Data), option.Config); err == nil {
+			return table.formatValue(resolved)
+		}
+	}
+	return table.formatValue(v)
+}
@@ -0,0 +1,76 @@
+package apkparser
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestXMLFileEncode(t *testing.T) {
+	data := encodeXML(testManifest(
+		&xmlElement{Name: "application", Attrs: []xmlAttr{
+			{NS: androidNamespace, Name: "label", Type: TypeReference, Data: 0x7f010000},
+			{NS: androidNamespace, Name: "debuggable", Type: TypeIntBoolean, Data: 0xffffffff},
+		}},
+		&xmlElement{Name: "meta-data", Text: "a < b"},
+	))
+	f, err := NewXMLFile(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	table, err := NewTableFile(bytes.NewReader(testTable()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		option XMLEncodeOption
+		want   string
+	}{
+		{XMLEncodeOption{}, `<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.example.app" android:versionCode="42" android:versionName="1.2.3"><application android:label="@0x7F010000" android:debuggable="true"/><meta-data>a &lt; b</meta-data></manifest>`},
+		{XMLEncodeOption{Indent: "  ", References: ReferenceName, Table: table}, `<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.example.app" android:versionCode="42" android:versionName="1.2.3">
+  <application android:label="@string/app_name" android:debuggable="true"/>
+  <meta-data>a &lt; b</meta-data>
+</manifest>`},
+		{XMLEncodeOption{References: ReferenceValue, Table: table, Config: &ResTableConfig{}}, `android:label="Example"`},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := f.Encode(&buf, tt.option); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), tt.want) {
+			t.Errorf("Encode(%+v) = %s, want %s", tt.option, buf.String(), tt.want)
+		}
+	}
+}
+
+func FuzzXMLFile(f *testing.F) {
+	f.Add(encodeXML(testManifest()))
+	f.Add(encodeXML(testManifest(
+		&xmlElement{Name: "application", Attrs: []xmlAttr{
+			{NS: androidNamespace, Name: "label", Type: TypeReference, Data: 0x7f010000},
+			{NS: androidNamespace, Name: "debuggable", Type: TypeIntBoolean, Data: 0xffffffff},
+		}, Children: []*xmlElement{{Name: "activity", Text: "text"}}},
+	)))
+	table, err := NewTableFile(bytes.NewReader(testTable()))
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		xmlFile, err := NewXMLFile(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		for _, option := range []XMLEncodeOption{
+			{},
+			{Indent: "  ", References: ReferenceName, Table: table},
+			{References: ReferenceValue, Table: table, Config: &ResTableConfig{}},
+		} {
+			_ = xmlFile.Encode(io.Discard, option)
+		}
+		_ = xmlFile.Reader()
+	})
+}
@@ -0,0 +1,392 @@
+package apkparser
+
+import (
+	"archive/zip"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"sync"
+)
+
+// Codes of the warnings about the zip archive of an APK.
+const (
+	WarningDuplicateEntry    = "duplicate-entry"    // entries with the same name
+	WarningHeaderMismatch    = "header-mismatch"    // local header disagrees with the central directory
+	WarningDataDescriptor    = "data-descriptor"    // data descriptor disagrees with the central directory
+	WarningCompressionMethod = "compression-method" // neither stored nor deflated
+	WarningChecksum          = "checksum"           // CRC-32 of the data is wrong
+	WarningSize              = "size"               // uncompressed size of the data is wrong
+	WarningEncrypted         = "encrypted"          // encryption flag, which Android ignores
+	WarningZip64             = "zip64"              // which Android doesn't support
+	WarningCentralDirectory  = "central-directory"  // records or trailing data of the archive
+	WarningInvalidEntry      = "invalid-entry"      // entry which can't be read
+)
+
+// Warning is an anomaly of an APK which doesn't prevent parsing it, such as
+// a malformed zip entry.
+type Warning struct {
+	Code    string `json:"code"`
+	Entry   string `json:"entry,omitempty"` // the zip entry, if any
+	Message string `json:"message"`
+}
+
+func (w Warning) String() string {
+	if w.Entry == "" {
+		return w.Code + ": " + w.Message
+	}
+	return w.Code + ": " + w.Entry + ": " + w.Message
+}
+
+// Signatures and sizes of the zip records.
+const (
+	zipLocalHeaderSignature   = 0x04034b50
+	zipCentralHeaderSignature = 0x02014b50
+	zipEndSignature           = 0x06054b50
+	zipDescriptorSignature    = 0x08074b50
+
+	zipLocalHeaderLen   = 30
+	zipCentralHeaderLen = 46
+	zipEndLen           = 22
+	zipMaxCommentLen    = 0xffff
+
+	zipFlagEncrypted  = 0x1
+	zipFlagDescriptor = 0x8
+)
+
+// zipArchive is the zip archive of an APK. It is read either with
+// archive/zip or, in tolerant mode, like libziparchive reads it on Android.
+type zipArchive struct {
+	files    []*zipEntry          // in central directory order
+	index    map[string]*zipEntry // the entry read for each name
+	mu       sync.Mutex           // guards warnings
+	warnings []Warning
+}
+
+// zipEntry is a file of the zip archive of an APK.
+type zipEntry struct {
+	zip.FileHeader
+	file *zip.File // if read with archive/zip
+
+	// in tolerant mode
+	archive    *zipArchive
+	r          io.ReaderAt
+	dataOffset int64
+	err        error // why the entry can't be read
+}
+
+// newZipArchive returns the zip archive read with archive/zip. Entries with
+// the same name are read like readTolerantZip reads them.
+func newZipArchive(r *zip.Reader) *zipArchive {
+	a := &zipArchive{index: make(map[string]*zipEntry)}
+	for _, f := range r.File {
+		e := &zipEntry{FileHeader: f.FileHeader, file: f}
+		a.files = append(a.files, e)
+		if _, ok := a.index[f.Name]; !ok {
+			a.index[f.Name] = e
+		}
+	}
+	return a
+}
+
+// readTolerantZip reads the zip archive r of size bytes like libziparchive:
+// the entries are listed by the central directory, and their data is read
+// with its compression method and sizes, other than a method besides stored
+// being inflated. Malformed records which libziparchive rejects, or which
+// archive/zip and other tools read differently, are reported as warnings.
+// Of the entries with the same name the first one is read, the one
+// libziparchive indexes before it rejects the archive, as Android refuses to
+// install it.
+func readTolerantZip(r io.ReaderAt, size int64) (*zipArchive, error) {
+	a := &zipArchive{index: make(map[string]*zipEntry)}
+
+	// the end of central directory record, scanning backward over the comment.
+	tail := int64(zipEndLen + zipMaxCommentLen)
+	if tail > size {
+		tail = size
+	}
+	buf := make([]byte, tail)
+	if _, err := r.ReadAt(buf, size-tail); err != nil && err != io.EOF {
+		return nil, err
+	}
+	i := len(buf) - zipEndLen
+	for ; i >= 0; i-- {
+		if binary.LittleEndian.Uint32(buf[i:]) == zipEndSignature {
+			break
+		}
+	}
+	if i < 0 {
+		return nil, errors.New("apkparser: end of central directory not found")
+	}
+	end := buf[i:]
+	endOffset := size - tail + int64(i)
+	records := int(binary.LittleEndian.Uint16(end[10:]))
+	cdSize := int64(binary.LittleEndian.Uint32(end[12:]))
+	cdOffset := int64(binary.LittleEndian.Uint32(end[16:]))
+	commentLen := int64(binary.LittleEndian.Uint16(end[20:]))
+	if commentLen > size-endOffset-zipEndLen {
+		return nil, fmt.Errorf("apkparser: comment of %d bytes exceeds the archive", commentLen)
+	}
+	if trailing := size - endOffset - zipEndLen - commentLen; trailing > 0 {
+		a.warn(Warning{Code: WarningCentralDirectory, Message: fmt.Sprintf("%d bytes after the end of central directory", trailing)})
+	}
+	if records != int(binary.LittleEndian.Uint16(end[8:])) || binary.LittleEndian.Uint16(end[4:]) != 0 {
+		a.warn(Warning{Code: WarningCentralDirectory, Message: "archive spans several disks"})
+	}
+	if cdOffset+cdSize > endOffset {
+		return nil, fmt.Errorf("apkparser: central directory at %d of %d bytes overlaps its end at %d", cdOffset, cdSize, endOffset)
+	}
+	if records == 0 {
+		return nil, errors.New("apkparser: empty archive")
+	}
+
+	cd := make([]byte, cdSize)
+	if _, err := r.ReadAt(cd, cdOffset); err != nil {
+		return nil, err
+	}
+	off := 0
+	for n := 0; n < records; n++ {
+		if off+zipCentralHeaderLen > len(cd) || binary.LittleEndian.Uint32(cd[off:]) != zipCentralHeaderSignature {
+			return nil, fmt.Errorf("apkparser: central directory record %d of %d not found", n, records)
+		}
+		h := cd[off:]
+		nameLen := int(binary.LittleEndian.Uint16(h[28:]))
+		extraLen := int(binary.LittleEndian.Uint16(h[30:]))
+		commentLen := int(binary.LittleEndian.Uint16(h[32:]))
+		recordLen := zipCentralHeaderLen + nameLen + extraLen + commentLen
+		if off+recordLen > len(cd) {
+			return nil, fmt.Errorf("apkparser: central directory record %d of %d exceeds the central directory", n, records)
+		}
+		e := &zipEntry{
+			FileHeader: zip.FileHeader{
+				Name:               string(h[zipCentralHeaderLen : zipCentralHeaderLen+nameLen]),
+				CreatorVersion:     binary.LittleEndian.Uint16(h[4:]),
+				ReaderVersion:      binary.LittleEndian.Uint16(h[6:]),
+				Flags:              binary.LittleEndian.Uint16(h[8:]),
+				Method:             binary.LittleEndian.Uint16(h[10:]),
+				ModifiedTime:       binary.LittleEndian.Uint16(h[12:]),
+				ModifiedDate:       binary.LittleEndian.Uint16(h[14:]),
+				CRC32:              binary.LittleEndian.Uint32(h[16:]),
+				CompressedSize:     binary.LittleEndian.Uint32(h[20:]),
+				UncompressedSize:   binary.LittleEndian.Uint32(h[24:]),
+				CompressedSize64:   uint64(binary.LittleEndian.Uint32(h[20:])),
+				UncompressedSize64: uint64(binary.LittleEndian.Uint32(h[24:])),
+				ExternalAttrs:      binary.LittleEndian.Uint32(h[38:]),
+			},
+			archive: a,
+			r:       r,
+		}
+		localOffset := int64(binary.LittleEndian.Uint32(h[42:]))
+		off += recordLen
+
+		if _, ok := a.index[e.Name]; ok {
+			a.warn(Warning{Code: WarningDuplicateEntry, Entry: e.Name,
+				Message: "several entries with the name, which Android refuses to install; the first one is read"})
+		} else {
+			a.index[e.Name] = e
+		}
+		a.files = append(a.files, e)
+		e.err = e.readLocalHeader(localOffset, cdOffset)
+	}
+	if off != len(cd) {
+		a.warn(Warning{Code: WarningCentralDirectory,
+			Message: fmt.Sprintf("%d bytes of the central directory after its %d records", len(cd)-off, records)})
+	}
+	return a, nil
+}
+
+// readLocalHeader reads the local header of the entry at offset, before the
+// central directory at cdOffset, and compares it with the central directory.
+func (e *zipEntry) readLocalHeader(offset, cdOffset int64) error {
+	a := e.archive
+	invalid := func(msg string) error {
+		a.warn(Warning{Code: WarningInvalidEntry, Entry: e.Name, Message: msg})
+		return fmt.Errorf("apkparser: %s: %s", e.Name, msg)
+	}
+	if e.CompressedSize == 0xffffffff || e.UncompressedSize == 0xffffffff || offset == 0xffffffff {
+		a.warn(Warning{Code: WarningZip64, Entry: e.Name, Message: "zip64 sizes or offset, which Android doesn't support"})
+	}
+	if e.Flags&zipFlagEncrypted != 0 {
+		a.warn(Warning{Code: WarningEncrypted, Entry: e.Name, Message: "encryption flag, which Android ignores"})
+	}
+	if e.Method != zip.Store && e.Method != zip.Deflate {
+		a.warn(Warning{Code: WarningCompressionMethod, Entry: e.Name,
+			Message: fmt.Sprintf("compression method %d, inflated as Android does", e.Method)})
+	}
+	if e.Method == zip.Store && e.CompressedSize != e.UncompressedSize {
+		a.warn(Warning{Code: WarningSize, Entry: e.Name,
+			Message: fmt.Sprintf("stored with %d compressed and %d uncompressed bytes; the uncompressed size is read", e.CompressedSize, e.UncompressedSize)})
+	}
+
+	if offset+zipLocalHeaderLen > cdOffset {
+		return invalid(fmt.Sprintf("local header at %d overlaps the central directory", offset))
+	}
+	h := make([]byte, zipLocalHeaderLen)
+	if _, err := e.r.ReadAt(h, offset); err != nil {
+		return invalid(fmt.Sprintf("local header at %d: %v", offset, err))
+	}
+	if binary.LittleEndian.Uint32(h) != zipLocalHeaderSignature {
+		return invalid(fmt.Sprintf("no local header at %d", offset))
+	}
+	flags := binary.LittleEndian.Uint16(h[6:])
+	method := binary.LittleEndian.Uint16(h[8:])
+	crc := binary.LittleEndian.Uint32(h[14:])
+	compressedSize := binary.LittleEndian.Uint32(h[18:])
+	uncompressedSize := binary.LittleEndian.Uint32(h[22:])
+	nameLen := int64(binary.LittleEndian.Uint16(h[26:]))
+	extraLen := int64(binary.LittleEndian.Uint16(h[28:]))
+	e.dataOffset = offset + zipLocalHeaderLen + nameLen + extraLen
+
+	name := make([]byte, nameLen)
+	if _, err := e.r.ReadAt(name, offset+zipLocalHeaderLen); err != nil {
+		return invalid(fmt.Sprintf("local header at %d: %v", offset, err))
+	}
+	mismatch := func(field string, local, central interface{}) {
+		a.warn(Warning{Code: WarningHeaderMismatch, Entry: e.Name,
+			Message: fmt.Sprintf("%s %v in the local header, %v in the central directory", field, local, central)})
+	}
+	if string(name) != e.Name {
+		mismatch("name", fmt.Sprintf("%q", name), fmt.Sprintf("%q", e.Name))
+	}
+	if method != e.Method {
+		mismatch("compression method", method, e.Method)
+	}
+	if flags&zipFlagDescriptor == 0 {
+		if crc != e.CRC32 {
+			mismatch("CRC-32", fmt.Sprintf("%08x", crc), fmt.Sprintf("%08x", e.CRC32))
+		}
+		if compressedSize != e.CompressedSize {
+			mismatch("compressed size", compressedSize, e.CompressedSize)
+		}
+		if uncompressedSize != e.UncompressedSize {
+			mismatch("uncompressed size", uncompressedSize, e.UncompressedSize)
+		}
+	}
+
+	dataEnd := e.dataOffset + int64(e.CompressedSize)
+	if e.Method == zip.Store {
+		dataEnd = e.dataOffset + int64(e.UncompressedSize)
+	}
+	if dataEnd > cdOffset {
+		return invalid(fmt.Sprintf("data at %d of %d bytes overlaps the central directory", e.dataOffset, dataEnd-e.dataOffset))
+	}
+	if flags&zipFlagDescriptor != 0 {
+		e.readDataDescriptor(e.dataOffset+int64(e.CompressedSize), cdOffset)
+	}
+	return nil
+}
+
+// readDataDescriptor reads the data descriptor of the entry at offset and
+// compares it with the central directory, which libziparchive reads the
+// sizes from.
+func (e *zipEntry) readDataDescriptor(offset, cdOffset int64) {
+	d := make([]byte, 16)
+	if offset+12 > cdOffset {
+		e.archive.warn(Warning{Code: WarningDataDescriptor, Entry: e.Name, Message: "data descriptor overlaps the central directory"})
+		return
+	}
+	n, _ := e.r.ReadAt(d, offset)
+	if n >= 4 && binary.LittleEndian.Uint32(d) == zipDescriptorSignature {
+		d = d[4:n]
+	} else {
+		d = d[:n]
+	}
+	if len(d) < 12 {
+		e.archive.warn(Warning{Code: WarningDataDescriptor, Entry: e.Name, Message: "truncated data descriptor"})
+		return
+	}
+	crc := binary.LittleEndian.Uint32(d)
+	compressedSize := binary.LittleEndian.Uint32(d[4:])
+	uncompressedSize := binary.LittleEndian.Uint32(d[8:])
+	if crc != e.CRC32 || compressedSize != e.CompressedSize || uncompressedSize != e.UncompressedSize {
+		e.archive.warn(Warning{Code: WarningDataDescriptor, Entry: e.Name,
+			Message: fmt.Sprintf("CRC-32 %08x and sizes %d/%d in the data descriptor, %08x and %d/%d in the central directory",
+				crc, compressedSize, uncompressedSize, e.CRC32, e.CompressedSize, e.UncompressedSize)})
+	}
+}
+
+// Open returns a ReadCloser that provides access to the uncompressed data of
+// the entry. In tolerant mode, a wrong CRC-32 or size of the data is reported
+// as a warning once the data is read to the end, instead of an error.
+func (e *zipEntry) Open() (io.ReadCloser, error) {
+	if e.file != nil {
+		return e.file.Open()
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+	if e.Method == zip.Store {
+		return &zipEntryReader{
+			r:    io.NewSectionReader(e.r, e.dataOffset, int64(e.UncompressedSize)),
+			e:    e,
+			hash: crc32.NewIEEE(),
+		}, nil
+	}
+	// libziparchive inflates every method but stored.
+	fr := flate.NewReader(io.NewSectionReader(e.r, e.dataOffset, int64(e.CompressedSize)))
+	return &zipEntryReader{r: fr, closer: fr, e: e, hash: crc32.NewIEEE()}, nil
+}
+
+// zipEntryReader reads the data of an entry in tolerant mode, and checks it
+// at the end.
+type zipEntryReader struct {
+	r      io.Reader
+	closer io.Closer
+	e      *zipEntry
+	hash   hash.Hash32
+	n      uint64
+	done   bool
+}
+
+func (r *zipEntryReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.hash.Write(p[:n])
+	r.n += uint64(n)
+	if err == io.EOF && !r.done {
+		r.done = true
+		e := r.e
+		if r.n != uint64(e.UncompressedSize) {
+			e.archive.warn(Warning{Code: WarningSize, Entry: e.Name,
+				Message: fmt.Sprintf("%d bytes of data, %d in the central directory", r.n, e.UncompressedSize)})
+		}
+		if sum := r.hash.Sum32(); sum != e.CRC32 {
+			e.archive.warn(Warning{Code: WarningChecksum, Entry: e.Name,
+				Message: fmt.Sprintf("CRC-32 of the data %08x, %08x in the central directory", sum, e.CRC32)})
+		}
+	}
+	return n, err
+}
+
+func (r *zipEntryReader) Close() error {
+	if r.closer != nil {
+		return r.closer.Close()
+	}
+	return nil
+}
+
+// lookup returns the entry read for name, or nil if there is none.
+func (a *zipArchive) lookup(name string) *zipEntry {
+	return a.index[name]
+}
+
+// warn records w, unless it was already recorded.
+func (a *zipArchive) warn(w Warning) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, v := range a.warnings {
+		if v == w {
+			return
+		}
+	}
+	a.warnings = append(a.warnings, w)
+}
+
+// recorded returns the warnings recorded so far.
+func (a *zipArchive) recorded() []Warning {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]Warning(nil), a.warnings...)
+}
@@ -0,0 +1,192 @@
+package apkparser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// zipHeaders returns the offsets of the local and central directory headers
+// of the entry name in the zip archive data.
+func zipHeaders(t *testing.T, data []byte, name string) (local, central int) {
+	t.Helper()
+	local, central = -1, -1
+	for i := 0; i+zipCentralHeaderLen <= len(data); i++ {
+		switch binary.LittleEndian.Uint32(data[i:]) {
+		case zipLocalHeaderSignature:
+			n := int(binary.LittleEndian.Uint16(data[i+26:]))
+			if local < 0 && string(data[i+zipLocalHeaderLen:i+zipLocalHeaderLen+n]) == name {
+				local = i
+			}
+		case zipCentralHeaderSignature:
+			n := int(binary.LittleEndian.Uint16(data[i+28:]))
+			if central < 0 && string(data[i+zipCentralHeaderLen:i+zipCentralHeaderLen+n]) == name {
+				central = i
+			}
+		}
+	}
+	if local < 0 || central < 0 {
+		t.Fatalf("headers of %s not found", name)
+	}
+	return local, central
+}
+
+func TestTolerantZip(t *testing.T) {
+	decoy := testManifest()
+	decoy.Attrs[0].Value = "com.example.decoy"
+	files := []zipFile{
+		{"AndroidManifest.xml", encodeXML(testManifest())},
+		{"resources.arsc", testTable()},
+		{"assets/data.json", []byte(`{"key": "value"}`)},
+	}
+
+	tests := []struct {
+		name   string
+		files  []zipFile
+		patch  func(t *testing.T, data []byte) []byte
+		strict bool   // whether archive/zip reads the APK
+		pkg    string // package read
+		codes  []string
+	}{
+		{
+			name:   "valid",
+			files:  files,
+			strict: true,
+			pkg:    "com.example.app",
+		},
+		{
+			name:  "duplicate manifest",
+			files: append([]zipFile{{"AndroidManifest.xml", encodeXML(decoy)}}, files...),
+			// the first manifest is read in both modes.
+			strict: true,
+			pkg:    "com.example.decoy",
+			codes:  []string{WarningDuplicateEntry},
+		},
+		{
+			name:  "bogus compression method of the manifest",
+			files: files,
+			patch: func(t *testing.T, data []byte) []byte {
+				local, central := zipHeaders(t, data, "AndroidManifest.xml")
+				binary.LittleEndian.PutUint16(data[local+8:], 0x1337)
+				binary.LittleEndian.PutUint16(data[central+10:], 0x1337)
+				return data
+			},
+			pkg:   "com.example.app",
+			codes: []string{WarningCompressionMethod},
+		},
+		{
+			name:  "local header mismatch",
+			files: files,
+			patch: func(t *testing.T, data []byte) []byte {
+				local, _ := zipHeaders(t, data, "assets/data.json")
+				data[local+zipLocalHeaderLen] = 'b'
+				return data
+			},
+			// archive/zip doesn't compare the local header.
+			strict: true,
+			pkg:    "com.example.app",
+			codes:  []string{WarningHeaderMismatch},
+		},
+		{
+			name:  "wrong data descriptor and checksum",
+			files: files,
+			patch: func(t *testing.T, data []byte) []byte {
+				_, central := zipHeaders(t, data, "AndroidManifest.xml")
+				binary.LittleEndian.PutUint32(data[central+16:], 0xdeadbeef)
+				return data
+			},
+			pkg:   "com.example.app",
+			codes: []string{WarningDataDescriptor, WarningChecksum},
+		},
+		{
+			name:  "wrong uncompressed size",
+			files: files,
+			patch: func(t *testing.T, data []byte) []byte {
+				_, central := zipHeaders(t, data, "AndroidManifest.xml")
+				size := binary.LittleEndian.Uint32(data[central+24:])
+				binary.LittleEndian.PutUint32(data[central+24:], size+1)
+				return data
+			},
+			pkg:   "com.example.app",
+			codes: []string{WarningDataDescriptor, WarningSize},
+		},
+		{
+			name:  "trailing data",
+			files: files,
+			patch: func(t *testing.T, data []byte) []byte {
+				return append(data, "trailer"...)
+			},
+			// archive/zip ignores data after the archive.
+			strict: true,
+			pkg:    "com.example.app",
+			codes:  []string{WarningCentralDirectory},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := encodeZip(t, tt.files...)
+			if tt.patch != nil {
+				data = tt.patch(t, data)
+			}
+			name := filepath.Join(t.TempDir(), "test.apk")
+			if err := os.WriteFile(name, data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			info, err := New(name, Option{})
+			if tt.strict != (err == nil) {
+				t.Errorf("New() error = %v", err)
+			}
+			if err == nil {
+				if info.BundleId != tt.pkg || info.Warnings != nil {
+					t.Errorf("BundleId = %q, Warnings = %v without Tolerant", info.BundleId, info.Warnings)
+				}
+			}
+
+			info, err = New(name, Option{Tolerant: true})
+			if err != nil {
+				t.Fatalf("New(Tolerant) error = %v", err)
+			}
+			if info.BundleId != tt.pkg {
+				t.Errorf("BundleId = %q, want %q", info.BundleId, tt.pkg)
+			}
+			var codes []string
+			for _, w := range info.Warnings {
+				codes = append(codes, w.Code)
+			}
+			if len(codes) != len(tt.codes) {
+				t.Fatalf("Warnings = %v, want codes %q", info.Warnings, tt.codes)
+			}
+			for i, code := range tt.codes {
+				if codes[i] != code {
+					t.Errorf("Warnings = %v, want codes %q", info.Warnings, tt.codes)
+				}
+			}
+		})
+	}
+}
+
+func TestTolerantZipInvalid(t *testing.T) {
+	data := encodeZip(t, zipFile{"AndroidManifest.xml", encodeXML(testManifest())})
+
+	// no end of central directory
+	if _, err := readTolerantZip(bytes.NewReader(data[:len(data)-zipEndLen]), int64(len(data)-zipEndLen)); err == nil {
+		t.Error("readTolerantZip() without end of central directory succeeded")
+	}
+
+	// local header pointing into the central directory
+	_, central := zipHeaders(t, data, "AndroidManifest.xml")
+	binary.LittleEndian.PutUint32(data[central+42:], uint32(central))
+	a, err := readTolerantZip(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.lookup("AndroidManifest.xml").Open(); err == nil {
+		t.Error("Open() of an invalid entry succeeded")
+	}
+	if w := a.recorded(); len(w) != 1 || w[0].Code != WarningInvalidEntry {
+		t.Errorf("warnings = %v", w)
+	}
+}